@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+// attribute is a key attribute of a table or index.
+type attribute struct {
+	name string
+	typ  string
+}
+
+// index is a global secondary index declared with `index=`.
+type index struct {
+	name    string
+	hashKey string
+}
+
+// table is the schema of a tagged model.
+type table struct {
+	typeName   string
+	name       string
+	hashKey    string
+	rangeKey   string
+	attributes []attribute
+	indexes    []index
+	// ttl is the attribute of the field tagged with `ttl=`, if any.
+	ttl string
+}
+
+// parseDirs parses the non-test Go files of each directory and returns the
+// tables of every struct that declares a `key=hash` field, sorted by name.
+func parseDirs(dirs []string) ([]table, error) {
+	var tables []table
+	for _, dir := range dirs {
+		found, err := parseDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, found...)
+	}
+	sort.SliceStable(tables, func(i, j int) bool { return tables[i].name < tables[j].name })
+	for i := 1; i < len(tables); i++ {
+		if tables[i].name == tables[i-1].name {
+			return nil, fmt.Errorf("models %s and %s use the same table %s", tables[i-1].typeName, tables[i].typeName, tables[i].name)
+		}
+	}
+	return tables, nil
+}
+
+func parseDir(dir string) ([]table, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return parseFiles(fset, files)
+}
+
+// parseFiles type-checks the files of a single package and extracts its
+// tables. Imported packages are type-checked from source, so key fields of
+// named types and embedded structs from other packages are resolved.
+func parseFiles(fset *token.FileSet, files []*ast.File) ([]table, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(files[0].Name.Name, fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+	tableNames := tableNameMethods(files, info)
+
+	var tables []table
+	scope := pkg.Scope()
+	for _, typeName := range scope.Names() {
+		obj, ok := scope.Lookup(typeName).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		structType, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		t, ok, err := parseStruct(typeName, structType)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if t.name, err = tableName(named, tableNames); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// tableNameMethods returns the table names of the TableName methods of files
+// that return a constant string.
+func tableNameMethods(files []*ast.File, info *types.Info) map[*types.Func]string {
+	names := make(map[*types.Func]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Name.Name != "TableName" || decl.Recv == nil || decl.Body == nil || len(decl.Body.List) != 1 {
+				continue
+			}
+			ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+			if !ok || len(ret.Results) != 1 {
+				continue
+			}
+			value := info.Types[ret.Results[0]].Value
+			if value == nil || value.Kind() != constant.String {
+				continue
+			}
+			if fn, ok := info.Defs[decl.Name].(*types.Func); ok {
+				names[fn] = constant.StringVal(value)
+			}
+		}
+	}
+	return names
+}
+
+// tableName resolves the table of named like Repository does for a pointer
+// to it: a TableName method, possibly promoted from an embedded struct, or
+// else the type name. A TableName method whose result is not a constant
+// declared in the parsed package is an error.
+func tableName(named *types.Named, tableNames map[*types.Func]string) (string, error) {
+	typeName := named.Obj().Name()
+	selection := types.NewMethodSet(types.NewPointer(named)).Lookup(named.Obj().Pkg(), "TableName")
+	if selection == nil {
+		return typeName, nil
+	}
+	fn := selection.Obj().(*types.Func)
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), types.Typ[types.String]) {
+		return typeName, nil
+	}
+	name, ok := tableNames[fn]
+	if !ok {
+		return "", fmt.Errorf("%s: TableName must return a constant string", typeName)
+	}
+	return name, nil
+}
+
+// structField is a `dynamo`-tagged field of a model, or of a struct embedded
+// in it at the given depth.
+type structField struct {
+	name  string
+	typ   types.Type
+	tag   *db.DynamoTagParser
+	depth int
+}
+
+// collectFields mirrors the flattening of embedded structs of the dynamodb
+// package: untagged anonymous struct fields without a dynamodbav tag are
+// flattened into their parent.
+func collectFields(structType *types.Struct, depth int, visiting map[*types.Struct]bool, fields *[]structField) error {
+	visiting[structType] = true
+	defer delete(visiting, structType)
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		structTag := reflect.StructTag(structType.Tag(i))
+		tag, tagged := structTag.Lookup("dynamo")
+		if embedded := embeddedStruct(field, structTag); embedded != nil && !tagged {
+			if !visiting[embedded] {
+				if err := collectFields(embedded, depth+1, visiting, fields); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tagged {
+			*fields = append(*fields, structField{name: field.Name(), typ: field.Type(), tag: db.ParseDynamoTag(tag), depth: depth})
+		}
+	}
+	return nil
+}
+
+// embeddedStruct returns the struct type of an anonymous field that is
+// flattened when marshaled, or nil.
+func embeddedStruct(field *types.Var, tag reflect.StructTag) *types.Struct {
+	if !field.Embedded() || tag.Get("dynamodbav") != "" {
+		return nil
+	}
+	typ := field.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	structType, _ := typ.Underlying().(*types.Struct)
+	return structType
+}
+
+// visibleFields resolves attribute names like the dynamodb package: the
+// shallowest field wins, and two fields at the same depth conflict.
+func visibleFields(typeName string, fields []structField) ([]structField, error) {
+	byAttribute := make(map[string]structField)
+	for _, field := range fields {
+		if field.tag.AttributeName == "" {
+			continue
+		}
+		other, ok := byAttribute[field.tag.AttributeName]
+		switch {
+		case !ok:
+		case other.depth < field.depth:
+			continue
+		case other.depth == field.depth:
+			return nil, fmt.Errorf("%s: fields %s and %s both use attribute %s", typeName, other.name, field.name, field.tag.AttributeName)
+		}
+		byAttribute[field.tag.AttributeName] = field
+	}
+	var visible []structField
+	for _, field := range fields {
+		if field.tag.AttributeName == "" || byAttribute[field.tag.AttributeName] == field {
+			visible = append(visible, field)
+		}
+	}
+	return visible, nil
+}
+
+// parseStruct mirrors the schema derivation of Repository.CreateTableInput,
+// including the fields of embedded structs. ok is false for structs without
+// a hash key.
+func parseStruct(typeName string, structType *types.Struct) (t table, ok bool, err error) {
+	t.typeName = typeName
+	var fields []structField
+	if err := collectFields(structType, 0, map[*types.Struct]bool{}, &fields); err != nil {
+		return t, false, err
+	}
+	if fields, err = visibleFields(typeName, fields); err != nil {
+		return t, false, err
+	}
+	for _, field := range fields {
+		parsed := field.tag
+		if parsed.TTL != "" {
+			t.ttl = parsed.AttributeName
+		}
+		if parsed.KeyType == "" && parsed.Index == "" {
+			continue
+		}
+		typ, err := attributeType(field.typ)
+		if err != nil {
+			return t, false, fmt.Errorf("%s.%s: %w", typeName, field.name, err)
+		}
+		t.attributes = append(t.attributes, attribute{name: parsed.AttributeName, typ: typ})
+		switch parsed.KeyType {
+		case "":
+		case db.KeyTypeHash:
+			if t.hashKey != "" {
+				return t, false, fmt.Errorf("%s: model must declare exactly one hash key", typeName)
+			}
+			t.hashKey = parsed.AttributeName
+		case db.KeyTypeRange:
+			if t.rangeKey != "" {
+				return t, false, fmt.Errorf("%s: model must declare at most one range key", typeName)
+			}
+			t.rangeKey = parsed.AttributeName
+		default:
+			return t, false, fmt.Errorf("%s.%s has unknown key type %q", typeName, field.name, parsed.KeyType)
+		}
+		if parsed.Index != "" {
+			for _, idx := range t.indexes {
+				if idx.name == parsed.Index {
+					return t, false, fmt.Errorf("%s: index %s is declared on both %s and %s", typeName, parsed.Index, idx.hashKey, parsed.AttributeName)
+				}
+			}
+			t.indexes = append(t.indexes, index{name: parsed.Index, hashKey: parsed.AttributeName})
+		}
+	}
+	return t, t.hashKey != "", nil
+}
+
+// attributeType maps a field type to the DynamoDB type it is marshaled to,
+// like scalarAttributeType of the dynamodb package.
+func attributeType(typ types.Type) (string, error) {
+	if named, ok := typ.(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			return "S", nil
+		}
+	}
+	if types.Identical(typ, types.NewSlice(types.Typ[types.Byte])) {
+		return "B", nil
+	}
+	if basic, ok := typ.Underlying().(*types.Basic); ok {
+		switch basic.Kind() {
+		case types.String:
+			return "S", nil
+		case types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+			types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64,
+			types.Float32, types.Float64:
+			return "N", nil
+		}
+	}
+	return "", fmt.Errorf("type %s cannot be used as a key", types.TypeString(typ, (*types.Package).Name))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	tables, err := parseDirs([]string{"testdata/models"})
+	require.NoError(t, err)
+	require.Len(t, tables, 2)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTemplate(&buf, tables, "DynamoDB tables generated from Go models"))
+
+	expected, err := os.ReadFile("testdata/models.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
+
+func TestParseFiles(t *testing.T) {
+	parse := func(t *testing.T, src string) ([]table, error) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "models.go", src, 0)
+		require.NoError(t, err)
+		return parseFiles(fset, []*ast.File{file})
+	}
+
+	t.Run("Table name defaults to the type name", func(t *testing.T) {
+		tables, err := parse(t, `package models
+type Session struct {
+	Token []byte `+"`dynamo:\"token,key=hash\"`"+`
+}`)
+		require.NoError(t, err)
+		require.Len(t, tables, 1)
+		assert.Equal(t, "Session", tables[0].name)
+		assert.Equal(t, []attribute{{name: "token", typ: "B"}}, tables[0].attributes)
+	})
+
+	t.Run("Unsupported key type", func(t *testing.T) {
+		_, err := parse(t, `package models
+type Session struct {
+	Tags []string `+"`dynamo:\"tags,key=hash\"`"+`
+}`)
+		assert.ErrorContains(t, err, "Session.Tags: type []string cannot be used as a key")
+	})
+
+	t.Run("Duplicate hash key", func(t *testing.T) {
+		_, err := parse(t, `package models
+type Session struct {
+	A string `+"`dynamo:\"a,key=hash\"`"+`
+	B string `+"`dynamo:\"b,key=hash\"`"+`
+}`)
+		assert.ErrorContains(t, err, "exactly one hash key")
+	})
+
+	t.Run("Keys of embedded structs", func(t *testing.T) {
+		tables, err := parse(t, `package models
+type Named struct{}
+func (Named) TableName() string { return "invoices" }
+type Base struct {
+	TenantID string `+"`dynamo:\"tenant_id,key=hash\"`"+`
+	ID       string `+"`dynamo:\"id\"`"+`
+}
+type Invoice struct {
+	Named
+	Base
+	ID string `+"`dynamo:\"id,key=range\"`"+`
+}`)
+		require.NoError(t, err)
+		require.Len(t, tables, 2)
+		assert.Equal(t, "Base", tables[0].name)
+		invoice := tables[1]
+		assert.Equal(t, "invoices", invoice.name, "TableName is promoted from Named")
+		assert.Equal(t, "tenant_id", invoice.hashKey)
+		assert.Equal(t, "id", invoice.rangeKey, "the shallower id field hides the one of Base")
+		assert.Equal(t, []attribute{{name: "tenant_id", typ: "S"}, {name: "id", typ: "S"}}, invoice.attributes)
+	})
+
+	t.Run("Named key type of another package", func(t *testing.T) {
+		tables, err := parse(t, `package models
+import "time"
+type Job struct {
+	Queue string        `+"`dynamo:\"queue,key=hash\"`"+`
+	Delay time.Duration `+"`dynamo:\"delay,key=range\"`"+`
+}`)
+		require.NoError(t, err)
+		require.Len(t, tables, 1)
+		assert.Equal(t, []attribute{{name: "queue", typ: "S"}, {name: "delay", typ: "N"}}, tables[0].attributes)
+	})
+
+	t.Run("TableName without a constant result", func(t *testing.T) {
+		_, err := parse(t, `package models
+import "os"
+type Session struct {
+	Token string `+"`dynamo:\"token,key=hash\"`"+`
+}
+func (s *Session) TableName() string { return os.Getenv("TABLE") }`)
+		assert.ErrorContains(t, err, "Session: TableName must return a constant string")
+	})
+
+	t.Run("Unresolved type", func(t *testing.T) {
+		_, err := parse(t, `package models
+type Session struct {
+	Token Missing `+"`dynamo:\"token,key=hash\"`"+`
+}`)
+		assert.ErrorContains(t, err, "undefined: Missing")
+	})
+
+	t.Run("Duplicate index name", func(t *testing.T) {
+		_, err := parse(t, `package models
+type Session struct {
+	ID    string `+"`dynamo:\"id,key=hash\"`"+`
+	Email string `+"`dynamo:\"email,index=lookup-index\"`"+`
+	Phone string `+"`dynamo:\"phone,index=lookup-index\"`"+`
+}`)
+		assert.ErrorContains(t, err, "Session: index lookup-index is declared on both email and phone")
+	})
+}
+
+func TestLogicalID(t *testing.T) {
+	assert.Equal(t, "UsersTable", logicalID("Users"))
+	assert.Equal(t, "OrderItemsTable", logicalID("order-items"))
+	assert.Equal(t, "UserSessionsV2Table", logicalID("user_sessions.v2"))
+}
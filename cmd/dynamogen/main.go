@@ -0,0 +1,53 @@
+// Command dynamogen generates a CloudFormation template with an
+// AWS::DynamoDB::Table resource for every `dynamo`-tagged model that
+// declares a `key=hash` field.
+//
+// Usage:
+//
+//	go run ./cmd/dynamogen [-o template.yaml] [-description text] [dir ...]
+//
+// Each directory is parsed and type-checked as a Go package (without its
+// tests); the current directory is used when none is given. The table name
+// comes from a TableName method returning a constant string, or else the type
+// name, and is suffixed with the Environment parameter like
+// templates/sample_dynamodb.yaml.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	output := flag.String("o", "", "output file (default stdout)")
+	description := flag.String("description", "DynamoDB tables generated from Go models", "template description")
+	flag.Parse()
+
+	if err := run(flag.Args(), *output, *description); err != nil {
+		fmt.Fprintln(os.Stderr, "dynamogen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dirs []string, output, description string) error {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	tables, err := parseDirs(dirs)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return writeTemplate(os.Stdout, tables, description)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := writeTemplate(f, tables, description); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
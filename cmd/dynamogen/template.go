@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// writeTemplate writes a CloudFormation template with one AWS::DynamoDB::Table
+// resource per table, in the layout of templates/sample_dynamodb.yaml.
+func writeTemplate(w io.Writer, tables []table, description string) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("no models with a key=hash field found")
+	}
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...interface{}) {
+		fmt.Fprintf(bw, format+"\n", args...)
+	}
+
+	p("AWSTemplateFormatVersion: '2010-09-09'")
+	p("Description: %s", quote(description))
+	p("")
+	p("Parameters:")
+	p("  Environment:")
+	p("    Type: String")
+	p("    Default: dev")
+	p("    AllowedValues:")
+	p("      - dev")
+	p("      - stg")
+	p("      - prd")
+	p("    Description: Environment name")
+	p("")
+	p("Resources:")
+	for i, t := range tables {
+		if i > 0 {
+			p("")
+		}
+		p("  %s:", logicalID(t.name))
+		p("    Type: AWS::DynamoDB::Table")
+		p("    Properties:")
+		p("      TableName: !Sub %s-${Environment}", t.name)
+		p("      BillingMode: PAY_PER_REQUEST")
+		p("      AttributeDefinitions:")
+		for _, attr := range t.attributes {
+			p("        - AttributeName: %s", attr.name)
+			p("          AttributeType: %s", attr.typ)
+		}
+		p("      KeySchema:")
+		p("        - AttributeName: %s", t.hashKey)
+		p("          KeyType: HASH")
+		if t.rangeKey != "" {
+			p("        - AttributeName: %s", t.rangeKey)
+			p("          KeyType: RANGE")
+		}
+		if len(t.indexes) > 0 {
+			p("      GlobalSecondaryIndexes:")
+			for _, idx := range t.indexes {
+				p("        - IndexName: %s", idx.name)
+				p("          KeySchema:")
+				p("            - AttributeName: %s", idx.hashKey)
+				p("              KeyType: HASH")
+				p("          Projection:")
+				p("            ProjectionType: ALL")
+			}
+		}
+		if t.ttl != "" {
+			p("      TimeToLiveSpecification:")
+			p("        AttributeName: %s", t.ttl)
+			p("        Enabled: true")
+		}
+		p("      Tags:")
+		p("        - Key: Environment")
+		p("          Value: !Ref Environment")
+	}
+	p("")
+	p("Outputs:")
+	for _, t := range tables {
+		id := logicalID(t.name)
+		p("  %sName:", id)
+		p("    Description: Name of the %s table", t.name)
+		p("    Value: !Ref %s", id)
+		p("  %sArn:", id)
+		p("    Description: ARN of the %s table", t.name)
+		p("    Value: !GetAtt %s.Arn", id)
+	}
+	return bw.Flush()
+}
+
+// logicalID derives the resource name from a table name, e.g. "user-sessions"
+// becomes "UserSessionsTable".
+func logicalID(tableName string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range tableName {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "Table"
+}
+
+// quote returns s as a single-quoted YAML string.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type Status string
+
+// User matches the table of templates/sample_dynamodb.yaml.
+type User struct {
+	ID        string     `dynamodbav:"id" dynamo:"id,key=hash"`
+	Email     string     `dynamodbav:"email" dynamo:"email,index=email-index,required"`
+	Name      string     `dynamodbav:"name" dynamo:"name,index=name-index"`
+	CreatedAt time.Time  `dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at" dynamo:"deleted_at,softDelete"`
+	ExpiresAt int64      `dynamodbav:"expires_at" dynamo:"expires_at,ttl=720h"`
+}
+
+func (u *User) TableName() string {
+	return "Users"
+}
+
+type Order struct {
+	UserID  string  `dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID int64   `dynamodbav:"order_id" dynamo:"order_id,key=range"`
+	Status  Status  `dynamodbav:"status" dynamo:"status,index=status-index"`
+	Total   float64 `dynamodbav:"total" dynamo:"total"`
+}
+
+func (o Order) TableName() string {
+	return "order-items"
+}
+
+// Address has no key and is not a table.
+type Address struct {
+	City string `dynamo:"city"`
+}
@@ -0,0 +1,488 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDB limits for a single BatchWriteItem and BatchGetItem call.
+const (
+	maxBatchWriteItems = 25
+	maxBatchGetItems   = 100
+)
+
+const (
+	defaultBatchConcurrency = 4
+	defaultBatchMaxRetries  = 5
+	defaultBatchBaseDelay   = 50 * time.Millisecond
+	maxBatchBackoff         = 5 * time.Second
+)
+
+// ErrUnprocessedItems is returned when DynamoDB still reports unprocessed
+// items or keys after every retry of a batch operation.
+var ErrUnprocessedItems = errors.New("batch items left unprocessed")
+
+// WithBatchConcurrency sets how many batch chunks BatchCreate, BatchDelete
+// and BatchFindByIDs send at the same time. The default is 4.
+func WithBatchConcurrency(n int) RepositoryOption {
+	return func(r *Repository) {
+		if n > 0 {
+			r.batchConcurrency = n
+		}
+	}
+}
+
+// WithBatchRetry sets how often unprocessed items are retried and the delay
+// before the first retry. The delay doubles on every retry, up to 5 seconds.
+// The default is 5 retries starting at 50ms. Negative values are ignored.
+func WithBatchRetry(maxRetries int, baseDelay time.Duration) RepositoryOption {
+	return func(r *Repository) {
+		if maxRetries >= 0 {
+			r.batchMaxRetries = maxRetries
+		}
+		if baseDelay >= 0 {
+			r.batchBaseDelay = baseDelay
+		}
+	}
+}
+
+// tableWriteRequest is a single put or delete together with its table.
+type tableWriteRequest struct {
+	tableName string
+	request   types.WriteRequest
+	// key identifies the item in its table, or is empty if the model
+	// declares no key fields.
+	key string
+}
+
+// BatchCreate stores every item of items, which must be a slice of structs
+// or of pointers to structs, using BatchWriteItem.
+//
+// BeforeCreate hooks are called and all items are validated before anything
+// is written. Unlike Create, BatchCreate cannot guard against duplicate keys:
+// an existing item with the same key is overwritten. Two items of items with
+// the same key are rejected before anything is written.
+//
+// The write is not atomic. Items are written in chunks of 25, and when a
+// chunk fails the chunks already written stay written. Use a Transaction to
+// write up to 100 items all or nothing.
+func (r *Repository) BatchCreate(ctx context.Context, items interface{}) error {
+	values, err := sliceItems(items)
+	if err != nil {
+		return err
+	}
+	requests := make([]tableWriteRequest, 0, len(values))
+	for _, item := range values {
+		if item, err = beforeCreate(ctx, item); err != nil {
+			return err
+		}
+		if item, err = r.scopeItem(item); err != nil {
+			return err
+		}
+		if item, err = r.setTimestamps(item, true); err != nil {
+			return err
+		}
+		if err := validateStruct(item); err != nil {
+			return fmt.Errorf("validation error: %w", err)
+		}
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal item: %w", err)
+		}
+		if err := initVersion(item, av); err != nil {
+			return err
+		}
+		if err := omitUndeleted(item, av); err != nil {
+			return err
+		}
+		tableName := r.getTableName(item)
+		key, err := itemKey(item, av)
+		if err != nil {
+			return err
+		}
+		if err := r.sealItem(ctx, item, tableName, av); err != nil {
+			return err
+		}
+		requests = append(requests, tableWriteRequest{
+			tableName: tableName,
+			request:   types.WriteRequest{PutRequest: &types.PutRequest{Item: av}},
+			key:       key,
+		})
+	}
+	return r.batchWrite(ctx, requests)
+}
+
+// BatchDelete deletes every item of items using BatchWriteItem. Each element
+// is resolved the same way as the argument of Delete: a struct is deleted by
+// its key fields, any other value is treated as an id in the default table.
+//
+// BeforeDelete hooks are called before anything is deleted. Unlike Delete,
+// deleting an item that does not exist is not an error. Models with a
+// `softDelete` field are rejected, since BatchWriteItem cannot update items.
+// Like BatchCreate, the delete is not atomic: when a chunk fails, the chunks
+// already deleted stay deleted.
+func (r *Repository) BatchDelete(ctx context.Context, items interface{}) error {
+	values, err := sliceItems(items)
+	if err != nil {
+		return err
+	}
+	requests := make([]tableWriteRequest, 0, len(values))
+	for _, item := range values {
+		if err := beforeDelete(ctx, item); err != nil {
+			return err
+		}
+		if softDeleted(item) {
+			return fmt.Errorf("BatchDelete cannot soft delete %T: use Delete, or Purge to remove it", item)
+		}
+		tableName, key, _, err := r.deleteKey(item)
+		if err != nil {
+			return err
+		}
+		id, err := writeKey(key)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, tableWriteRequest{
+			tableName: tableName,
+			request:   types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}},
+			key:       id,
+		})
+	}
+	return r.batchWrite(ctx, requests)
+}
+
+// BatchFindByIDs retrieves the items with the given hash key values using
+// BatchGetItem. ids must be a slice and out a pointer to a slice of structs.
+// Items are returned in the order of ids; ids that do not exist are skipped
+// and duplicate ids are only looked up once. On a repository scoped to a
+// tenant, ids of models with a range key are range key values, and the hash
+// key is the tenant.
+func (r *Repository) BatchFindByIDs(ctx context.Context, ids interface{}, out interface{}, opts ...ReadOption) error {
+	options := r.readOptions(opts)
+	if options.indexName != "" {
+		return fmt.Errorf("items cannot be read by key from index %s", options.indexName)
+	}
+	elemType, err := sliceElemType(out)
+	if err != nil {
+		return err
+	}
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return err
+	}
+	hashKey, rangeKey := meta.hashKey, meta.rangeKey
+	if hashKey == "" {
+		return fmt.Errorf("no hash key defined in struct")
+	}
+	// idKey is the key attribute ids hold, and fixedKey the rest of the key.
+	idKey, fixedKey := hashKey, map[string]types.AttributeValue{}
+	if rangeKey != "" {
+		if options.tenant == "" || meta.tenant == nil {
+			return fmt.Errorf("struct defines range key %s: BatchFindByIDs only supports hash key tables", rangeKey)
+		}
+		idKey = rangeKey
+		fixedKey[hashKey] = &types.AttributeValueMemberS{Value: options.tenant}
+	}
+	tableName := options.table(r, elemType)
+	b := newExpressionBuilder()
+	// The id key is always read to put the items back in the order of ids.
+	projection, err := options.projectionExpression(b, elemType, append([]string{idKey}, options.guardAttributes(meta)...)...)
+	if err != nil {
+		return err
+	}
+	template := types.KeysAndAttributes{
+		ConsistentRead:           options.consistentReadValue(),
+		ProjectionExpression:     projection,
+		ExpressionAttributeNames: b.attributeNames(),
+	}
+
+	idValues := reflect.ValueOf(ids)
+	if idValues.Kind() != reflect.Slice {
+		return fmt.Errorf("ids must be a slice")
+	}
+	keys := make([]map[string]types.AttributeValue, 0, idValues.Len())
+	positions := make(map[string]int, idValues.Len())
+	for i := 0; i < idValues.Len(); i++ {
+		av, err := attributevalue.Marshal(idValues.Index(i).Interface())
+		if err != nil {
+			return fmt.Errorf("failed to marshal key: %w", err)
+		}
+		id, err := keyString(av)
+		if err != nil {
+			return err
+		}
+		if _, ok := positions[id]; ok {
+			continue
+		}
+		positions[id] = len(keys)
+		key := map[string]types.AttributeValue{idKey: av}
+		for name, value := range fixedKey {
+			key[name] = value
+		}
+		keys = append(keys, key)
+	}
+
+	found := make([]map[string]types.AttributeValue, len(keys))
+	var mu sync.Mutex
+	err = r.runChunks(ctx, len(keys), maxBatchGetItems, func(ctx context.Context, start, end int) error {
+		items, err := r.batchGet(ctx, tableName, template, keys[start:end])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			id, err := keyString(item[idKey])
+			if err != nil {
+				return err
+			}
+			if pos, ok := positions[id]; ok {
+				found[pos] = item
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	result := make([]map[string]types.AttributeValue, 0, len(found))
+	for _, item := range found {
+		if item == nil {
+			continue
+		}
+		visible, err := options.visible(meta, item)
+		if err != nil {
+			return err
+		}
+		if visible {
+			result = append(result, item)
+		}
+	}
+	if err := r.openItems(ctx, meta, tableName, result); err != nil {
+		return err
+	}
+	if err := attributevalue.UnmarshalListOfMaps(result, out); err != nil {
+		return fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	return afterFind(ctx, out)
+}
+
+// batchWrite sends requests in chunks of 25. BatchWriteItem rejects a call
+// that writes the same item twice, so requests with the same key are refused
+// before the first chunk is sent.
+func (r *Repository) batchWrite(ctx context.Context, requests []tableWriteRequest) error {
+	seen := make(map[[2]string]int, len(requests))
+	for i, req := range requests {
+		if req.key == "" {
+			continue
+		}
+		id := [2]string{req.tableName, req.key}
+		if j, ok := seen[id]; ok {
+			return fmt.Errorf("items %d and %d have the same key in table %s: a batch cannot write an item twice", j, i, req.tableName)
+		}
+		seen[id] = i
+	}
+	return r.runChunks(ctx, len(requests), maxBatchWriteItems, func(ctx context.Context, start, end int) error {
+		requestItems := make(map[string][]types.WriteRequest)
+		for _, req := range requests[start:end] {
+			requestItems[req.tableName] = append(requestItems[req.tableName], req.request)
+		}
+		return r.batchWriteChunk(ctx, requestItems)
+	})
+}
+
+// batchWriteChunk writes a single chunk, retrying UnprocessedItems with
+// exponential backoff.
+func (r *Repository) batchWriteChunk(ctx context.Context, requestItems map[string][]types.WriteRequest) error {
+	for attempt := 0; ; attempt++ {
+		result, err := r.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to batch write items: %w", err)
+		}
+		if len(result.UnprocessedItems) == 0 {
+			return nil
+		}
+		requestItems = result.UnprocessedItems
+		if attempt >= r.batchMaxRetries {
+			count := 0
+			for _, reqs := range requestItems {
+				count += len(reqs)
+			}
+			return fmt.Errorf("%w: %d write requests after %d retries", ErrUnprocessedItems, count, attempt)
+		}
+		if err := r.batchBackoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// batchGet reads a single chunk of keys, retrying UnprocessedKeys with
+// exponential backoff. template holds the read settings for the keys.
+func (r *Repository) batchGet(ctx context.Context, tableName string, template types.KeysAndAttributes, keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	template.Keys = keys
+	requestItems := map[string]types.KeysAndAttributes{
+		tableName: template,
+	}
+	var items []map[string]types.AttributeValue
+	for attempt := 0; ; attempt++ {
+		result, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to batch get items: %w", err)
+		}
+		items = append(items, result.Responses[tableName]...)
+		if len(result.UnprocessedKeys) == 0 {
+			return items, nil
+		}
+		requestItems = result.UnprocessedKeys
+		if attempt >= r.batchMaxRetries {
+			return nil, fmt.Errorf("%w: %d keys after %d retries", ErrUnprocessedItems, len(requestItems[tableName].Keys), attempt)
+		}
+		if err := r.batchBackoff(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// batchBackoff waits before retry number attempt+1.
+func (r *Repository) batchBackoff(ctx context.Context, attempt int) error {
+	delay := r.batchBaseDelay << attempt
+	if delay > maxBatchBackoff || delay < 0 {
+		delay = maxBatchBackoff
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// runChunks splits n elements into chunks of at most size and calls fn for
+// each chunk, running up to batchConcurrency chunks at once. The first error
+// cancels the remaining chunks and is returned. Chunks that completed, or
+// that were running and complete anyway, are not undone.
+func (r *Repository) runChunks(ctx context.Context, n, size int, fn func(ctx context.Context, start, end int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := r.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for start := 0; start < n && ctx.Err() == nil; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, start, end); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// sliceItems returns the elements of a slice of structs or of pointers to
+// structs. Struct elements are returned as pointers so that TableNamer
+// implementations with pointer receivers are found.
+func sliceItems(items interface{}) ([]interface{}, error) {
+	val := reflect.ValueOf(items)
+	if val.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("items must be a slice")
+	}
+	values := make([]interface{}, val.Len())
+	for i := range values {
+		elem := val.Index(i)
+		if elem.Kind() == reflect.Struct {
+			elem = elem.Addr()
+		}
+		values[i] = elem.Interface()
+	}
+	return values, nil
+}
+
+// itemKey returns a comparable representation of the primary key of item,
+// read from its marshaled attributes av, or an empty string if item declares
+// no key fields.
+func itemKey(item interface{}, av map[string]types.AttributeValue) (string, error) {
+	meta, err := metadataOf(reflect.Indirect(reflect.ValueOf(item)).Type())
+	if err != nil {
+		return "", err
+	}
+	key := make(map[string]types.AttributeValue, len(meta.keyFields))
+	for _, field := range meta.keyFields {
+		if value, ok := av[field.tag.AttributeName]; ok {
+			key[field.tag.AttributeName] = value
+		}
+	}
+	return writeKey(key)
+}
+
+// writeKey returns a comparable representation of the primary key key.
+func writeKey(key map[string]types.AttributeValue) (string, error) {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		s, err := keyString(key[name])
+		if err != nil {
+			return "", err
+		}
+		parts[i] = fmt.Sprintf("%s=%q", name, s)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// keyString returns a comparable representation of a key attribute value.
+func keyString(av types.AttributeValue) (string, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value, nil
+	case *types.AttributeValueMemberN:
+		return "N:" + v.Value, nil
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(v.Value), nil
+	}
+	return "", fmt.Errorf("unsupported key attribute type %T", av)
+}
@@ -0,0 +1,119 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unprocessedClient leaves the first request of every BatchWriteItem call
+// unprocessed until it has been sent failures times.
+type unprocessedClient struct {
+	DynamoDBClient
+	mu       sync.Mutex
+	failures int
+	calls    int
+	written  int
+}
+
+func (c *unprocessedClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	out := &dynamodb.BatchWriteItemOutput{}
+	for table, requests := range params.RequestItems {
+		if len(requests) > maxBatchWriteItems {
+			return nil, errors.New("too many items")
+		}
+		if c.failures > 0 {
+			c.failures--
+			out.UnprocessedItems = map[string][]types.WriteRequest{table: requests[:1]}
+			requests = requests[1:]
+		}
+		c.written += len(requests)
+	}
+	return out, nil
+}
+
+type batchItem struct {
+	ID string `dynamodbav:"id" dynamo:"id,key=hash"`
+}
+
+func TestBatchCreate_RetriesUnprocessedItems(t *testing.T) {
+	client := &unprocessedClient{failures: 2}
+	repo := NewRepository(client, "Items", WithBatchRetry(3, time.Millisecond))
+
+	items := make([]batchItem, 60)
+	for i := range items {
+		items[i].ID = strconv.Itoa(i)
+	}
+	require.NoError(t, repo.BatchCreate(context.Background(), items))
+	assert.Equal(t, 60, client.written)
+	assert.Equal(t, 5, client.calls)
+}
+
+func TestBatchCreate_GivesUpAfterRetries(t *testing.T) {
+	client := &unprocessedClient{failures: 10}
+	repo := NewRepository(client, "Items", WithBatchConcurrency(1), WithBatchRetry(2, time.Millisecond))
+
+	err := repo.BatchCreate(context.Background(), []batchItem{{ID: "a"}})
+	assert.ErrorIs(t, err, ErrUnprocessedItems)
+	assert.Equal(t, 3, client.calls)
+}
+
+func TestWithBatchRetry_IgnoresNegativeValues(t *testing.T) {
+	client := &unprocessedClient{failures: 2}
+	repo := NewRepository(client, "Items", WithBatchConcurrency(1), WithBatchRetry(2, time.Millisecond), WithBatchRetry(-1, -time.Second))
+	assert.Equal(t, 2, repo.batchMaxRetries)
+	assert.Equal(t, time.Millisecond, repo.batchBaseDelay)
+
+	require.NoError(t, repo.BatchCreate(context.Background(), []batchItem{{ID: "a"}}))
+	assert.Equal(t, 3, client.calls)
+}
+
+func TestBatchCreate_RejectsDuplicateKeys(t *testing.T) {
+	client := &unprocessedClient{}
+	repo := NewRepository(client, "Items")
+
+	err := repo.BatchCreate(context.Background(), []batchItem{{ID: "a"}, {ID: "b"}, {ID: "a"}})
+	assert.EqualError(t, err, "items 0 and 2 have the same key in table Items: a batch cannot write an item twice")
+	assert.Zero(t, client.calls)
+}
+
+func TestBatchDelete_RejectsDuplicateKeys(t *testing.T) {
+	client := &unprocessedClient{}
+	repo := NewRepository(client, "Items")
+
+	err := repo.BatchDelete(context.Background(), []string{"a", "a"})
+	assert.ErrorContains(t, err, "items 0 and 1 have the same key")
+	assert.Zero(t, client.calls)
+}
+
+func TestRunChunks(t *testing.T) {
+	repo := NewRepository(nil, "Items", WithBatchConcurrency(3))
+
+	var mu sync.Mutex
+	var chunks [][2]int
+	err := repo.runChunks(context.Background(), 60, 25, func(ctx context.Context, start, end int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks = append(chunks, [2]int{start, end})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][2]int{{0, 25}, {25, 50}, {50, 60}}, chunks)
+
+	errBoom := errors.New("boom")
+	err = repo.runChunks(context.Background(), 60, 25, func(ctx context.Context, start, end int) error {
+		return errBoom
+	})
+	assert.ErrorIs(t, err, errBoom)
+}
@@ -0,0 +1,262 @@
+package dynamodb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrInvalidCursor is matched by every CursorError, so callers can use
+// errors.Is(err, ErrInvalidCursor) to answer with 400 Bad Request.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorError is returned when a pagination cursor is malformed, has been
+// tampered with, or was issued for a different query, table or index.
+type CursorError struct {
+	Reason string
+	Err    error
+}
+
+// Error implements the error interface
+func (e *CursorError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", ErrInvalidCursor, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidCursor, e.Reason)
+}
+
+// Unwrap returns the wrapped error
+func (e *CursorError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidCursor.
+func (e *CursorError) Is(target error) bool {
+	return target == ErrInvalidCursor
+}
+
+// cursorPayload is the signed content of a cursor.
+type cursorPayload struct {
+	Table string                       `json:"t"`
+	Index string                       `json:"i,omitempty"`
+	Key   map[string]map[string]string `json:"k"`
+}
+
+// newCursorSecret returns a random secret for the cursors of a repository
+// created without WithCursorSecret.
+func newCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed to generate cursor secret: %v", err))
+	}
+	return secret
+}
+
+// encodeCursor turns the LastEvaluatedKey of req into an opaque, URL-safe
+// cursor. An empty key (the last page) produces an empty cursor.
+func (r *Repository) encodeCursor(req *readRequest, lastKey map[string]types.AttributeValue) (string, error) {
+	if len(lastKey) == 0 {
+		return "", nil
+	}
+	payload := cursorPayload{Table: req.tableName, Index: req.indexName, Key: make(map[string]map[string]string, len(lastKey))}
+	for name, av := range lastKey {
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			payload.Key[name] = map[string]string{"S": v.Value}
+		case *types.AttributeValueMemberN:
+			payload.Key[name] = map[string]string{"N": v.Value}
+		case *types.AttributeValueMemberB:
+			payload.Key[name] = map[string]string{"B": base64.StdEncoding.EncodeToString(v.Value)}
+		default:
+			return "", fmt.Errorf("unsupported key attribute type %T for %s", av, name)
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(r.signCursor(req, data)), nil
+}
+
+// decodeCursor verifies a cursor issued for req and returns the
+// ExclusiveStartKey it encodes. An empty cursor means the first page and
+// returns a nil key.
+func (r *Repository) decodeCursor(cursor string, req *readRequest) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	encodedPayload, encodedSignature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, &CursorError{Reason: "malformed cursor"}
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, &CursorError{Reason: "malformed cursor", Err: err}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, &CursorError{Reason: "malformed cursor", Err: err}
+	}
+	if !hmac.Equal(signature, r.signCursor(req, data)) {
+		return nil, &CursorError{Reason: "signature mismatch"}
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, &CursorError{Reason: "malformed cursor", Err: err}
+	}
+	if payload.Table != req.tableName || payload.Index != req.indexName {
+		return nil, &CursorError{Reason: "cursor was issued for a different table or index"}
+	}
+	if len(payload.Key) == 0 {
+		return nil, &CursorError{Reason: "cursor has no key"}
+	}
+	key := make(map[string]types.AttributeValue, len(payload.Key))
+	for name, value := range payload.Key {
+		if len(value) != 1 {
+			return nil, &CursorError{Reason: fmt.Sprintf("malformed key attribute %s", name)}
+		}
+		switch {
+		case value["S"] != "":
+			key[name] = &types.AttributeValueMemberS{Value: value["S"]}
+		case value["N"] != "":
+			key[name] = &types.AttributeValueMemberN{Value: value["N"]}
+		case value["B"] != "":
+			b, err := base64.StdEncoding.DecodeString(value["B"])
+			if err != nil {
+				return nil, &CursorError{Reason: fmt.Sprintf("malformed key attribute %s", name), Err: err}
+			}
+			key[name] = &types.AttributeValueMemberB{Value: b}
+		default:
+			return nil, &CursorError{Reason: fmt.Sprintf("malformed key attribute %s", name)}
+		}
+	}
+	return key, nil
+}
+
+// signCursor returns an HMAC of the payload and of the query of req, keyed
+// with the repository's cursor secret. A cursor therefore only verifies for
+// the query, expressions and parameter values it was issued for.
+func (r *Repository) signCursor(req *readRequest, data []byte) []byte {
+	mac := hmac.New(sha256.New, r.cursorSecret)
+	writeQueryIdentity(mac, req)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// writeQueryIdentity writes to h the parts of req that select its items.
+func writeQueryIdentity(h hash.Hash, req *readRequest) {
+	if req.query != nil {
+		q := req.query
+		writeString(h, "query")
+		writeString(h, aws.ToString(q.TableName))
+		writeString(h, aws.ToString(q.IndexName))
+		writeString(h, aws.ToString(q.KeyConditionExpression))
+		writeString(h, aws.ToString(q.FilterExpression))
+		writeString(h, aws.ToString(q.ProjectionExpression))
+		writeString(h, fmt.Sprint(q.ScanIndexForward == nil || *q.ScanIndexForward))
+		writeExpressionValues(h, q.ExpressionAttributeNames, q.ExpressionAttributeValues)
+		return
+	}
+	s := req.scan
+	writeString(h, "scan")
+	writeString(h, aws.ToString(s.TableName))
+	writeString(h, aws.ToString(s.IndexName))
+	writeString(h, aws.ToString(s.FilterExpression))
+	writeString(h, aws.ToString(s.ProjectionExpression))
+	writeExpressionValues(h, s.ExpressionAttributeNames, s.ExpressionAttributeValues)
+}
+
+// writeExpressionValues writes the expression attribute names and values in
+// placeholder order.
+func writeExpressionValues(h hash.Hash, names map[string]string, values map[string]types.AttributeValue) {
+	placeholders := make([]string, 0, len(names))
+	for placeholder := range names {
+		placeholders = append(placeholders, placeholder)
+	}
+	sort.Strings(placeholders)
+	writeString(h, fmt.Sprint(len(placeholders)))
+	for _, placeholder := range placeholders {
+		writeString(h, placeholder)
+		writeString(h, names[placeholder])
+	}
+	placeholders = placeholders[:0]
+	for placeholder := range values {
+		placeholders = append(placeholders, placeholder)
+	}
+	sort.Strings(placeholders)
+	writeString(h, fmt.Sprint(len(placeholders)))
+	for _, placeholder := range placeholders {
+		writeString(h, placeholder)
+		writeAttributeValue(h, values[placeholder])
+	}
+}
+
+// writeAttributeValue writes the type and value of av.
+func writeAttributeValue(h hash.Hash, av types.AttributeValue) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		writeString(h, "S")
+		writeString(h, v.Value)
+	case *types.AttributeValueMemberN:
+		writeString(h, "N")
+		writeString(h, v.Value)
+	case *types.AttributeValueMemberB:
+		writeString(h, "B")
+		writeString(h, string(v.Value))
+	case *types.AttributeValueMemberBOOL:
+		writeString(h, "BOOL")
+		writeString(h, fmt.Sprint(v.Value))
+	case *types.AttributeValueMemberNULL:
+		writeString(h, "NULL")
+	case *types.AttributeValueMemberSS:
+		writeString(h, "SS")
+		writeStrings(h, v.Value)
+	case *types.AttributeValueMemberNS:
+		writeString(h, "NS")
+		writeStrings(h, v.Value)
+	case *types.AttributeValueMemberBS:
+		writeString(h, "BS")
+		writeString(h, fmt.Sprint(len(v.Value)))
+		for _, b := range v.Value {
+			writeString(h, string(b))
+		}
+	case *types.AttributeValueMemberL:
+		writeString(h, "L")
+		writeString(h, fmt.Sprint(len(v.Value)))
+		for _, element := range v.Value {
+			writeAttributeValue(h, element)
+		}
+	case *types.AttributeValueMemberM:
+		writeString(h, "M")
+		writeExpressionValues(h, nil, v.Value)
+	default:
+		writeString(h, fmt.Sprintf("%T", av))
+	}
+}
+
+func writeStrings(h hash.Hash, values []string) {
+	writeString(h, fmt.Sprint(len(values)))
+	for _, value := range values {
+		writeString(h, value)
+	}
+}
+
+// writeString writes s prefixed with its length, so that consecutive strings
+// cannot be confused with one another.
+func writeString(h hash.Hash, s string) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(s)))
+	h.Write(length[:])
+	h.Write([]byte(s))
+}
@@ -0,0 +1,119 @@
+package dynamodb
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emailQuery returns the request of a query on email-index for email.
+func emailQuery(email string) *readRequest {
+	return &readRequest{
+		tableName: "Users",
+		indexName: "email-index",
+		query: &dynamodb.QueryInput{
+			TableName:                 aws.String("Users"),
+			IndexName:                 aws.String("email-index"),
+			KeyConditionExpression:    aws.String("#k = :k"),
+			ExpressionAttributeNames:  map[string]string{"#k": "email"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":k": &types.AttributeValueMemberS{Value: email}},
+		},
+	}
+}
+
+// usersScan returns the request of a scan of table.
+func usersScan(table string) *readRequest {
+	return &readRequest{tableName: table, scan: &dynamodb.ScanInput{TableName: aws.String(table)}}
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	repo := NewRepository(nil, "Users", WithCursorSecret([]byte("secret")))
+	lastKey := map[string]types.AttributeValue{
+		"id":         &types.AttributeValueMemberS{Value: "user-1"},
+		"created_at": &types.AttributeValueMemberN{Value: "1700000000"},
+		"blob":       &types.AttributeValueMemberB{Value: []byte{0xff, 0x00}},
+	}
+
+	cursor, err := repo.encodeCursor(emailQuery("a@example.com"), lastKey)
+	require.NoError(t, err)
+	assert.NotContains(t, cursor, "+")
+	assert.NotContains(t, cursor, "/")
+	assert.NotContains(t, cursor, "=")
+
+	key, err := repo.decodeCursor(cursor, emailQuery("a@example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, lastKey, key)
+
+	// A cursor only verifies for the parameter values it was issued for.
+	_, err = repo.decodeCursor(cursor, emailQuery("b@example.com"))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestCursor_DefaultSecret(t *testing.T) {
+	repo := NewRepository(nil, "Users")
+	lastKey := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "user-1"}}
+	cursor, err := repo.encodeCursor(usersScan("Users"), lastKey)
+	require.NoError(t, err)
+
+	key, err := repo.decodeCursor(cursor, usersScan("Users"))
+	require.NoError(t, err)
+	assert.Equal(t, lastKey, key)
+
+	// Without WithCursorSecret each repository has a random secret, so a
+	// cursor cannot be forged by recomputing a checksum.
+	_, err = NewRepository(nil, "Users").decodeCursor(cursor, usersScan("Users"))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestCursor_EmptyKey(t *testing.T) {
+	repo := NewRepository(nil, "Users")
+
+	cursor, err := repo.encodeCursor(usersScan("Users"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, cursor)
+
+	key, err := repo.decodeCursor("", usersScan("Users"))
+	require.NoError(t, err)
+	assert.Nil(t, key)
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	repo := NewRepository(nil, "Users", WithCursorSecret([]byte("secret")))
+	lastKey := map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "user-1"},
+	}
+	cursor, err := repo.encodeCursor(usersScan("Users"), lastKey)
+	require.NoError(t, err)
+	payload, signature, _ := strings.Cut(cursor, ".")
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"Users","k":{"id":{"S":"admin"}}}`))
+
+	tests := []struct {
+		name   string
+		cursor string
+		table  string
+		repo   *Repository
+	}{
+		{name: "not base64", cursor: "!!!.???", table: "Users", repo: repo},
+		{name: "missing signature", cursor: payload, table: "Users", repo: repo},
+		{name: "forged payload", cursor: forged + "." + signature, table: "Users", repo: repo},
+		{name: "other secret", cursor: cursor, table: "Users", repo: NewRepository(nil, "Users", WithCursorSecret([]byte("other")))},
+		{name: "other table", cursor: cursor, table: "Orders", repo: repo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.repo.decodeCursor(tt.cursor, usersScan(tt.table))
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+
+			var cursorErr *CursorError
+			assert.True(t, errors.As(err, &cursorErr))
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package dynamodbtest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// BatchGetItem reads up to 100 items across tables.
+func (c *Client) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, ka := range params.RequestItems {
+		total += len(ka.Keys)
+	}
+	if total == 0 || total > 100 {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+	for tableName, ka := range params.RequestItems {
+		t, err := c.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		used := map[string]bool{}
+		var paths []path
+		if ka.ProjectionExpression != nil {
+			paths, err = compileProjection(*ka.ProjectionExpression, ka.ExpressionAttributeNames, used)
+			if err != nil {
+				return nil, validationError("Invalid ProjectionExpression: " + err.Error())
+			}
+		}
+		if err := checkUnused(ka.ExpressionAttributeNames, nil, used); err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		results := []map[string]types.AttributeValue{}
+		for _, key := range ka.Keys {
+			if err := t.checkKey(key); err != nil {
+				return nil, err
+			}
+			encoded := encodeKey(key, t.attributes())
+			if seen[encoded] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[encoded] = true
+			if it, ok := t.get(key); ok {
+				results = append(results, project(it, paths))
+			}
+		}
+		out.Responses[tableName] = results
+	}
+	return out, nil
+}
+
+// BatchWriteItem puts or deletes up to 25 items across tables.
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, requests := range params.RequestItems {
+		total += len(requests)
+	}
+	if total == 0 || total > 25 {
+		return nil, validationError("Too many items requested for the BatchWriteItem call")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var applies []func() item
+	for tableName, requests := range params.RequestItems {
+		t, err := c.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, req := range requests {
+			var key item
+			var apply func() item
+			switch {
+			case req.PutRequest != nil:
+				key, err = t.keyOf(req.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				apply, err = c.preparePut(t, req.PutRequest.Item, nil, nil, nil, "")
+			case req.DeleteRequest != nil:
+				key = req.DeleteRequest.Key
+				apply, err = c.prepareDelete(t, key, nil, nil, nil, "")
+			default:
+				return nil, validationError("WriteRequest must contain a PutRequest or a DeleteRequest")
+			}
+			if err != nil {
+				return nil, err
+			}
+			encoded := encodeKey(key, t.attributes())
+			if seen[encoded] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[encoded] = true
+			applies = append(applies, apply)
+		}
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}, nil
+}
+
+// TransactWriteItems applies up to 100 writes atomically. When any
+// condition fails, nothing is written and a TransactionCanceledException
+// lists a reason for every action in request order.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > 100 {
+		return nil, validationError("Member must have length less than or equal to 100")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	type target struct {
+		table string
+		key   string
+	}
+	seen := map[target]bool{}
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	var applies []func()
+	failed := false
+	for i, ti := range params.TransactItems {
+		var (
+			tableName *string
+			key       item
+			apply     func()
+			err       error
+		)
+		switch {
+		case ti.Put != nil:
+			p := ti.Put
+			tableName = p.TableName
+			t, terr := c.table(tableName)
+			if terr != nil {
+				return nil, terr
+			}
+			if key, err = t.keyOf(p.Item); err != nil {
+				return nil, err
+			}
+			var fn func() item
+			fn, err = c.preparePut(t, p.Item, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues, p.ReturnValuesOnConditionCheckFailure)
+			apply = func() { fn() }
+		case ti.Update != nil:
+			u := ti.Update
+			tableName, key = u.TableName, u.Key
+			t, terr := c.table(tableName)
+			if terr != nil {
+				return nil, terr
+			}
+			var fn func() (item, item, []string)
+			fn, err = c.prepareUpdate(t, u.Key, u.UpdateExpression, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, u.ReturnValuesOnConditionCheckFailure)
+			apply = func() { fn() }
+		case ti.Delete != nil:
+			d := ti.Delete
+			tableName, key = d.TableName, d.Key
+			t, terr := c.table(tableName)
+			if terr != nil {
+				return nil, terr
+			}
+			var fn func() item
+			fn, err = c.prepareDelete(t, d.Key, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues, d.ReturnValuesOnConditionCheckFailure)
+			apply = func() { fn() }
+		case ti.ConditionCheck != nil:
+			cc := ti.ConditionCheck
+			tableName, key = cc.TableName, cc.Key
+			t, terr := c.table(tableName)
+			if terr != nil {
+				return nil, terr
+			}
+			err = c.checkCondition(t, cc)
+			apply = func() {}
+		default:
+			return nil, validationError("TransactItem must contain exactly one action")
+		}
+		if key != nil {
+			tgt := target{table: aws.ToString(tableName)}
+			if t, ok := c.tables[tgt.table]; ok {
+				tgt.key = encodeKey(key, t.attributes())
+			}
+			if seen[tgt] {
+				return nil, validationError("Transaction request cannot include multiple operations on one item")
+			}
+			seen[tgt] = true
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		if err != nil {
+			if ccf, ok := err.(*types.ConditionalCheckFailedException); ok {
+				failed = true
+				reasons[i] = types.CancellationReason{
+					Code:    aws.String("ConditionalCheckFailed"),
+					Message: aws.String("The conditional request failed"),
+					Item:    ccf.Item,
+				}
+				continue
+			}
+			return nil, err
+		}
+		applies = append(applies, apply)
+	}
+	if failed {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons %s", reasonCodes(reasons))),
+			CancellationReasons: reasons,
+		}
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (c *Client) checkCondition(t *table, cc *types.ConditionCheck) error {
+	if err := t.checkKey(cc.Key); err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	cond, err := compiledCondition(cc.ConditionExpression, cc.ExpressionAttributeNames, cc.ExpressionAttributeValues, used)
+	if err != nil {
+		return err
+	}
+	if cond == nil {
+		return validationError("ConditionCheck requires a ConditionExpression")
+	}
+	if err := checkUnused(cc.ExpressionAttributeNames, cc.ExpressionAttributeValues, used); err != nil {
+		return err
+	}
+	old, exists := t.get(cc.Key)
+	if !cond(conditionTarget(old, exists)) {
+		return conditionFailed(old, cc.ReturnValuesOnConditionCheckFailure)
+	}
+	return nil
+}
+
+func reasonCodes(reasons []types.CancellationReason) string {
+	codes := "["
+	for i, r := range reasons {
+		if i > 0 {
+			codes += ", "
+		}
+		codes += aws.ToString(r.Code)
+	}
+	return codes + "]"
+}
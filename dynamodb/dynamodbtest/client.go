@@ -0,0 +1,791 @@
+// Package dynamodbtest provides an in-memory implementation of the
+// DynamoDB API used by the dynamodb repository, for tests that run offline.
+//
+// The client stores items per table, evaluates condition, key condition,
+// filter, projection and update expressions with their placeholders, and
+// returns the same error types as the AWS SDK so repository code can be
+// exercised without LocalStack or real AWS.
+package dynamodbtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// Client is an in-memory DynamoDB client. The zero value is not usable; use NewClient.
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+
+	// PageSize caps the number of items a single Query or Scan call
+	// evaluates, simulating the 1 MB response limit. Zero means no cap.
+	PageSize int
+}
+
+// NewClient returns an empty in-memory client.
+func NewClient() *Client {
+	return &Client{tables: map[string]*table{}}
+}
+
+// TableDefinition describes a table to create with CreateTables.
+type TableDefinition struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+	// AttributeTypes maps key attribute names to their scalar type.
+	// Attributes missing from the map default to S.
+	AttributeTypes map[string]types.ScalarAttributeType
+	// GlobalSecondaryIndexes maps index names to their hash key and optional range key.
+	GlobalSecondaryIndexes map[string][2]string
+}
+
+// CreateTables creates the described tables, failing the test on error.
+func (c *Client) CreateTables(t interface{ Fatalf(string, ...any) }, defs ...TableDefinition) {
+	for _, def := range defs {
+		if _, err := c.CreateTable(context.Background(), def.input()); err != nil {
+			t.Fatalf("failed to create table %s: %v", def.Name, err)
+		}
+	}
+}
+
+func (d TableDefinition) input() *dynamodb.CreateTableInput {
+	attributeTypes := map[string]types.ScalarAttributeType{}
+	define := func(name string) {
+		if name == "" {
+			return
+		}
+		if t, ok := d.AttributeTypes[name]; ok {
+			attributeTypes[name] = t
+		} else {
+			attributeTypes[name] = types.ScalarAttributeTypeS
+		}
+	}
+	keySchema := func(hash, rng string) []types.KeySchemaElement {
+		define(hash)
+		define(rng)
+		ks := []types.KeySchemaElement{{AttributeName: aws.String(hash), KeyType: types.KeyTypeHash}}
+		if rng != "" {
+			ks = append(ks, types.KeySchemaElement{AttributeName: aws.String(rng), KeyType: types.KeyTypeRange})
+		}
+		return ks
+	}
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(d.Name),
+		KeySchema:   keySchema(d.HashKey, d.RangeKey),
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	names := make([]string, 0, len(d.GlobalSecondaryIndexes))
+	for name := range d.GlobalSecondaryIndexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		keys := d.GlobalSecondaryIndexes[name]
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  keySchema(keys[0], keys[1]),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+	attrNames := make([]string, 0, len(attributeTypes))
+	for name := range attributeTypes {
+		attrNames = append(attrNames, name)
+	}
+	sort.Strings(attrNames)
+	for _, name := range attrNames {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: attributeTypes[name],
+		})
+	}
+	return input
+}
+
+// Items returns a copy of every item stored in a table.
+func (c *Client) Items(tableName string) []map[string]types.AttributeValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tables[tableName]
+	if !ok {
+		return nil
+	}
+	v, _ := t.view("")
+	out := make([]map[string]types.AttributeValue, len(v.items))
+	for i, it := range v.items {
+		out[i] = cloneItem(it)
+	}
+	return out
+}
+
+func validationError(msg string) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: msg, Fault: smithy.FaultClient}
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: Table: " + aws.ToString(name) + " not found")}
+	}
+	return t, nil
+}
+
+// checkUnused rejects placeholders that no expression referenced, as DynamoDB does.
+func checkUnused(names map[string]string, values map[string]types.AttributeValue, used map[string]bool) error {
+	for k := range names {
+		if !used[k] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {" + k + "}")
+		}
+	}
+	for k := range values {
+		if !used[k] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {" + k + "}")
+		}
+	}
+	return nil
+}
+
+// compiledCondition compiles an optional condition expression.
+func compiledCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, used map[string]bool) (condition, error) {
+	if expr == nil {
+		return nil, nil
+	}
+	if *expr == "" {
+		return nil, validationError("Invalid ConditionExpression: The expression can not be empty;")
+	}
+	cond, err := compileCondition(*expr, names, values, used)
+	if err != nil {
+		return nil, validationError("Invalid ConditionExpression: " + err.Error())
+	}
+	return cond, nil
+}
+
+func conditionFailed(old item, returnOld types.ReturnValuesOnConditionCheckFailure) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnOld == types.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+		err.Item = cloneItem(old)
+	}
+	return err
+}
+
+// CreateTable creates a table. Tables become ACTIVE immediately.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := aws.ToString(params.TableName)
+	if _, ok := c.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+	defined := map[string]bool{}
+	for _, def := range params.AttributeDefinitions {
+		defined[aws.ToString(def.AttributeName)] = true
+	}
+	for _, e := range params.KeySchema {
+		if !defined[aws.ToString(e.AttributeName)] {
+			return nil, validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions")
+		}
+	}
+	desc := types.TableDescription{
+		TableName:            aws.String(name),
+		TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + name),
+		TableStatus:          types.TableStatusActive,
+		CreationDateTime:     aws.Time(time.Now()),
+		AttributeDefinitions: params.AttributeDefinitions,
+		KeySchema:            params.KeySchema,
+		ItemCount:            aws.Int64(0),
+	}
+	if params.BillingMode != "" {
+		desc.BillingModeSummary = &types.BillingModeSummary{BillingMode: params.BillingMode}
+	}
+	if params.ProvisionedThroughput != nil {
+		desc.ProvisionedThroughput = &types.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  params.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: params.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		for _, e := range gsi.KeySchema {
+			if !defined[aws.ToString(e.AttributeName)] {
+				return nil, validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions")
+			}
+		}
+		desc.GlobalSecondaryIndexes = append(desc.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			IndexArn:    aws.String(aws.ToString(desc.TableArn) + "/index/" + aws.ToString(gsi.IndexName)),
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+		})
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		desc.LocalSecondaryIndexes = append(desc.LocalSecondaryIndexes, types.LocalSecondaryIndexDescription{
+			IndexName:  lsi.IndexName,
+			KeySchema:  lsi.KeySchema,
+			Projection: lsi.Projection,
+		})
+	}
+	if params.StreamSpecification != nil {
+		desc.StreamSpecification = params.StreamSpecification
+	}
+	c.tables[name] = newTable(desc)
+	return &dynamodb.CreateTableOutput{TableDescription: &desc}, nil
+}
+
+// DescribeTable returns the description a table was created with.
+func (c *Client) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	desc := t.description
+	desc.ItemCount = aws.Int64(int64(len(t.items)))
+	return &dynamodb.DescribeTableOutput{Table: &desc}, nil
+}
+
+// DeleteTable drops a table and all of its items.
+func (c *Client) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(c.tables, aws.ToString(params.TableName))
+	desc := t.description
+	desc.TableStatus = types.TableStatusDeleting
+	return &dynamodb.DeleteTableOutput{TableDescription: &desc}, nil
+}
+
+// PutItem stores an item, honouring ConditionExpression.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	apply, err := c.preparePut(t, params.Item, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+	old := apply()
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+// preparePut validates a put and returns a function that applies it and returns the replaced item.
+func (c *Client) preparePut(t *table, it item, condExpr *string, names map[string]string, values map[string]types.AttributeValue, returnOld types.ReturnValuesOnConditionCheckFailure) (func() item, error) {
+	key, err := t.keyOf(it)
+	if err != nil {
+		return nil, err
+	}
+	for name, idx := range t.indexes {
+		for _, attr := range idx.attributes() {
+			if v, ok := it[attr]; ok {
+				if err := t.checkKeyType(attr, v); err != nil {
+					return nil, validationError(fmt.Sprintf("One or more parameter values were invalid: Type mismatch for Index Key %s Index: %s", attr, name))
+				}
+			}
+		}
+	}
+	used := map[string]bool{}
+	cond, err := compiledCondition(condExpr, names, values, used)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(names, values, used); err != nil {
+		return nil, err
+	}
+	old, exists := t.get(key)
+	if cond != nil && !cond(conditionTarget(old, exists)) {
+		return nil, conditionFailed(old, returnOld)
+	}
+	stored := cloneItem(it)
+	return func() item {
+		t.put(stored)
+		if exists {
+			return old
+		}
+		return nil
+	}, nil
+}
+
+func conditionTarget(old item, exists bool) item {
+	if !exists {
+		return item{}
+	}
+	return old
+}
+
+// GetItem returns the item with the given primary key.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkKey(params.Key); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	var paths []path
+	if params.ProjectionExpression != nil {
+		paths, err = compileProjection(*params.ProjectionExpression, params.ExpressionAttributeNames, used)
+		if err != nil {
+			return nil, validationError("Invalid ProjectionExpression: " + err.Error())
+		}
+	}
+	if err := checkUnused(params.ExpressionAttributeNames, nil, used); err != nil {
+		return nil, err
+	}
+	out := &dynamodb.GetItemOutput{}
+	if it, ok := t.get(params.Key); ok {
+		out.Item = project(it, paths)
+	}
+	if params.ReturnConsumedCapacity != "" && params.ReturnConsumedCapacity != types.ReturnConsumedCapacityNone {
+		units := 0.5
+		if aws.ToBool(params.ConsistentRead) {
+			units = 1
+		}
+		out.ConsumedCapacity = &types.ConsumedCapacity{TableName: params.TableName, CapacityUnits: aws.Float64(units)}
+	}
+	return out, nil
+}
+
+// DeleteItem removes an item, honouring ConditionExpression.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	apply, err := c.prepareDelete(t, params.Key, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+	old := apply()
+	out := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+func (c *Client) prepareDelete(t *table, key item, condExpr *string, names map[string]string, values map[string]types.AttributeValue, returnOld types.ReturnValuesOnConditionCheckFailure) (func() item, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	cond, err := compiledCondition(condExpr, names, values, used)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(names, values, used); err != nil {
+		return nil, err
+	}
+	old, exists := t.get(key)
+	if cond != nil && !cond(conditionTarget(old, exists)) {
+		return nil, conditionFailed(old, returnOld)
+	}
+	key = cloneItem(key)
+	return func() item {
+		t.remove(key)
+		if exists {
+			return old
+		}
+		return nil
+	}, nil
+}
+
+// UpdateItem applies an update expression, creating the item if it does not exist.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	apply, err := c.prepareUpdate(t, params.Key, params.UpdateExpression, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+	old, updated, changed := apply()
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		if old != nil {
+			out.Attributes = cloneItem(old)
+		}
+	case types.ReturnValueAllNew:
+		out.Attributes = cloneItem(updated)
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = pick(old, changed)
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = pick(updated, changed)
+	}
+	return out, nil
+}
+
+func pick(it item, names []string) item {
+	if it == nil {
+		return nil
+	}
+	out := item{}
+	for _, name := range names {
+		if v, ok := it[name]; ok {
+			out[name] = cloneValue(v)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (c *Client) prepareUpdate(t *table, key item, updateExpr, condExpr *string, names map[string]string, values map[string]types.AttributeValue, returnOld types.ReturnValuesOnConditionCheckFailure) (func() (old, updated item, changed []string), error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	var actions []updateAction
+	var targets []path
+	if updateExpr != nil {
+		var err error
+		actions, targets, err = compileUpdate(*updateExpr, names, values, used)
+		if err != nil {
+			return nil, validationError("Invalid UpdateExpression: " + err.Error())
+		}
+	}
+	for _, target := range targets {
+		for _, name := range t.attributes() {
+			if target[0].name == name {
+				return nil, validationError("One or more parameter values were invalid: Cannot update attribute " + name + ". This attribute is part of the key")
+			}
+		}
+	}
+	cond, err := compiledCondition(condExpr, names, values, used)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnused(names, values, used); err != nil {
+		return nil, err
+	}
+	old, exists := t.get(key)
+	if cond != nil && !cond(conditionTarget(old, exists)) {
+		return nil, conditionFailed(old, returnOld)
+	}
+	updated := cloneItem(old)
+	if !exists {
+		updated = cloneItem(key)
+	}
+	for _, action := range actions {
+		if err := action(updated); err != nil {
+			return nil, validationError(err.Error())
+		}
+	}
+	var changed []string
+	for _, target := range targets {
+		changed = append(changed, target[0].name)
+	}
+	return func() (item, item, []string) {
+		t.put(updated)
+		if !exists {
+			return nil, updated, changed
+		}
+		return old, updated, changed
+	}, nil
+}
+
+// Query reads items from a table or index that match a key condition.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := t.view(aws.ToString(params.IndexName))
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	keyCond, err := compileKeyCondition(*params.KeyConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, used, v.keys)
+	if err != nil {
+		return nil, validationError("Invalid KeyConditionExpression: " + err.Error())
+	}
+	r, err := c.read(v, readParams{
+		keyCond:          keyCond,
+		filterExpr:       params.FilterExpression,
+		projectionExpr:   params.ProjectionExpression,
+		names:            params.ExpressionAttributeNames,
+		values:           params.ExpressionAttributeValues,
+		used:             used,
+		limit:            params.Limit,
+		exclusiveStart:   params.ExclusiveStartKey,
+		backward:         params.ScanIndexForward != nil && !*params.ScanIndexForward,
+		selectCount:      params.Select == types.SelectCount,
+		consumedCapacity: params.ReturnConsumedCapacity,
+		consistentRead:   aws.ToBool(params.ConsistentRead),
+		indexName:        aws.ToString(params.IndexName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            r.items,
+		Count:            r.count,
+		ScannedCount:     r.scanned,
+		LastEvaluatedKey: r.lastKey,
+		ConsumedCapacity: r.capacity(params.TableName),
+	}, nil
+}
+
+// Scan reads every item of a table or index, optionally in parallel segments.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if (params.Segment == nil) != (params.TotalSegments == nil) {
+		return nil, validationError("Segment and TotalSegments must be specified together")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := t.view(aws.ToString(params.IndexName))
+	if err != nil {
+		return nil, err
+	}
+	if params.TotalSegments != nil {
+		total, segment := *params.TotalSegments, *params.Segment
+		if total < 1 || segment < 0 || segment >= total {
+			return nil, validationError("The Segment parameter is out of range")
+		}
+		var kept []item
+		for _, it := range v.items {
+			if v.segmentOf(it, total) == segment {
+				kept = append(kept, it)
+			}
+		}
+		v.items = kept
+	}
+	r, err := c.read(v, readParams{
+		filterExpr:       params.FilterExpression,
+		projectionExpr:   params.ProjectionExpression,
+		names:            params.ExpressionAttributeNames,
+		values:           params.ExpressionAttributeValues,
+		used:             map[string]bool{},
+		limit:            params.Limit,
+		exclusiveStart:   params.ExclusiveStartKey,
+		selectCount:      params.Select == types.SelectCount,
+		consumedCapacity: params.ReturnConsumedCapacity,
+		consistentRead:   aws.ToBool(params.ConsistentRead),
+		indexName:        aws.ToString(params.IndexName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            r.items,
+		Count:            r.count,
+		ScannedCount:     r.scanned,
+		LastEvaluatedKey: r.lastKey,
+		ConsumedCapacity: r.capacity(params.TableName),
+	}, nil
+}
+
+type readParams struct {
+	keyCond          condition
+	filterExpr       *string
+	projectionExpr   *string
+	names            map[string]string
+	values           map[string]types.AttributeValue
+	used             map[string]bool
+	limit            *int32
+	exclusiveStart   item
+	backward         bool
+	selectCount      bool
+	consumedCapacity types.ReturnConsumedCapacity
+	consistentRead   bool
+	indexName        string
+}
+
+type readResult struct {
+	items    []map[string]types.AttributeValue
+	count    int32
+	scanned  int32
+	lastKey  item
+	units    float64
+	reported bool
+}
+
+func (r *readResult) capacity(tableName *string) *types.ConsumedCapacity {
+	if !r.reported {
+		return nil
+	}
+	return &types.ConsumedCapacity{TableName: tableName, CapacityUnits: aws.Float64(r.units)}
+}
+
+// read walks a view applying key condition, pagination, filter and projection.
+func (c *Client) read(v *view, p readParams) (*readResult, error) {
+	if p.consistentRead && p.indexName != "" {
+		if _, ok := v.table.indexes[p.indexName]; ok && isGlobal(v.table, p.indexName) {
+			return nil, validationError("Consistent reads are not supported on global secondary indexes")
+		}
+	}
+	var filter condition
+	if p.filterExpr != nil {
+		var err error
+		filter, err = compileCondition(*p.filterExpr, p.names, p.values, p.used)
+		if err != nil {
+			return nil, validationError("Invalid FilterExpression: " + err.Error())
+		}
+	}
+	var paths []path
+	if p.projectionExpr != nil {
+		var err error
+		paths, err = compileProjection(*p.projectionExpr, p.names, p.used)
+		if err != nil {
+			return nil, validationError("Invalid ProjectionExpression: " + err.Error())
+		}
+	}
+	if err := checkUnused(p.names, p.values, p.used); err != nil {
+		return nil, err
+	}
+	if p.limit != nil && *p.limit < 1 {
+		return nil, validationError("Limit must be greater than or equal to 1")
+	}
+
+	candidates := v.items
+	if p.keyCond != nil {
+		var matched []item
+		for _, it := range candidates {
+			if p.keyCond(it) {
+				matched = append(matched, it)
+			}
+		}
+		candidates = matched
+	}
+	if p.backward {
+		reversed := make([]item, len(candidates))
+		for i, it := range candidates {
+			reversed[len(candidates)-1-i] = it
+		}
+		candidates = reversed
+	}
+	if p.exclusiveStart != nil {
+		start := 0
+		for start < len(candidates) {
+			c := v.compare(candidates[start], p.exclusiveStart)
+			if (!p.backward && c > 0) || (p.backward && c < 0) {
+				break
+			}
+			start++
+		}
+		candidates = candidates[start:]
+	}
+
+	max := len(candidates)
+	if p.limit != nil && int(*p.limit) < max {
+		max = int(*p.limit)
+	}
+	if c.PageSize > 0 && c.PageSize < max {
+		max = c.PageSize
+	}
+	r := &readResult{items: []map[string]types.AttributeValue{}}
+	for _, it := range candidates[:max] {
+		r.scanned++
+		if filter != nil && !filter(it) {
+			continue
+		}
+		r.count++
+		if !p.selectCount {
+			r.items = append(r.items, project(indexProjection(v, p.indexName, it), paths))
+		}
+	}
+	limited := p.limit != nil && max == int(*p.limit)
+	if max > 0 && (max < len(candidates) || limited) {
+		r.lastKey = v.lastKey(candidates[max-1])
+	}
+	if p.selectCount {
+		r.items = nil
+	}
+	if p.consumedCapacity != "" && p.consumedCapacity != types.ReturnConsumedCapacityNone {
+		r.reported = true
+		r.units = float64(r.scanned) * 0.5
+		if p.consistentRead {
+			r.units = float64(r.scanned)
+		}
+		if r.units == 0 {
+			r.units = 0.5
+		}
+	}
+	return r, nil
+}
+
+func isGlobal(t *table, indexName string) bool {
+	for _, gsi := range t.description.GlobalSecondaryIndexes {
+		if aws.ToString(gsi.IndexName) == indexName {
+			return true
+		}
+	}
+	return false
+}
+
+// indexProjection trims an item to the attributes projected into an index.
+func indexProjection(v *view, indexName string, it item) item {
+	if indexName == "" {
+		return it
+	}
+	idx := v.table.indexes[indexName]
+	switch idx.projection.ProjectionType {
+	case types.ProjectionTypeKeysOnly, types.ProjectionTypeInclude:
+		out := item{}
+		for _, name := range v.order {
+			out[name] = it[name]
+		}
+		if idx.projection.ProjectionType == types.ProjectionTypeInclude {
+			for _, name := range idx.projection.NonKeyAttributes {
+				if val, ok := it[name]; ok {
+					out[name] = val
+				}
+			}
+		}
+		return out
+	}
+	return it
+}
@@ -0,0 +1,209 @@
+package dynamodbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+
+func newOrdersClient(t *testing.T) *Client {
+	client := NewClient()
+	client.CreateTables(t, TableDefinition{
+		Name:                   "Orders",
+		HashKey:                "user_id",
+		RangeKey:               "order_id",
+		AttributeTypes:         map[string]types.ScalarAttributeType{"order_id": types.ScalarAttributeTypeN},
+		GlobalSecondaryIndexes: map[string][2]string{"status-index": {"status"}},
+	})
+	for _, it := range []map[string]types.AttributeValue{
+		{"user_id": s("u1"), "order_id": n("1"), "status": s("new"), "total": n("100")},
+		{"user_id": s("u1"), "order_id": n("2"), "status": s("paid"), "total": n("250")},
+		{"user_id": s("u1"), "order_id": n("10"), "status": s("paid"), "total": n("50")},
+		{"user_id": s("u2"), "order_id": n("1"), "status": s("paid"), "total": n("75")},
+	} {
+		_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("Orders"), Item: it})
+		require.NoError(t, err)
+	}
+	return client
+}
+
+func orderIDs(items []map[string]types.AttributeValue) []string {
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it["user_id"].(*types.AttributeValueMemberS).Value + "/" + it["order_id"].(*types.AttributeValueMemberN).Value
+	}
+	return ids
+}
+
+func TestClient_ConditionExpression(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+	key := map[string]types.AttributeValue{"user_id": s("u1"), "order_id": n("1")}
+
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String("Orders"),
+		Item:                     key,
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]string{"#k": "user_id"},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	require.ErrorAs(t, err, &ccf)
+	assert.Nil(t, ccf.Item)
+
+	_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                           aws.String("Orders"),
+		Key:                                 key,
+		ConditionExpression:                 aws.String("total > :min AND #s IN (:a, :b)"),
+		ExpressionAttributeNames:            map[string]string{"#s": "status"},
+		ExpressionAttributeValues:           map[string]types.AttributeValue{":min": n("100"), ":a": s("new"), ":b": s("paid")},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	require.ErrorAs(t, err, &ccf)
+	assert.Equal(t, n("100"), ccf.Item["total"], "the old item is returned on request")
+
+	_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String("Orders"),
+		Key:                       key,
+		ConditionExpression:       aws.String("attribute_exists(user_id) AND total >= :min"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":min": n("100")},
+	})
+	require.NoError(t, err)
+	assert.Len(t, client.Items("Orders"), 3)
+}
+
+func TestClient_Query(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+
+	out, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String("Orders"),
+		KeyConditionExpression:    aws.String("user_id = :u AND order_id BETWEEN :lo AND :hi"),
+		FilterExpression:          aws.String("#s = :paid"),
+		ExpressionAttributeNames:  map[string]string{"#s": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":u": s("u1"), ":lo": n("1"), ":hi": n("10"), ":paid": s("paid")},
+		ScanIndexForward:          aws.Bool(false),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u1/10", "u1/2"}, orderIDs(out.Items), "numeric range keys sort as numbers")
+	assert.Equal(t, int32(3), out.ScannedCount)
+
+	out, err = client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String("Orders"),
+		IndexName:                 aws.String("status-index"),
+		KeyConditionExpression:    aws.String("#s = :paid"),
+		ExpressionAttributeNames:  map[string]string{"#s": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":paid": s("paid")},
+	})
+	require.NoError(t, err)
+	assert.Len(t, out.Items, 3)
+
+	_, err = client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String("Orders"),
+		KeyConditionExpression: aws.String("total = :t"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":t": n("100"),
+		},
+	})
+	assert.Error(t, err, "non-key attributes cannot be used in a key condition")
+}
+
+func TestClient_Pagination(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+	client.PageSize = 2
+
+	var ids []string
+	var startKey map[string]types.AttributeValue
+	for calls := 0; ; calls++ {
+		require.Less(t, calls, 5)
+		out, err := client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("Orders"), ExclusiveStartKey: startKey})
+		require.NoError(t, err)
+		ids = append(ids, orderIDs(out.Items)...)
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+	}
+	assert.ElementsMatch(t, []string{"u1/1", "u1/2", "u1/10", "u2/1"}, ids)
+}
+
+func TestClient_Validation(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String("Orders"),
+		Item:                      map[string]types.AttributeValue{"user_id": s("u3"), "order_id": n("1")},
+		ConditionExpression:       aws.String("attribute_not_exists(user_id)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":unused": s("x")},
+	})
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "ValidationException", apiErr.ErrorCode())
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("Orders"),
+		Item:      map[string]types.AttributeValue{"user_id": s("u3"), "order_id": s("not a number")},
+	})
+	require.ErrorAs(t, err, &apiErr)
+
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("Missing"),
+		Key:       map[string]types.AttributeValue{"id": s("1")},
+	})
+	var notFound *types.ResourceNotFoundException
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestClient_UpdateItem(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+
+	out, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("Orders"),
+		Key:                       map[string]types.AttributeValue{"user_id": s("u1"), "order_id": n("1")},
+		UpdateExpression:          aws.String("SET #s = :s, tags = list_append(if_not_exists(tags, :empty), :tags) ADD total :inc REMOVE note"),
+		ExpressionAttributeNames:  map[string]string{"#s": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":s": s("paid"), ":inc": n("5"), ":empty": &types.AttributeValueMemberL{}, ":tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("gift")}}},
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, s("paid"), out.Attributes["status"])
+	assert.Equal(t, n("105"), out.Attributes["total"])
+	assert.Equal(t, &types.AttributeValueMemberL{Value: []types.AttributeValue{s("gift")}}, out.Attributes["tags"])
+}
+
+func TestClient_TransactWriteItems(t *testing.T) {
+	ctx := context.Background()
+	client := newOrdersClient(t)
+
+	_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName: aws.String("Orders"),
+				Item:      map[string]types.AttributeValue{"user_id": s("u3"), "order_id": n("1")},
+			}},
+			{ConditionCheck: &types.ConditionCheck{
+				TableName:                 aws.String("Orders"),
+				Key:                       map[string]types.AttributeValue{"user_id": s("u2"), "order_id": n("1")},
+				ConditionExpression:       aws.String("total > :t"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":t": n("100")},
+			}},
+		},
+	})
+	var tce *types.TransactionCanceledException
+	require.ErrorAs(t, err, &tce)
+	require.Len(t, tce.CancellationReasons, 2)
+	assert.Equal(t, "None", aws.ToString(tce.CancellationReasons[0].Code))
+	assert.Equal(t, "ConditionalCheckFailed", aws.ToString(tce.CancellationReasons[1].Code))
+	assert.Len(t, client.Items("Orders"), 4, "nothing is written when a transaction is cancelled")
+}
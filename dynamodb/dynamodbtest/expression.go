@@ -0,0 +1,869 @@
+package dynamodbtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// item is a single stored DynamoDB item.
+type item = map[string]types.AttributeValue
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName
+	tokValue
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits an expression into tokens.
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at offset %d", i)
+			}
+			kind := tokName
+			if c == ':' {
+				kind = tokValue
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:j])})
+			i = j
+		case isIdentRune(c):
+			j := i
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "<>" || two == "<=" || two == ">=" {
+					tokens = append(tokens, token{kind: tokPunct, text: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("()[],.=<>+-", c) {
+				return nil, fmt.Errorf("unexpected character %q in expression", c)
+			}
+			tokens = append(tokens, token{kind: tokPunct, text: string(c)})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// pathElem is one step of a document path: a map key or a list index.
+type pathElem struct {
+	name  string
+	index int
+	isIdx bool
+}
+
+type path []pathElem
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		if e.isIdx {
+			fmt.Fprintf(&b, "[%d]", e.index)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(e.name)
+	}
+	return b.String()
+}
+
+// parser turns tokens into evaluable closures, resolving placeholders as it goes.
+type parser struct {
+	tokens []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+	used   map[string]bool
+	// paths lists every attribute path the expression refers to.
+	paths []path
+}
+
+func newParser(expr string, names map[string]string, values map[string]types.AttributeValue, used map[string]bool) (*parser, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, names: names, values: values, used: used}, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	t := p.next()
+	if t.kind != tokPunct || t.text != s {
+		return fmt.Errorf("expected %q, got %q", s, t.text)
+	}
+	return nil
+}
+
+func (p *parser) done() error {
+	if t := p.peek(); t.kind != tokEOF {
+		return fmt.Errorf("unexpected token %q", t.text)
+	}
+	return nil
+}
+
+func (p *parser) parsePath() (path, error) {
+	t := p.next()
+	var first string
+	switch t.kind {
+	case tokName:
+		name, ok := p.names[t.text]
+		if !ok {
+			return nil, fmt.Errorf("undefined expression attribute name %s", t.text)
+		}
+		p.used[t.text] = true
+		first = name
+	case tokIdent:
+		first = t.text
+	default:
+		return nil, fmt.Errorf("expected attribute path, got %q", t.text)
+	}
+	result := path{{name: first}}
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			t := p.next()
+			switch t.kind {
+			case tokName:
+				name, ok := p.names[t.text]
+				if !ok {
+					return nil, fmt.Errorf("undefined expression attribute name %s", t.text)
+				}
+				p.used[t.text] = true
+				result = append(result, pathElem{name: name})
+			case tokIdent:
+				result = append(result, pathElem{name: t.text})
+			default:
+				return nil, fmt.Errorf("expected attribute name after '.', got %q", t.text)
+			}
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokNumber {
+				return nil, fmt.Errorf("expected list index, got %q", t.text)
+			}
+			n, _ := strconv.Atoi(t.text)
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElem{index: n, isIdx: true})
+		default:
+			p.paths = append(p.paths, result)
+			return result, nil
+		}
+	}
+}
+
+func (p *parser) parseValueRef() (types.AttributeValue, error) {
+	t := p.next()
+	v, ok := p.values[t.text]
+	if !ok {
+		return nil, fmt.Errorf("undefined expression attribute value %s", t.text)
+	}
+	p.used[t.text] = true
+	return v, nil
+}
+
+// operand evaluates to an attribute value, or reports that it does not exist.
+type operand func(it item) (types.AttributeValue, bool)
+
+// condition evaluates a boolean expression against an item.
+type condition func(it item) bool
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValue:
+		v, err := p.parseValueRef()
+		if err != nil {
+			return nil, err
+		}
+		return func(item) (types.AttributeValue, bool) { return v, true }, nil
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].text == "(":
+		p.next()
+		p.next()
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool) {
+			v, ok := getPath(it, pth)
+			if !ok {
+				return nil, false
+			}
+			n, ok := sizeOf(v)
+			if !ok {
+				return nil, false
+			}
+			return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true
+		}, nil
+	default:
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool) { return getPath(it, pth) }, nil
+	}
+}
+
+func (p *parser) parseCondition() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(it item) bool { return l(it) || r(it) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(it item) bool { return l(it) && r(it) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) bool { return !inner(it) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		c, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" && !strings.EqualFold(t.text, "size") {
+		return p.parseFunction()
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		lo, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, fmt.Errorf("expected AND in BETWEEN")
+		}
+		p.next()
+		hi, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) bool {
+			v, ok1 := left(it)
+			l, ok2 := lo(it)
+			h, ok3 := hi(it)
+			if !ok1 || !ok2 || !ok3 {
+				return false
+			}
+			c1, ok1 := compare(v, l)
+			c2, ok2 := compare(v, h)
+			return ok1 && ok2 && c1 >= 0 && c2 <= 0
+		}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, o)
+			if p.isPunct(",") {
+				p.next()
+				continue
+			}
+			break
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) bool {
+			v, ok := left(it)
+			if !ok {
+				return false
+			}
+			for _, c := range candidates {
+				if cv, ok := c(it); ok && equal(v, cv) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	op := p.next()
+	if op.kind != tokPunct {
+		return nil, fmt.Errorf("expected comparator, got %q", op.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "=":
+		return func(it item) bool {
+			l, ok1 := left(it)
+			r, ok2 := right(it)
+			return ok1 && ok2 && equal(l, r)
+		}, nil
+	case "<>":
+		return func(it item) bool {
+			l, ok1 := left(it)
+			r, ok2 := right(it)
+			return ok1 && ok2 && !equal(l, r)
+		}, nil
+	case "<", "<=", ">", ">=":
+		cmpOp := op.text
+		return func(it item) bool {
+			l, ok1 := left(it)
+			r, ok2 := right(it)
+			if !ok1 || !ok2 {
+				return false
+			}
+			c, ok := compare(l, r)
+			if !ok {
+				return false
+			}
+			switch cmpOp {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			default:
+				return c >= 0
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported comparator %q", op.text)
+}
+
+func (p *parser) parseFunction() (condition, error) {
+	name := strings.ToLower(p.next().text)
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	pth, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	var c condition
+	switch name {
+	case "attribute_exists":
+		c = func(it item) bool { _, ok := getPath(it, pth); return ok }
+	case "attribute_not_exists":
+		c = func(it item) bool { _, ok := getPath(it, pth); return !ok }
+	case "attribute_type", "begins_with", "contains":
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "attribute_type":
+			c = func(it item) bool {
+				v, ok := getPath(it, pth)
+				t, ok2 := arg(it)
+				s, ok3 := t.(*types.AttributeValueMemberS)
+				return ok && ok2 && ok3 && typeName(v) == s.Value
+			}
+		case "begins_with":
+			c = func(it item) bool {
+				v, ok := getPath(it, pth)
+				prefix, ok2 := arg(it)
+				if !ok || !ok2 {
+					return false
+				}
+				switch v := v.(type) {
+				case *types.AttributeValueMemberS:
+					s, ok := prefix.(*types.AttributeValueMemberS)
+					return ok && strings.HasPrefix(v.Value, s.Value)
+				case *types.AttributeValueMemberB:
+					b, ok := prefix.(*types.AttributeValueMemberB)
+					return ok && bytes.HasPrefix(v.Value, b.Value)
+				}
+				return false
+			}
+		case "contains":
+			c = func(it item) bool {
+				v, ok := getPath(it, pth)
+				operand, ok2 := arg(it)
+				if !ok || !ok2 {
+					return false
+				}
+				return contains(v, operand)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported function %s", name)
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compileCondition parses a condition, key condition or filter expression.
+func compileCondition(expr string, names map[string]string, values map[string]types.AttributeValue, used map[string]bool) (condition, error) {
+	p, err := newParser(expr, names, values, used)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.done(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compileKeyCondition parses a key condition expression, which may only refer
+// to the key attributes of keys and must include the hash key.
+func compileKeyCondition(expr string, names map[string]string, values map[string]types.AttributeValue, used map[string]bool, keys keySchema) (condition, error) {
+	p, err := newParser(expr, names, values, used)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.done(); err != nil {
+		return nil, err
+	}
+	hasHashKey := false
+	for _, ref := range p.paths {
+		name := ref.String()
+		switch {
+		case name == keys.hashKey:
+			hasHashKey = true
+		case name == keys.rangeKey:
+		default:
+			return nil, fmt.Errorf("query key condition not supported: %s is not a key attribute", name)
+		}
+	}
+	if !hasHashKey {
+		return nil, fmt.Errorf("query condition missed key schema element: %s", keys.hashKey)
+	}
+	return c, nil
+}
+
+// compileProjection parses a projection expression into a list of paths.
+func compileProjection(expr string, names map[string]string, used map[string]bool) ([]path, error) {
+	p, err := newParser(expr, names, nil, used)
+	if err != nil {
+		return nil, err
+	}
+	var paths []path
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, pth)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		break
+	}
+	if err := p.done(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func typeName(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	}
+	return ""
+}
+
+func sizeOf(v types.AttributeValue) (int, bool) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	}
+	return 0, false
+}
+
+func contains(v, operand types.AttributeValue) bool {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		s, ok := operand.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(v.Value, s.Value)
+	case *types.AttributeValueMemberSS:
+		s, ok := operand.(*types.AttributeValueMemberS)
+		if !ok {
+			return false
+		}
+		for _, e := range v.Value {
+			if e == s.Value {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberNS:
+		for _, e := range v.Value {
+			if equal(&types.AttributeValueMemberN{Value: e}, operand) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberBS:
+		b, ok := operand.(*types.AttributeValueMemberB)
+		if !ok {
+			return false
+		}
+		for _, e := range v.Value {
+			if bytes.Equal(e, b.Value) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberL:
+		for _, e := range v.Value {
+			if equal(e, operand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseNumber(s string) (*big.Float, error) {
+	f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+func formatNumber(f *big.Float) string {
+	return f.Text('f', -1)
+}
+
+// compare orders two scalar attribute values of the same type.
+func compare(a, b types.AttributeValue) (int, bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		b, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a.Value, b.Value), true
+	case *types.AttributeValueMemberN:
+		b, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, false
+		}
+		x, err1 := parseNumber(a.Value)
+		y, err2 := parseNumber(b.Value)
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	case *types.AttributeValueMemberB:
+		b, ok := b.(*types.AttributeValueMemberB)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(a.Value, b.Value), true
+	}
+	return 0, false
+}
+
+// equal reports whether two attribute values are the same.
+func equal(a, b types.AttributeValue) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	switch a := a.(type) {
+	case *types.AttributeValueMemberBOOL:
+		b, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && a.Value == b.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberL:
+		b, ok := b.(*types.AttributeValueMemberL)
+		if !ok || len(a.Value) != len(b.Value) {
+			return false
+		}
+		for i := range a.Value {
+			if !equal(a.Value[i], b.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		b, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(a.Value) != len(b.Value) {
+			return false
+		}
+		for k, v := range a.Value {
+			w, ok := b.Value[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberSS:
+		b, ok := b.(*types.AttributeValueMemberSS)
+		return ok && sameSet(a.Value, b.Value, func(x, y string) bool { return x == y })
+	case *types.AttributeValueMemberNS:
+		b, ok := b.(*types.AttributeValueMemberNS)
+		return ok && sameSet(a.Value, b.Value, func(x, y string) bool {
+			return equal(&types.AttributeValueMemberN{Value: x}, &types.AttributeValueMemberN{Value: y})
+		})
+	case *types.AttributeValueMemberBS:
+		b, ok := b.(*types.AttributeValueMemberBS)
+		return ok && sameSet(a.Value, b.Value, bytes.Equal)
+	}
+	return false
+}
+
+func sameSet[E any](a, b []E, eq func(x, y E) bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if eq(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// getPath resolves a document path inside an item.
+func getPath(it item, p path) (types.AttributeValue, bool) {
+	v, ok := it[p[0].name]
+	if !ok {
+		return nil, false
+	}
+	for _, e := range p[1:] {
+		switch cur := v.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIdx {
+				return nil, false
+			}
+			v, ok = cur.Value[e.name]
+			if !ok {
+				return nil, false
+			}
+		case *types.AttributeValueMemberL:
+			if !e.isIdx || e.index >= len(cur.Value) {
+				return nil, false
+			}
+			v = cur.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// setPath writes a value at a document path, creating nothing but the final element.
+func setPath(it item, p path, v types.AttributeValue) error {
+	if len(p) == 1 {
+		it[p[0].name] = v
+		return nil
+	}
+	parent, ok := getPath(it, p[:len(p)-1])
+	if !ok {
+		return fmt.Errorf("the document path provided in the update expression is invalid for update")
+	}
+	last := p[len(p)-1]
+	switch cur := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.isIdx {
+			return fmt.Errorf("the document path provided in the update expression is invalid for update")
+		}
+		cur.Value[last.name] = v
+	case *types.AttributeValueMemberL:
+		if !last.isIdx {
+			return fmt.Errorf("the document path provided in the update expression is invalid for update")
+		}
+		if last.index >= len(cur.Value) {
+			cur.Value = append(cur.Value, v)
+		} else {
+			cur.Value[last.index] = v
+		}
+	default:
+		return fmt.Errorf("the document path provided in the update expression is invalid for update")
+	}
+	return nil
+}
+
+// removePath deletes the value at a document path if it exists.
+func removePath(it item, p path) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+	parent, ok := getPath(it, p[:len(p)-1])
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch cur := parent.(type) {
+	case *types.AttributeValueMemberM:
+		delete(cur.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.isIdx && last.index < len(cur.Value) {
+			cur.Value = append(cur.Value[:last.index], cur.Value[last.index+1:]...)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package dynamodbtest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// keySchema names the partition and sort key attributes of a table or index.
+type keySchema struct {
+	hashKey  string
+	rangeKey string
+}
+
+func newKeySchema(elements []types.KeySchemaElement) keySchema {
+	var ks keySchema
+	for _, e := range elements {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			ks.hashKey = aws.ToString(e.AttributeName)
+		case types.KeyTypeRange:
+			ks.rangeKey = aws.ToString(e.AttributeName)
+		}
+	}
+	return ks
+}
+
+func (ks keySchema) attributes() []string {
+	if ks.rangeKey == "" {
+		return []string{ks.hashKey}
+	}
+	return []string{ks.hashKey, ks.rangeKey}
+}
+
+// index is a global or local secondary index.
+type index struct {
+	keySchema
+	projection types.Projection
+}
+
+// table stores the items and schema of one table.
+type table struct {
+	description types.TableDescription
+	keySchema
+	attributeTypes map[string]types.ScalarAttributeType
+	indexes        map[string]*index
+	items          map[string]item
+}
+
+func newTable(desc types.TableDescription) *table {
+	t := &table{
+		description:    desc,
+		keySchema:      newKeySchema(desc.KeySchema),
+		attributeTypes: map[string]types.ScalarAttributeType{},
+		indexes:        map[string]*index{},
+		items:          map[string]item{},
+	}
+	for _, def := range desc.AttributeDefinitions {
+		t.attributeTypes[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+	for _, gsi := range desc.GlobalSecondaryIndexes {
+		idx := &index{keySchema: newKeySchema(gsi.KeySchema)}
+		if gsi.Projection != nil {
+			idx.projection = *gsi.Projection
+		}
+		t.indexes[aws.ToString(gsi.IndexName)] = idx
+	}
+	for _, lsi := range desc.LocalSecondaryIndexes {
+		idx := &index{keySchema: newKeySchema(lsi.KeySchema)}
+		if lsi.Projection != nil {
+			idx.projection = *lsi.Projection
+		}
+		t.indexes[aws.ToString(lsi.IndexName)] = idx
+	}
+	return t
+}
+
+// keyOf extracts and validates the primary key of an item.
+func (t *table) keyOf(it item) (item, error) {
+	key := item{}
+	for _, name := range t.attributes() {
+		v, ok := it[name]
+		if !ok {
+			return nil, validationError("One of the required keys was not given a value")
+		}
+		if err := t.checkKeyType(name, v); err != nil {
+			return nil, err
+		}
+		key[name] = v
+	}
+	return key, nil
+}
+
+// checkKey validates that key holds exactly the primary key attributes.
+func (t *table) checkKey(key item) error {
+	if len(key) != len(t.attributes()) {
+		return validationError("The provided key element does not match the schema")
+	}
+	_, err := t.keyOf(key)
+	return err
+}
+
+func (t *table) checkKeyType(name string, v types.AttributeValue) error {
+	want, ok := t.attributeTypes[name]
+	if !ok {
+		return nil
+	}
+	if typeName(v) != string(want) {
+		return validationError(fmt.Sprintf("Type mismatch for key %s expected: %s actual: %s", name, want, typeName(v)))
+	}
+	if s, ok := v.(*types.AttributeValueMemberS); ok && s.Value == "" {
+		return validationError(fmt.Sprintf("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name))
+	}
+	return nil
+}
+
+func encodeKey(key item, names []string) string {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = encodeScalar(key[name])
+	}
+	return strings.Join(parts, "|")
+}
+
+func encodeScalar(v types.AttributeValue) string {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value
+	case *types.AttributeValueMemberN:
+		if f, err := parseNumber(v.Value); err == nil {
+			return "N:" + formatNumber(f)
+		}
+		return "N:" + v.Value
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(v.Value)
+	}
+	return "?"
+}
+
+func (t *table) get(key item) (item, bool) {
+	it, ok := t.items[encodeKey(key, t.attributes())]
+	return it, ok
+}
+
+func (t *table) put(it item) {
+	key, _ := t.keyOf(it)
+	t.items[encodeKey(key, t.attributes())] = it
+}
+
+func (t *table) remove(key item) {
+	delete(t.items, encodeKey(key, t.attributes()))
+}
+
+// view is an ordered projection of a table or one of its indexes.
+type view struct {
+	table *table
+	keys  keySchema
+	// order lists the attributes used to sort items and to build LastEvaluatedKey.
+	order []string
+	items []item
+}
+
+// view returns the items visible through the table or the named index, in key order.
+func (t *table) view(indexName string) (*view, error) {
+	v := &view{table: t, keys: t.keySchema}
+	if indexName != "" {
+		idx, ok := t.indexes[indexName]
+		if !ok {
+			return nil, validationError(fmt.Sprintf("The table does not have the specified index: %s", indexName))
+		}
+		v.keys = idx.keySchema
+	}
+	v.order = append(v.order, v.keys.attributes()...)
+	for _, name := range t.attributes() {
+		if name != v.keys.hashKey && name != v.keys.rangeKey {
+			v.order = append(v.order, name)
+		}
+	}
+	for _, it := range t.items {
+		visible := true
+		for _, name := range v.keys.attributes() {
+			if _, ok := it[name]; !ok {
+				visible = false
+			}
+		}
+		if visible {
+			v.items = append(v.items, it)
+		}
+	}
+	sort.Slice(v.items, func(i, j int) bool { return v.compare(v.items[i], v.items[j]) < 0 })
+	return v, nil
+}
+
+// compare orders two items by the view's hash key, range key and then the table key.
+func (v *view) compare(a, b item) int {
+	for _, name := range v.order {
+		x, y := a[name], b[name]
+		if name == v.keys.hashKey {
+			// Partitions are not ordered by DynamoDB; any stable order will do.
+			if c := strings.Compare(encodeScalar(x), encodeScalar(y)); c != 0 {
+				return c
+			}
+			continue
+		}
+		if c, ok := compare(x, y); ok && c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// lastKey builds the LastEvaluatedKey for an item read through the view.
+func (v *view) lastKey(it item) item {
+	key := item{}
+	for _, name := range v.order {
+		key[name] = cloneValue(it[name])
+	}
+	return key
+}
+
+// segmentOf assigns a partition to one of totalSegments parallel scan segments.
+func (v *view) segmentOf(it item, totalSegments int32) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(encodeScalar(it[v.table.hashKey])))
+	return int32(h.Sum32() % uint32(totalSegments))
+}
+
+// project applies an index projection and a projection expression to an item.
+func project(it item, paths []path) item {
+	if len(paths) == 0 {
+		return cloneItem(it)
+	}
+	out := item{}
+	for _, p := range paths {
+		v, ok := getPath(it, p)
+		if !ok {
+			continue
+		}
+		if len(p) == 1 {
+			out[p[0].name] = cloneValue(v)
+			continue
+		}
+		// Nested paths keep their enclosing document structure.
+		top, ok := out[p[0].name]
+		if !ok {
+			top = emptyLike(it[p[0].name])
+			out[p[0].name] = top
+		}
+		copyNested(top, it[p[0].name], p[1:])
+	}
+	return out
+}
+
+func emptyLike(v types.AttributeValue) types.AttributeValue {
+	switch v.(type) {
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: item{}}
+	case *types.AttributeValueMemberL:
+		return &types.AttributeValueMemberL{}
+	}
+	return cloneValue(v)
+}
+
+func copyNested(dst, src types.AttributeValue, p path) {
+	if len(p) == 0 {
+		return
+	}
+	e := p[0]
+	switch s := src.(type) {
+	case *types.AttributeValueMemberM:
+		d, ok := dst.(*types.AttributeValueMemberM)
+		if !ok || e.isIdx {
+			return
+		}
+		child, ok := s.Value[e.name]
+		if !ok {
+			return
+		}
+		if len(p) == 1 {
+			d.Value[e.name] = cloneValue(child)
+			return
+		}
+		if _, ok := d.Value[e.name]; !ok {
+			d.Value[e.name] = emptyLike(child)
+		}
+		copyNested(d.Value[e.name], child, p[1:])
+	case *types.AttributeValueMemberL:
+		d, ok := dst.(*types.AttributeValueMemberL)
+		if !ok || !e.isIdx || e.index >= len(s.Value) {
+			return
+		}
+		child := s.Value[e.index]
+		if len(p) == 1 {
+			d.Value = append(d.Value, cloneValue(child))
+			return
+		}
+		next := emptyLike(child)
+		d.Value = append(d.Value, next)
+		copyNested(next, child, p[1:])
+	}
+}
@@ -0,0 +1,349 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// updateAction applies one clause of an update expression to an item.
+type updateAction func(it item) error
+
+// valueExpr computes the right-hand side of a SET action.
+type valueExpr func(it item) (types.AttributeValue, error)
+
+// compileUpdate parses an update expression into a list of actions.
+func compileUpdate(expr string, names map[string]string, values map[string]types.AttributeValue, used map[string]bool) ([]updateAction, []path, error) {
+	p, err := newParser(expr, names, values, used)
+	if err != nil {
+		return nil, nil, err
+	}
+	var actions []updateAction
+	var targets []path
+	seen := map[string]bool{}
+	for p.peek().kind != tokEOF {
+		clause := p.next()
+		if clause.kind != tokIdent {
+			return nil, nil, fmt.Errorf("expected update clause, got %q", clause.text)
+		}
+		keyword := strings.ToUpper(clause.text)
+		if seen[keyword] {
+			return nil, nil, fmt.Errorf("the %s section can only be used once in an update expression", keyword)
+		}
+		seen[keyword] = true
+		for {
+			target, err := p.parsePath()
+			if err != nil {
+				return nil, nil, err
+			}
+			targets = append(targets, target)
+			var action updateAction
+			switch keyword {
+			case "SET":
+				if err := p.expectPunct("="); err != nil {
+					return nil, nil, err
+				}
+				value, err := p.parseSetValue()
+				if err != nil {
+					return nil, nil, err
+				}
+				action = func(it item) error {
+					v, err := value(it)
+					if err != nil {
+						return err
+					}
+					return setPath(it, target, cloneValue(v))
+				}
+			case "REMOVE":
+				action = func(it item) error {
+					removePath(it, target)
+					return nil
+				}
+			case "ADD", "DELETE":
+				v, err := p.parseValueRef()
+				if err != nil {
+					return nil, nil, err
+				}
+				if keyword == "ADD" {
+					action = func(it item) error { return addValue(it, target, v) }
+				} else {
+					action = func(it item) error { return deleteFromSet(it, target, v) }
+				}
+			default:
+				return nil, nil, fmt.Errorf("invalid update clause %s", clause.text)
+			}
+			actions = append(actions, action)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(actions) == 0 {
+		return nil, nil, fmt.Errorf("update expression is empty")
+	}
+	return actions, targets, nil
+}
+
+func (p *parser) parseSetValue() (valueExpr, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct("+") && !p.isPunct("-") {
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (types.AttributeValue, error) {
+		l, err := left(it)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(it)
+		if err != nil {
+			return nil, err
+		}
+		ln, ok1 := l.(*types.AttributeValueMemberN)
+		rn, ok2 := r.(*types.AttributeValueMemberN)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		x, err := parseNumber(ln.Value)
+		if err != nil {
+			return nil, err
+		}
+		y, err := parseNumber(rn.Value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			x.Add(x, y)
+		} else {
+			x.Sub(x, y)
+		}
+		return &types.AttributeValueMemberN{Value: formatNumber(x)}, nil
+	}, nil
+}
+
+func (p *parser) parseSetOperand() (valueExpr, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			pth, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return func(it item) (types.AttributeValue, error) {
+				if v, ok := getPath(it, pth); ok {
+					return v, nil
+				}
+				return fallback(it)
+			}, nil
+		case "list_append":
+			p.next()
+			p.next()
+			first, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return func(it item) (types.AttributeValue, error) {
+				a, err := first(it)
+				if err != nil {
+					return nil, err
+				}
+				b, err := second(it)
+				if err != nil {
+					return nil, err
+				}
+				al, ok1 := a.(*types.AttributeValueMemberL)
+				bl, ok2 := b.(*types.AttributeValueMemberL)
+				if !ok1 || !ok2 {
+					return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+				}
+				joined := append(append([]types.AttributeValue{}, al.Value...), bl.Value...)
+				return &types.AttributeValueMemberL{Value: joined}, nil
+			}, nil
+		}
+	}
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (types.AttributeValue, error) {
+		v, ok := o(it)
+		if !ok {
+			return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item")
+		}
+		return v, nil
+	}, nil
+}
+
+func addValue(it item, target path, v types.AttributeValue) error {
+	current, exists := getPath(it, target)
+	switch v := v.(type) {
+	case *types.AttributeValueMemberN:
+		if !exists {
+			return setPath(it, target, cloneValue(v))
+		}
+		cur, ok := current.(*types.AttributeValueMemberN)
+		if !ok {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		x, err := parseNumber(cur.Value)
+		if err != nil {
+			return err
+		}
+		y, err := parseNumber(v.Value)
+		if err != nil {
+			return err
+		}
+		return setPath(it, target, &types.AttributeValueMemberN{Value: formatNumber(x.Add(x, y))})
+	case *types.AttributeValueMemberSS:
+		if !exists {
+			return setPath(it, target, cloneValue(v))
+		}
+		cur, ok := current.(*types.AttributeValueMemberSS)
+		if !ok {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		merged := append([]string{}, cur.Value...)
+		for _, e := range v.Value {
+			if !contains(cur, &types.AttributeValueMemberS{Value: e}) {
+				merged = append(merged, e)
+			}
+		}
+		return setPath(it, target, &types.AttributeValueMemberSS{Value: merged})
+	case *types.AttributeValueMemberNS:
+		if !exists {
+			return setPath(it, target, cloneValue(v))
+		}
+		cur, ok := current.(*types.AttributeValueMemberNS)
+		if !ok {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		merged := append([]string{}, cur.Value...)
+		for _, e := range v.Value {
+			if !contains(cur, &types.AttributeValueMemberN{Value: e}) {
+				merged = append(merged, e)
+			}
+		}
+		return setPath(it, target, &types.AttributeValueMemberNS{Value: merged})
+	}
+	return fmt.Errorf("incorrect operand type for operator or function; operator: ADD")
+}
+
+func deleteFromSet(it item, target path, v types.AttributeValue) error {
+	current, exists := getPath(it, target)
+	if !exists {
+		return nil
+	}
+	switch v := v.(type) {
+	case *types.AttributeValueMemberSS:
+		cur, ok := current.(*types.AttributeValueMemberSS)
+		if !ok {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		var kept []string
+		for _, e := range cur.Value {
+			if !contains(v, &types.AttributeValueMemberS{Value: e}) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			removePath(it, target)
+			return nil
+		}
+		return setPath(it, target, &types.AttributeValueMemberSS{Value: kept})
+	case *types.AttributeValueMemberNS:
+		cur, ok := current.(*types.AttributeValueMemberNS)
+		if !ok {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		var kept []string
+		for _, e := range cur.Value {
+			if !contains(v, &types.AttributeValueMemberN{Value: e}) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			removePath(it, target)
+			return nil
+		}
+		return setPath(it, target, &types.AttributeValueMemberNS{Value: kept})
+	}
+	return fmt.Errorf("incorrect operand type for operator or function; operator: DELETE")
+}
+
+// cloneValue deep-copies an attribute value so stored items never alias caller data.
+func cloneValue(v types.AttributeValue) types.AttributeValue {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		out := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			out[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: out}
+	case *types.AttributeValueMemberL:
+		out := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			out[i] = cloneValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: out}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	}
+	return v
+}
+
+func cloneItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item, len(it))
+	for k, v := range it {
+		out[k] = cloneValue(v)
+	}
+	return out
+}
@@ -0,0 +1,379 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrDecryption is matched by the error returned when an encrypted attribute
+// cannot be decrypted, for example because it was copied from another item.
+var ErrDecryption = errors.New("failed to decrypt attribute")
+
+// KeyProvider supplies the data keys that encrypt the fields tagged
+// `encrypt`. Every write of an item uses a new data key, which is stored
+// encrypted next to the ciphertext of each field. encryptionContext names the
+// table and the primary key of the item; a provider must bind the encrypted
+// data key to it, so that it only decrypts for the same item.
+type KeyProvider interface {
+	// DataKey returns a new 256-bit data key, in plaintext and encrypted.
+	DataKey(ctx context.Context, encryptionContext map[string]string) (plaintext, encrypted []byte, err error)
+	// DecryptDataKey returns the plaintext of a data key returned by DataKey.
+	DecryptDataKey(ctx context.Context, encrypted []byte, encryptionContext map[string]string) ([]byte, error)
+}
+
+// WithKeyProvider encrypts the fields tagged `encrypt` with data keys from
+// provider. Models with such fields cannot be read or written without one.
+func WithKeyProvider(provider KeyProvider) RepositoryOption {
+	return func(r *Repository) {
+		r.keyProvider = provider
+	}
+}
+
+// encryptionVersion is the first byte of an encrypted attribute.
+const encryptionVersion = 1
+
+// encryptedPlaceholder is the ExpressionAttributeValues placeholder of the
+// i-th encrypted field in an Update, so that the value can be sealed once
+// the request is built.
+func encryptedPlaceholder(i int) string {
+	return ":e" + strconv.Itoa(i)
+}
+
+// encryptedIndex returns the position of field in meta.encrypted.
+func encryptedIndex(meta *modelMeta, field *fieldMeta) int {
+	for i, f := range meta.encrypted {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// encryptedAttribute reports whether attribute is declared with `encrypt`.
+func encryptedAttribute(meta *modelMeta, attribute string) bool {
+	for _, field := range meta.encrypted {
+		if field.tag.AttributeName == attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEncryptedConditions rejects conditions comparing the value of an
+// encrypted attribute, since DynamoDB only sees its ciphertext.
+func checkEncryptedConditions(meta *modelMeta, conditions []Condition) error {
+	for _, c := range conditions {
+		if c.op == opExists || c.op == opNotExists {
+			continue
+		}
+		if encryptedAttribute(meta, c.attribute) {
+			return fmt.Errorf("encrypted attribute %s cannot be compared in a condition", c.attribute)
+		}
+	}
+	return nil
+}
+
+// encryptionContext returns the context binding a data key to the item with
+// primary key key in tableName.
+func encryptionContext(meta *modelMeta, tableName string, key map[string]types.AttributeValue) (map[string]string, error) {
+	ec := map[string]string{"table": tableName}
+	for _, field := range meta.keyFields {
+		value, ok := key[field.tag.AttributeName]
+		if !ok {
+			return nil, fmt.Errorf("key attribute %s is required to encrypt or decrypt an item", field.tag.AttributeName)
+		}
+		s, err := keyString(value)
+		if err != nil {
+			return nil, err
+		}
+		ec[field.tag.AttributeName] = s
+	}
+	return ec, nil
+}
+
+// additionalData serializes the encryption context and the attribute name as
+// the additional authenticated data of AES-GCM.
+func additionalData(encryptionContext map[string]string, attribute string) []byte {
+	keys := make([]string, 0, len(encryptionContext))
+	for k := range encryptionContext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var aad []byte
+	for _, k := range keys {
+		aad = append(aad, k...)
+		aad = append(aad, 0)
+		aad = append(aad, encryptionContext[k]...)
+		aad = append(aad, 0)
+	}
+	return append(aad, attribute...)
+}
+
+// sealer encrypts the attributes of one item with one data key.
+type sealer struct {
+	context      map[string]string
+	aead         cipher.AEAD
+	encryptedKey []byte
+}
+
+// newSealer requests a data key for the item of meta with primary key key.
+// It returns nil when the model has no encrypted field.
+func (r *Repository) newSealer(ctx context.Context, meta *modelMeta, tableName string, key map[string]types.AttributeValue) (*sealer, error) {
+	if len(meta.encrypted) == 0 {
+		return nil, nil
+	}
+	if r.keyProvider == nil {
+		return nil, fmt.Errorf("model %s has encrypted fields: set a KeyProvider with WithKeyProvider", meta.typ)
+	}
+	encryptionContext, err := encryptionContext(meta, tableName, key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, encrypted, err := r.keyProvider.DataKey(ctx, encryptionContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) > 0xffff {
+		return nil, fmt.Errorf("encrypted data key is too long: %d bytes", len(encrypted))
+	}
+	return &sealer{context: encryptionContext, aead: aead, encryptedKey: encrypted}, nil
+}
+
+// seal encrypts the string or binary value av of attribute. NULL values are
+// kept as they are.
+func (s *sealer) seal(attribute string, av types.AttributeValue) (types.AttributeValue, error) {
+	var plaintext []byte
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		plaintext = []byte(v.Value)
+	case *types.AttributeValueMemberB:
+		plaintext = v.Value
+	case *types.AttributeValueMemberNULL:
+		return av, nil
+	default:
+		return nil, fmt.Errorf("encrypted attribute %s must be a string or binary, got %T", attribute, av)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// version | key length | encrypted data key | nonce | ciphertext
+	out := make([]byte, 3, 3+len(s.encryptedKey)+len(nonce)+len(plaintext)+s.aead.Overhead())
+	out[0] = encryptionVersion
+	binary.BigEndian.PutUint16(out[1:3], uint16(len(s.encryptedKey)))
+	out = append(out, s.encryptedKey...)
+	out = append(out, nonce...)
+	out = s.aead.Seal(out, nonce, plaintext, additionalData(s.context, attribute))
+	return &types.AttributeValueMemberB{Value: out}, nil
+}
+
+// sealItem encrypts the `encrypt` attributes of av, item marshaled to be
+// written whole to tableName.
+func (r *Repository) sealItem(ctx context.Context, item interface{}, tableName string, av map[string]types.AttributeValue) error {
+	meta, err := itemMeta(item)
+	if err != nil {
+		return err
+	}
+	s, err := r.newSealer(ctx, meta, tableName, av)
+	if err != nil || s == nil {
+		return err
+	}
+	for _, field := range meta.encrypted {
+		attribute := field.tag.AttributeName
+		value, ok := av[attribute]
+		if !ok {
+			continue
+		}
+		if av[attribute], err = s.seal(attribute, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealUpdate encrypts the values that an update of item built by updateInput
+// sets on its `encrypt` attributes.
+func (r *Repository) sealUpdate(ctx context.Context, item interface{}, tableName string, key, values map[string]types.AttributeValue) error {
+	meta, err := itemMeta(item)
+	if err != nil {
+		return err
+	}
+	s, err := r.newSealer(ctx, meta, tableName, key)
+	if err != nil || s == nil {
+		return err
+	}
+	for i, field := range meta.encrypted {
+		placeholder := encryptedPlaceholder(i)
+		value, ok := values[placeholder]
+		if !ok {
+			continue
+		}
+		if values[placeholder], err = s.seal(field.tag.AttributeName, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemMeta returns the metadata of item, a struct or a pointer to one.
+func itemMeta(item interface{}) (*modelMeta, error) {
+	typ := reflect.TypeOf(item)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("item must be a struct")
+	}
+	return metadataOf(typ)
+}
+
+// openItems decrypts in place the `encrypt` attributes of items of meta read
+// from tableName. A data key used for several attributes is only decrypted
+// once.
+func (r *Repository) openItems(ctx context.Context, meta *modelMeta, tableName string, items []map[string]types.AttributeValue) error {
+	if len(meta.encrypted) == 0 || len(items) == 0 {
+		return nil
+	}
+	if r.keyProvider == nil {
+		return fmt.Errorf("model %s has encrypted fields: set a KeyProvider with WithKeyProvider", meta.typ)
+	}
+	for _, item := range items {
+		keys := make(map[string]cipher.AEAD)
+		var itemContext map[string]string
+		for _, field := range meta.encrypted {
+			attribute := field.tag.AttributeName
+			blob, ok := item[attribute].(*types.AttributeValueMemberB)
+			if !ok {
+				// Missing, NULL or projected away.
+				continue
+			}
+			if itemContext == nil {
+				var err error
+				if itemContext, err = encryptionContext(meta, tableName, item); err != nil {
+					return err
+				}
+			}
+			plaintext, err := r.open(ctx, keys, itemContext, attribute, blob.Value)
+			if err != nil {
+				return err
+			}
+			if field.typ == byteSliceType {
+				item[attribute] = &types.AttributeValueMemberB{Value: plaintext}
+			} else {
+				item[attribute] = &types.AttributeValueMemberS{Value: string(plaintext)}
+			}
+		}
+	}
+	return nil
+}
+
+// open decrypts one attribute value written by sealer.seal.
+func (r *Repository) open(ctx context.Context, keys map[string]cipher.AEAD, encryptionContext map[string]string, attribute string, blob []byte) ([]byte, error) {
+	if len(blob) < 3 || blob[0] != encryptionVersion {
+		return nil, fmt.Errorf("%w %s: unknown format", ErrDecryption, attribute)
+	}
+	keyLen := int(binary.BigEndian.Uint16(blob[1:3]))
+	if len(blob) < 3+keyLen {
+		return nil, fmt.Errorf("%w %s: truncated", ErrDecryption, attribute)
+	}
+	encryptedKey, rest := blob[3:3+keyLen], blob[3+keyLen:]
+	aead, ok := keys[string(encryptedKey)]
+	if !ok {
+		plaintext, err := r.keyProvider.DecryptDataKey(ctx, encryptedKey, encryptionContext)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrDecryption, attribute, err)
+		}
+		if aead, err = newAEAD(plaintext); err != nil {
+			return nil, err
+		}
+		keys[string(encryptedKey)] = aead
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w %s: truncated", ErrDecryption, attribute)
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(encryptionContext, attribute))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrDecryption, attribute, err)
+	}
+	return plaintext, nil
+}
+
+// Decrypt decrypts in place the `encrypt` attributes of av, an item of the
+// model's type stored in the model's table, for example a stream image
+// converted with FromStreamImage.
+func (r *Repository) Decrypt(ctx context.Context, model interface{}, av map[string]types.AttributeValue) error {
+	return r.decryptItem(ctx, model, r.getTableName(model), av)
+}
+
+// decryptItem decrypts in place the `encrypt` attributes of av, an item of
+// the type of item read from tableName.
+func (r *Repository) decryptItem(ctx context.Context, item interface{}, tableName string, av map[string]types.AttributeValue) error {
+	meta, err := itemMeta(item)
+	if err != nil {
+		return err
+	}
+	return r.openItems(ctx, meta, tableName, []map[string]types.AttributeValue{av})
+}
+
+// newAEAD returns AES-GCM keyed with key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// StaticKeyProvider is a KeyProvider that wraps data keys with a fixed AES
+// key held in memory. It suits tests and local development; use a
+// KMSKeyProvider in production.
+type StaticKeyProvider struct {
+	aead cipher.AEAD
+}
+
+// NewStaticKeyProvider returns a StaticKeyProvider for a 16, 24 or 32-byte key.
+func NewStaticKeyProvider(key []byte) (*StaticKeyProvider, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeyProvider{aead: aead}, nil
+}
+
+// DataKey implements KeyProvider.
+func (p *StaticKeyProvider) DataKey(ctx context.Context, encryptionContext map[string]string) ([]byte, []byte, error) {
+	plaintext := make([]byte, 32)
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	encrypted := p.aead.Seal(nonce, nonce, plaintext, additionalData(encryptionContext, ""))
+	return plaintext, encrypted, nil
+}
+
+// DecryptDataKey implements KeyProvider.
+func (p *StaticKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte, encryptionContext map[string]string) ([]byte, error) {
+	if len(encrypted) < p.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data key is truncated")
+	}
+	nonce, ciphertext := encrypted[:p.aead.NonceSize()], encrypted[p.aead.NonceSize():]
+	return p.aead.Open(nil, nonce, ciphertext, additionalData(encryptionContext, ""))
+}
@@ -0,0 +1,157 @@
+package dynamodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secretItem struct {
+	ID    string `dynamodbav:"id" dynamo:"id,key=hash"`
+	SSN   string `dynamodbav:"ssn" dynamo:"ssn,encrypt"`
+	Notes []byte `dynamodbav:"notes" dynamo:"notes,encrypt"`
+	Name  string `dynamodbav:"name" dynamo:"name"`
+}
+
+func newStaticKeyProvider(t *testing.T) *StaticKeyProvider {
+	provider, err := NewStaticKeyProvider(make([]byte, 32))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestMetadataOf_Encrypt(t *testing.T) {
+	assert.True(t, ParseDynamoTag("ssn,encrypt").Encrypt)
+	meta, err := metadataOf(reflect.TypeOf(secretItem{}))
+	require.NoError(t, err)
+	require.Len(t, meta.encrypted, 2)
+	assert.Equal(t, "SSN", meta.encrypted[0].name)
+
+	type intSecret struct {
+		ID  string `dynamo:"id,key=hash"`
+		PIN int    `dynamo:"pin,encrypt"`
+	}
+	type keySecret struct {
+		ID string `dynamo:"id,key=hash,encrypt"`
+	}
+	for _, tc := range []struct {
+		model    interface{}
+		expected string
+	}{
+		{intSecret{}, "encrypted field PIN must be a string or []byte, got int"},
+		{keySecret{}, "encrypted field ID cannot be a key"},
+	} {
+		_, err := metadataOf(reflect.TypeOf(tc.model))
+		assert.ErrorContains(t, err, tc.expected, "%T", tc.model)
+	}
+}
+
+func TestEncrypt_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(nil, "Items", WithKeyProvider(newStaticKeyProvider(t)))
+	item := &secretItem{ID: "a", SSN: "123-45-6789", Notes: []byte("notes"), Name: "n"}
+
+	input, err := repo.createInput(item)
+	require.NoError(t, err)
+	require.NoError(t, repo.sealItem(ctx, item, "Items", input.Item))
+	assert.IsType(t, &types.AttributeValueMemberB{}, input.Item["ssn"])
+	assert.NotContains(t, string(input.Item["ssn"].(*types.AttributeValueMemberB).Value), "123-45-6789")
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "n"}, input.Item["name"])
+
+	require.NoError(t, repo.Decrypt(ctx, &secretItem{}, input.Item))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "123-45-6789"}, input.Item["ssn"])
+	assert.Equal(t, &types.AttributeValueMemberB{Value: []byte("notes")}, input.Item["notes"])
+
+	// A ciphertext only decrypts in the attribute and the item it was written to.
+	require.NoError(t, repo.sealItem(ctx, item, "Items", input.Item))
+	moved := map[string]types.AttributeValue{
+		"id":  &types.AttributeValueMemberS{Value: "b"},
+		"ssn": input.Item["ssn"],
+	}
+	assert.ErrorIs(t, repo.Decrypt(ctx, &secretItem{}, moved), ErrDecryption)
+	swapped := map[string]types.AttributeValue{
+		"id":    input.Item["id"],
+		"notes": input.Item["ssn"],
+	}
+	assert.ErrorIs(t, repo.Decrypt(ctx, &secretItem{}, swapped), ErrDecryption)
+
+	_, err = NewStaticKeyProvider([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestEncrypt_Update(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepository(nil, "Items", WithKeyProvider(newStaticKeyProvider(t)))
+	item := &secretItem{ID: "a", SSN: "123-45-6789", Name: "n"}
+
+	input, err := repo.updateInput(item)
+	require.NoError(t, err)
+	assert.Contains(t, aws.ToString(input.UpdateExpression), "#n0 = :e0")
+	require.NoError(t, repo.sealUpdate(ctx, item, "Items", input.Key, input.ExpressionAttributeValues))
+
+	stored := map[string]types.AttributeValue{"id": input.Key["id"], "ssn": input.ExpressionAttributeValues[":e0"]}
+	require.NoError(t, repo.Decrypt(ctx, item, stored))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "123-45-6789"}, stored["ssn"])
+
+	_, err = repo.patchInput(item, NewUpdate().Set("ssn", "x"))
+	assert.EqualError(t, err, "encrypted attribute ssn cannot be patched: use Update")
+	_, err = repo.patchInput(item, NewUpdate().Set("name", "x").If(Equal("ssn", "x")))
+	assert.EqualError(t, err, "encrypted attribute ssn cannot be compared in a condition")
+
+	// Without a KeyProvider, encrypted models are refused.
+	err = NewRepository(nil, "Items").sealItem(ctx, item, "Items", map[string]types.AttributeValue{})
+	assert.ErrorContains(t, err, "set a KeyProvider")
+}
+
+func TestEncrypt_Reads(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingClient{}
+	repo := NewRepository(client, "Items", WithKeyProvider(newStaticKeyProvider(t)))
+
+	// The key is projected, since decryption is bound to it.
+	var one secretItem
+	require.NoError(t, repo.FindByID(ctx, "a", &one, WithProjection("SSN")))
+	assert.Equal(t, map[string]string{"#n0": "ssn", "#n1": "id"}, client.get.ExpressionAttributeNames)
+
+	var items []secretItem
+	assert.EqualError(t, repo.FindByParameter(ctx, "ssn", "x", &items), "items cannot be found by encrypted attribute ssn")
+	assert.ErrorContains(t, repo.Query("id", "a").Filter(Equal("notes", "x")).All(ctx, &items), "cannot be compared")
+}
+
+// fakeKMS wraps data keys with a StaticKeyProvider and records the context.
+type fakeKMS struct {
+	provider *StaticKeyProvider
+	context  map[string]string
+}
+
+func (k *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	k.context = params.EncryptionContext
+	plaintext, encrypted, err := k.provider.DataKey(ctx, params.EncryptionContext)
+	return &kms.GenerateDataKeyOutput{Plaintext: plaintext, CiphertextBlob: encrypted, KeyId: params.KeyId}, err
+}
+
+func (k *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	plaintext, err := k.provider.DecryptDataKey(ctx, params.CiphertextBlob, params.EncryptionContext)
+	return &kms.DecryptOutput{Plaintext: plaintext, KeyId: params.KeyId}, err
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeKMS{provider: newStaticKeyProvider(t)}
+	repo := NewRepository(nil, "Items", WithKeyProvider(NewKMSKeyProvider(client, "alias/app")))
+	item := &secretItem{ID: "a", SSN: "123-45-6789"}
+
+	av := map[string]types.AttributeValue{
+		"id":  &types.AttributeValueMemberS{Value: "a"},
+		"ssn": &types.AttributeValueMemberS{Value: item.SSN},
+	}
+	require.NoError(t, repo.sealItem(ctx, item, "Items", av))
+	assert.Equal(t, map[string]string{"table": "Items", "id": "S:a"}, client.context)
+	require.NoError(t, repo.Decrypt(ctx, item, av))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: item.SSN}, av["ssn"])
+}
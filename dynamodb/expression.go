@@ -0,0 +1,223 @@
+package dynamodb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// expressionBuilder hands out ExpressionAttributeNames and
+// ExpressionAttributeValues placeholders so that attribute names and values
+// never appear in an expression verbatim. This keeps reserved words such as
+// `name` or `status` usable as attribute names.
+type expressionBuilder struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	nameByAttr map[string]string
+}
+
+func newExpressionBuilder() *expressionBuilder {
+	return &expressionBuilder{
+		names:      make(map[string]string),
+		values:     make(map[string]types.AttributeValue),
+		nameByAttr: make(map[string]string),
+	}
+}
+
+// name returns the placeholder for an attribute name, reusing it when the
+// same attribute is referenced more than once.
+func (b *expressionBuilder) name(attribute string) string {
+	if placeholder, ok := b.nameByAttr[attribute]; ok {
+		return placeholder
+	}
+	placeholder := "#n" + strconv.Itoa(len(b.names))
+	b.names[placeholder] = attribute
+	b.nameByAttr[attribute] = placeholder
+	return placeholder
+}
+
+// value marshals v and returns its placeholder.
+func (b *expressionBuilder) value(v interface{}) (string, error) {
+	av, err := attributevalue.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal value: %w", err)
+	}
+	return b.attributeValue(av), nil
+}
+
+// attributeValue returns the placeholder for an already marshaled value.
+func (b *expressionBuilder) attributeValue(av types.AttributeValue) string {
+	placeholder := ":v" + strconv.Itoa(len(b.values))
+	b.values[placeholder] = av
+	return placeholder
+}
+
+// attributeNames returns the collected names, or nil when there are none,
+// because DynamoDB rejects an empty ExpressionAttributeNames map.
+func (b *expressionBuilder) attributeNames() map[string]string {
+	if len(b.names) == 0 {
+		return nil
+	}
+	return b.names
+}
+
+// attributeValues returns the collected values, or nil when there are none.
+func (b *expressionBuilder) attributeValues() map[string]types.AttributeValue {
+	if len(b.values) == 0 {
+		return nil
+	}
+	return b.values
+}
+
+// Condition operators.
+const (
+	opEqual              = "="
+	opNotEqual           = "<>"
+	opLessThan           = "<"
+	opLessThanOrEqual    = "<="
+	opGreaterThan        = ">"
+	opGreaterThanOrEqual = ">="
+	opBetween            = "BETWEEN"
+	opBeginsWith         = "begins_with"
+	opContains           = "contains"
+	opExists             = "attribute_exists"
+	opNotExists          = "attribute_not_exists"
+	opIn                 = "IN"
+)
+
+// Condition is a single comparison on an attribute, used as a sort key
+// condition or as a filter. Build one with Equal, LessThan, Between,
+// BeginsWith and the other constructors in this file.
+type Condition struct {
+	op        string
+	attribute string
+	values    []interface{}
+}
+
+// Equal matches items whose attribute equals value.
+func Equal(attribute string, value interface{}) Condition {
+	return Condition{op: opEqual, attribute: attribute, values: []interface{}{value}}
+}
+
+// NotEqual matches items whose attribute does not equal value.
+func NotEqual(attribute string, value interface{}) Condition {
+	return Condition{op: opNotEqual, attribute: attribute, values: []interface{}{value}}
+}
+
+// LessThan matches items whose attribute is less than value.
+func LessThan(attribute string, value interface{}) Condition {
+	return Condition{op: opLessThan, attribute: attribute, values: []interface{}{value}}
+}
+
+// LessThanOrEqual matches items whose attribute is less than or equal to value.
+func LessThanOrEqual(attribute string, value interface{}) Condition {
+	return Condition{op: opLessThanOrEqual, attribute: attribute, values: []interface{}{value}}
+}
+
+// GreaterThan matches items whose attribute is greater than value.
+func GreaterThan(attribute string, value interface{}) Condition {
+	return Condition{op: opGreaterThan, attribute: attribute, values: []interface{}{value}}
+}
+
+// GreaterThanOrEqual matches items whose attribute is greater than or equal to value.
+func GreaterThanOrEqual(attribute string, value interface{}) Condition {
+	return Condition{op: opGreaterThanOrEqual, attribute: attribute, values: []interface{}{value}}
+}
+
+// Between matches items whose attribute lies between low and high, inclusive.
+func Between(attribute string, low, high interface{}) Condition {
+	return Condition{op: opBetween, attribute: attribute, values: []interface{}{low, high}}
+}
+
+// BeginsWith matches items whose string or binary attribute starts with prefix.
+func BeginsWith(attribute string, prefix interface{}) Condition {
+	return Condition{op: opBeginsWith, attribute: attribute, values: []interface{}{prefix}}
+}
+
+// Contains matches items whose string attribute contains value as a
+// substring, or whose set or list attribute contains value as an element.
+// It can only be used as a filter.
+func Contains(attribute string, value interface{}) Condition {
+	return Condition{op: opContains, attribute: attribute, values: []interface{}{value}}
+}
+
+// In matches items whose attribute equals one of values.
+// It can only be used as a filter.
+func In(attribute string, values ...interface{}) Condition {
+	return Condition{op: opIn, attribute: attribute, values: values}
+}
+
+// AttributeExists matches items that have the attribute.
+// It can only be used as a filter.
+func AttributeExists(attribute string) Condition {
+	return Condition{op: opExists, attribute: attribute}
+}
+
+// AttributeNotExists matches items that do not have the attribute.
+// It can only be used as a filter.
+func AttributeNotExists(attribute string) Condition {
+	return Condition{op: opNotExists, attribute: attribute}
+}
+
+// isKeyCondition reports whether the condition may be used on a sort key.
+func (c Condition) isKeyCondition() bool {
+	switch c.op {
+	case opEqual, opLessThan, opLessThanOrEqual, opGreaterThan, opGreaterThanOrEqual, opBetween, opBeginsWith:
+		return true
+	}
+	return false
+}
+
+// build renders the condition using placeholders from b.
+func (c Condition) build(b *expressionBuilder) (string, error) {
+	if c.attribute == "" {
+		return "", fmt.Errorf("condition has no attribute")
+	}
+	name := b.name(c.attribute)
+	placeholders := make([]string, len(c.values))
+	for i, v := range c.values {
+		placeholder, err := b.value(v)
+		if err != nil {
+			return "", err
+		}
+		placeholders[i] = placeholder
+	}
+	switch c.op {
+	case opEqual, opNotEqual, opLessThan, opLessThanOrEqual, opGreaterThan, opGreaterThanOrEqual:
+		return fmt.Sprintf("%s %s %s", name, c.op, placeholders[0]), nil
+	case opBetween:
+		return fmt.Sprintf("%s BETWEEN %s AND %s", name, placeholders[0], placeholders[1]), nil
+	case opBeginsWith, opContains:
+		return fmt.Sprintf("%s(%s, %s)", c.op, name, placeholders[0]), nil
+	case opExists, opNotExists:
+		return fmt.Sprintf("%s(%s)", c.op, name), nil
+	case opIn:
+		if len(placeholders) == 0 {
+			return "", fmt.Errorf("IN condition on %s needs at least one value", c.attribute)
+		}
+		return fmt.Sprintf("%s IN (%s)", name, strings.Join(placeholders, ", ")), nil
+	}
+	return "", fmt.Errorf("unsupported condition operator %q", c.op)
+}
+
+// buildConditions renders conditions joined with AND.
+func buildConditions(b *expressionBuilder, conditions []Condition) (string, error) {
+	parts := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		expr, err := c.build(b)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, expr)
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	for i, part := range parts {
+		parts[i] = "(" + part + ")"
+	}
+	return strings.Join(parts, " AND "), nil
+}
@@ -0,0 +1,76 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCondition_Build(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		expected  string
+	}{
+		{name: "equal", condition: Equal("name", "Alice"), expected: "#n0 = :v0"},
+		{name: "not equal", condition: NotEqual("status", "deleted"), expected: "#n0 <> :v0"},
+		{name: "greater than or equal", condition: GreaterThanOrEqual("total", 100), expected: "#n0 >= :v0"},
+		{name: "between", condition: Between("created_at", 1, 10), expected: "#n0 BETWEEN :v0 AND :v1"},
+		{name: "begins with", condition: BeginsWith("order_id", "2024-"), expected: "begins_with(#n0, :v0)"},
+		{name: "contains", condition: Contains("tags", "go"), expected: "contains(#n0, :v0)"},
+		{name: "in", condition: In("status", "new", "paid"), expected: "#n0 IN (:v0, :v1)"},
+		{name: "attribute exists", condition: AttributeExists("email"), expected: "attribute_exists(#n0)"},
+		{name: "attribute not exists", condition: AttributeNotExists("email"), expected: "attribute_not_exists(#n0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newExpressionBuilder()
+			expr, err := tt.condition.build(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, expr)
+			assert.Equal(t, tt.condition.attribute, b.names["#n0"])
+		})
+	}
+}
+
+func TestCondition_BuildErrors(t *testing.T) {
+	_, err := In("status").build(newExpressionBuilder())
+	assert.Error(t, err)
+
+	_, err = Equal("", "x").build(newExpressionBuilder())
+	assert.Error(t, err)
+}
+
+func TestBuildConditions_ReusesNames(t *testing.T) {
+	b := newExpressionBuilder()
+	expr, err := buildConditions(b, []Condition{
+		GreaterThanOrEqual("total", 100),
+		LessThan("total", 200),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "(#n0 >= :v0) AND (#n0 < :v1)", expr)
+	assert.Equal(t, map[string]string{"#n0": "total"}, b.attributeNames())
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "100"}, b.attributeValues()[":v0"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "200"}, b.attributeValues()[":v1"])
+}
+
+func TestExpressionBuilder_Empty(t *testing.T) {
+	b := newExpressionBuilder()
+	assert.Nil(t, b.attributeNames())
+	assert.Nil(t, b.attributeValues())
+}
+
+func TestQueryBuilder_Where(t *testing.T) {
+	repo := NewRepository(nil, "Orders")
+
+	q := repo.Query("user_id", "u1").Where(Contains("order_id", "x"))
+	assert.Error(t, q.err)
+
+	q = repo.Query("user_id", "u1").Where(BeginsWith("order_id", "a")).Where(LessThan("order_id", "b"))
+	assert.Error(t, q.err)
+
+	q = repo.Query("user_id", "u1").Where(Between("order_id", "a", "b"))
+	assert.NoError(t, q.err)
+}
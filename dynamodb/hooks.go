@@ -0,0 +1,117 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// BeforeCreateHook is implemented by models that prepare themselves before
+// Create, BatchCreate, Transaction.Create and SingleTable's Create and Put
+// store them, for example to normalize fields or
+// derive an ID. It runs before timestamps are set and required fields are
+// validated. Returning an error aborts the operation.
+type BeforeCreateHook interface {
+	BeforeCreate(ctx context.Context) error
+}
+
+// BeforeUpdateHook is implemented by models that prepare themselves before
+// Update, Patch or Transaction.Update writes them. Returning an error aborts the operation.
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// BeforeDeleteHook is implemented by models that check whether they may be
+// deleted by Delete, Purge, BatchDelete, their Transaction counterparts and
+// SingleTable.Delete. It is only called when a struct is passed. Returning an error aborts the operation.
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterFindHook is implemented by models that post-process themselves after
+// being read, for example to redact fields. It is called for every item
+// returned by FindByID, FindByKey, FindByParameter, GetAll, their paged
+// variants, queries, BatchFindByIDs, ParallelScan and SingleTable's Get,
+// Query and Collection. Returning an error
+// makes the read fail.
+type AfterFindHook interface {
+	AfterFind(ctx context.Context) error
+}
+
+// addressable returns item as a pointer so that hooks with pointer receivers
+// can be called and can modify it. A struct passed by value is copied.
+func addressable(item interface{}) interface{} {
+	val := reflect.ValueOf(item)
+	if val.Kind() != reflect.Struct {
+		return item
+	}
+	copied := reflect.New(val.Type())
+	copied.Elem().Set(val)
+	return copied.Interface()
+}
+
+// beforeCreate calls the BeforeCreate hook of item and returns the item to store.
+func beforeCreate(ctx context.Context, item interface{}) (interface{}, error) {
+	item = addressable(item)
+	if hook, ok := item.(BeforeCreateHook); ok {
+		if err := hook.BeforeCreate(ctx); err != nil {
+			return nil, fmt.Errorf("before create hook: %w", err)
+		}
+	}
+	return item, nil
+}
+
+// beforeUpdate calls the BeforeUpdate hook of item and returns the item to write.
+func beforeUpdate(ctx context.Context, item interface{}) (interface{}, error) {
+	item = addressable(item)
+	if hook, ok := item.(BeforeUpdateHook); ok {
+		if err := hook.BeforeUpdate(ctx); err != nil {
+			return nil, fmt.Errorf("before update hook: %w", err)
+		}
+	}
+	return item, nil
+}
+
+// beforeDelete calls the BeforeDelete hook of item.
+func beforeDelete(ctx context.Context, item interface{}) error {
+	if hook, ok := addressable(item).(BeforeDeleteHook); ok {
+		if err := hook.BeforeDelete(ctx); err != nil {
+			return fmt.Errorf("before delete hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// afterFind calls the AfterFind hook of out, which is a pointer to a struct,
+// or of every element of the slice out points to.
+func afterFind(ctx context.Context, out interface{}) error {
+	val := reflect.ValueOf(out)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return nil
+	}
+	if val.Elem().Kind() != reflect.Slice {
+		return callAfterFind(ctx, out)
+	}
+	slice := val.Elem()
+	for i := 0; i < slice.Len(); i++ {
+		elem := slice.Index(i)
+		if elem.Kind() != reflect.Ptr {
+			elem = elem.Addr()
+		} else if elem.IsNil() {
+			continue
+		}
+		if err := callAfterFind(ctx, elem.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func callAfterFind(ctx context.Context, item interface{}) error {
+	if hook, ok := item.(AfterFindHook); ok {
+		if err := hook.AfterFind(ctx); err != nil {
+			return fmt.Errorf("after find hook: %w", err)
+		}
+	}
+	return nil
+}
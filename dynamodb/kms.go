@@ -0,0 +1,57 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// KMSClient is the subset of the AWS KMS client used by KMSKeyProvider.
+// *kms.Client satisfies it.
+type KMSClient interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// KMSKeyProvider is a KeyProvider that generates data keys under a KMS key.
+// The encryption context is passed to KMS, so it is recorded in CloudTrail
+// and can be used in key policy conditions.
+type KMSKeyProvider struct {
+	client KMSClient
+	keyID  string
+}
+
+// NewKMSKeyProvider returns a KMSKeyProvider for the KMS key keyID, which may
+// be a key ID, a key ARN or an alias.
+func NewKMSKeyProvider(client KMSClient, keyID string) *KMSKeyProvider {
+	return &KMSKeyProvider{client: client, keyID: keyID}
+}
+
+// DataKey implements KeyProvider.
+func (p *KMSKeyProvider) DataKey(ctx context.Context, encryptionContext map[string]string) ([]byte, []byte, error) {
+	out, err := p.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(p.keyID),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// DecryptDataKey implements KeyProvider.
+func (p *KMSKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte, encryptionContext map[string]string) ([]byte, error) {
+	out, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(p.keyID),
+		CiphertextBlob:    encrypted,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,434 @@
+package dynamodb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelMeta is the parsed `dynamo` layout of a struct type. It is built once
+// per type by metadataOf and shared by every later call, so the hot paths of
+// the repository do not walk the fields and parse the tags again.
+//
+// Fields of anonymous embedded structs, such as a shared base entity, are
+// promoted like the attributevalue package does when marshaling: they appear
+// in fields as if they were declared by the outer struct. A field of the
+// outer struct hides an embedded field with the same attribute name.
+//
+// Table names are not cached: TableName is still called on every item since
+// it may depend on the item or on the environment.
+type modelMeta struct {
+	typ reflect.Type
+	// fields lists the fields with a `dynamo` tag in declaration order.
+	fields      []*fieldMeta
+	byAttribute map[string]*fieldMeta
+	byName      map[string]*fieldMeta
+	hashKey     string
+	rangeKey    string
+	// keyFields lists the hash and range key fields.
+	keyFields []*fieldMeta
+	// indexes maps attributes declared with `index=` to their index name.
+	indexes      map[string]string
+	createFields []*fieldMeta
+	updateFields []*fieldMeta
+	// version is the field declared with `version`, or nil.
+	version *fieldMeta
+	// softDelete and ttl are the fields declared with `softDelete` and
+	// `ttl=`, or nil. ttlRetention is the duration of the `ttl=` option.
+	softDelete   *fieldMeta
+	ttl          *fieldMeta
+	ttlRetention time.Duration
+	// tenant is the field declared with `tenant`, or nil.
+	tenant *fieldMeta
+	// encrypted lists the fields declared with `encrypt`.
+	encrypted []*fieldMeta
+	// nested lists the struct fields, with or without a `dynamo` tag, whose
+	// values are validated recursively.
+	nested []*nestedField
+}
+
+// fieldMeta is a field with a `dynamo` tag.
+type fieldMeta struct {
+	// index is the index sequence for reflect.Value.FieldByIndex. It is
+	// longer than one for fields promoted from embedded structs.
+	index []int
+	name  string
+	typ   reflect.Type
+	tag   *DynamoTagParser
+	depth int
+}
+
+// nestedField is a field holding a struct, a pointer to a struct, or a slice
+// or array of either.
+type nestedField struct {
+	index     []int
+	name      string
+	attribute string
+	elem      reflect.Type
+}
+
+// get returns the field of the struct value v. A field promoted through a nil
+// embedded pointer reads as its zero value.
+func (f *fieldMeta) get(v reflect.Value) reflect.Value {
+	field, err := v.FieldByIndexErr(f.index)
+	if err != nil {
+		return reflect.Zero(f.typ)
+	}
+	return field
+}
+
+// settable returns the field of the addressable struct value v, allocating
+// nil embedded pointers on the way. When a nil pointer cannot be set, because
+// its type is unexported, a detached zero value is returned and writes to it
+// are lost, like the attributevalue package ignores such fields.
+func (f *fieldMeta) settable(v reflect.Value) reflect.Value {
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.New(f.typ).Elem()
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// models caches *modelMeta, or the error found in the tags, by reflect.Type.
+var models sync.Map
+
+// metadataOf returns the metadata of the struct type typ. A malformed tag is
+// reported the first time typ is used and on every later call.
+func metadataOf(typ reflect.Type) (*modelMeta, error) {
+	if cached, ok := models.Load(typ); ok {
+		return cachedMetadata(cached)
+	}
+	var cached interface{}
+	meta, err := buildMetadata(typ)
+	if err != nil {
+		cached = fmt.Errorf("invalid model %s: %w", typ, err)
+	} else {
+		cached = meta
+	}
+	cached, _ = models.LoadOrStore(typ, cached)
+	return cachedMetadata(cached)
+}
+
+func cachedMetadata(cached interface{}) (*modelMeta, error) {
+	if err, ok := cached.(error); ok {
+		return nil, err
+	}
+	return cached.(*modelMeta), nil
+}
+
+// buildMetadata parses and checks the `dynamo` tags of typ.
+func buildMetadata(typ reflect.Type) (*modelMeta, error) {
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct")
+	}
+	meta := &modelMeta{
+		typ:         typ,
+		byAttribute: make(map[string]*fieldMeta),
+		byName:      make(map[string]*fieldMeta),
+		indexes:     make(map[string]string),
+	}
+	var fields []*fieldMeta
+	if err := collectFields(typ, nil, 0, map[reflect.Type]bool{}, &fields, &meta.nested); err != nil {
+		return nil, err
+	}
+	// Resolve attribute names: the shallowest field wins, like in the
+	// attributevalue package, and two fields at the same depth conflict.
+	for _, field := range fields {
+		if field.tag.AttributeName == "" {
+			meta.fields = append(meta.fields, field)
+			continue
+		}
+		other, ok := meta.byAttribute[field.tag.AttributeName]
+		switch {
+		case !ok:
+		case other.depth < field.depth:
+			continue
+		case other.depth == field.depth:
+			return nil, fmt.Errorf("fields %s and %s both use attribute %s", other.name, field.name, field.tag.AttributeName)
+		}
+		meta.byAttribute[field.tag.AttributeName] = field
+		meta.fields = append(meta.fields, field)
+	}
+	if len(meta.byAttribute) < len(fields) {
+		// Drop hidden fields that were appended before the field hiding them.
+		visible := meta.fields[:0]
+		for _, field := range meta.fields {
+			if field.tag.AttributeName == "" || meta.byAttribute[field.tag.AttributeName] == field {
+				visible = append(visible, field)
+			}
+		}
+		meta.fields = visible
+	}
+
+	for _, field := range meta.fields {
+		parser := field.tag
+		if _, ok := meta.byName[field.name]; !ok || len(field.index) == 1 {
+			meta.byName[field.name] = field
+		}
+		switch parser.KeyType {
+		case "":
+		case KeyTypeHash:
+			if meta.hashKey != "" {
+				return nil, fmt.Errorf("model must declare exactly one hash key, found %s and %s", meta.hashKey, parser.AttributeName)
+			}
+			meta.hashKey = parser.AttributeName
+			meta.keyFields = append(meta.keyFields, field)
+		case KeyTypeRange:
+			if meta.rangeKey != "" {
+				return nil, fmt.Errorf("model must declare at most one range key, found %s and %s", meta.rangeKey, parser.AttributeName)
+			}
+			meta.rangeKey = parser.AttributeName
+			meta.keyFields = append(meta.keyFields, field)
+		default:
+			return nil, fmt.Errorf("field %s has unknown key type %q", field.name, parser.KeyType)
+		}
+		if parser.Index != "" {
+			meta.indexes[parser.AttributeName] = parser.Index
+		}
+
+		if parser.Version {
+			if meta.version != nil {
+				return nil, fmt.Errorf("only one version field is allowed, found %s and %s", meta.version.name, field.name)
+			}
+			if parser.KeyType != "" {
+				return nil, fmt.Errorf("version field %s cannot be a key", field.name)
+			}
+			switch field.typ.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				return nil, fmt.Errorf("version field %s must be an integer, got %s", field.name, field.typ)
+			}
+			meta.version = field
+		}
+
+		if parser.AutoCreateTime || parser.AutoUpdateTime {
+			if parser.AutoCreateTime && parser.AutoUpdateTime {
+				return nil, fmt.Errorf("field %s cannot be both autoCreateTime and autoUpdateTime", field.name)
+			}
+			if parser.TimeUnit != "" && parser.TimeUnit != TimeUnitMilli {
+				return nil, fmt.Errorf("field %s has unknown time unit %q", field.name, parser.TimeUnit)
+			}
+			switch {
+			case field.typ == timeType:
+			case field.typ.Kind() >= reflect.Int && field.typ.Kind() <= reflect.Int64:
+			default:
+				return nil, fmt.Errorf("timestamp field %s must be time.Time or an integer, got %s", field.name, field.typ)
+			}
+			if parser.AutoCreateTime {
+				meta.createFields = append(meta.createFields, field)
+			} else {
+				meta.updateFields = append(meta.updateFields, field)
+			}
+		}
+
+		if parser.SoftDelete {
+			if meta.softDelete != nil {
+				return nil, fmt.Errorf("only one softDelete field is allowed, found %s and %s", meta.softDelete.name, field.name)
+			}
+			if parser.KeyType != "" || parser.Version || parser.AutoCreateTime || parser.AutoUpdateTime {
+				return nil, fmt.Errorf("softDelete field %s cannot be a key, a version or an automatic timestamp", field.name)
+			}
+			if parser.TimeUnit != "" && parser.TimeUnit != TimeUnitMilli {
+				return nil, fmt.Errorf("field %s has unknown time unit %q", field.name, parser.TimeUnit)
+			}
+			typ := field.typ
+			if typ.Kind() == reflect.Ptr && typ.Elem() == timeType {
+				typ = timeType
+			}
+			if typ != timeType && (typ.Kind() < reflect.Int || typ.Kind() > reflect.Int64) {
+				return nil, fmt.Errorf("softDelete field %s must be time.Time, *time.Time or an integer, got %s", field.name, field.typ)
+			}
+			meta.softDelete = field
+		}
+
+		if parser.TTL != "" {
+			if meta.ttl != nil {
+				return nil, fmt.Errorf("only one ttl field is allowed, found %s and %s", meta.ttl.name, field.name)
+			}
+			retention, err := time.ParseDuration(parser.TTL)
+			if err != nil || retention <= 0 {
+				return nil, fmt.Errorf("field %s has invalid ttl %q", field.name, parser.TTL)
+			}
+			if parser.KeyType != "" || field.typ.Kind() < reflect.Int || field.typ.Kind() > reflect.Int64 {
+				return nil, fmt.Errorf("ttl field %s must be an integer that is not a key, got %s", field.name, field.typ)
+			}
+			meta.ttl, meta.ttlRetention = field, retention
+		}
+
+		if parser.Tenant {
+			if meta.tenant != nil {
+				return nil, fmt.Errorf("only one tenant field is allowed, found %s and %s", meta.tenant.name, field.name)
+			}
+			// Ids would otherwise be shared across tenants, and creating an
+			// item with the id of another tenant's item would reveal it.
+			if parser.KeyType != KeyTypeHash {
+				return nil, fmt.Errorf("tenant field %s must be the hash key", field.name)
+			}
+			if field.typ.Kind() != reflect.String {
+				return nil, fmt.Errorf("tenant field %s must be a string, got %s", field.name, field.typ)
+			}
+			meta.tenant = field
+		}
+
+		if parser.Encrypt {
+			if parser.KeyType != "" || parser.Index != "" || parser.Version || parser.AutoCreateTime || parser.AutoUpdateTime || parser.SoftDelete || parser.TTL != "" || parser.Tenant {
+				return nil, fmt.Errorf("encrypted field %s cannot be a key, an index, a version, a timestamp, a ttl or a tenant", field.name)
+			}
+			if field.typ.Kind() != reflect.String && field.typ != byteSliceType {
+				return nil, fmt.Errorf("encrypted field %s must be a string or []byte, got %s", field.name, field.typ)
+			}
+			meta.encrypted = append(meta.encrypted, field)
+		}
+
+		for _, rule := range parser.Rules {
+			if err := checkRuleType(rule, field.typ); err != nil {
+				return nil, fmt.Errorf("field %s: rule %s: %w", field.name, rule.Name, err)
+			}
+		}
+	}
+	if meta.ttl != nil && meta.softDelete == nil {
+		return nil, fmt.Errorf("ttl field %s requires a softDelete field", meta.ttl.name)
+	}
+	return meta, nil
+}
+
+// collectFields appends the tagged fields of typ to fields, descending into
+// anonymous embedded structs, and the fields holding structs to nested.
+// visiting guards against embedding cycles through pointers.
+func collectFields(typ reflect.Type, index []int, depth int, visiting map[reflect.Type]bool, fields *[]*fieldMeta, nested *[]*nestedField) error {
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		tag, tagged := field.Tag.Lookup("dynamo")
+		if embedded := embeddedStruct(field); embedded != nil && !tagged {
+			if !visiting[embedded] {
+				if err := collectFields(embedded, fieldIndex, depth+1, visiting, fields, nested); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tagged {
+			parser, err := parseDynamoTag(tag)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			*fields = append(*fields, &fieldMeta{index: fieldIndex, name: field.Name, typ: field.Type, tag: parser, depth: depth})
+		}
+		if elem := nestedStruct(field); elem != nil {
+			*nested = append(*nested, &nestedField{
+				index:     fieldIndex,
+				name:      field.Name,
+				attribute: attributeName(field),
+				elem:      elem,
+			})
+		}
+	}
+	return nil
+}
+
+// embeddedStruct returns the struct type of an anonymous field that is
+// flattened when marshaled, or nil.
+func embeddedStruct(field reflect.StructField) reflect.Type {
+	if !field.Anonymous || field.Tag.Get("dynamodbav") != "" {
+		return nil
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+// nestedStruct returns the struct type held by an exported field of a
+// struct, pointer, slice or array type, or nil. time.Time is not nested.
+func nestedStruct(field reflect.StructField) reflect.Type {
+	if !field.IsExported() || field.Tag.Get("dynamodbav") == "-" {
+		return nil
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return nil
+	}
+	return typ
+}
+
+// attributeName returns the attribute a field is stored in: the name in its
+// `dynamo` tag, else the name in its `dynamodbav` tag, else its Go name.
+func attributeName(field reflect.StructField) string {
+	if name := ParseDynamoTag(field.Tag.Get("dynamo")).AttributeName; name != "" {
+		return name
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// checkRuleType checks that rule has a valid parameter and can be applied to
+// fields of type typ.
+func checkRuleType(rule ValidationRule, typ reflect.Type) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	kind := typ.Kind()
+	isLength := kind == reflect.String || kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array
+	isInteger := kind >= reflect.Int && kind <= reflect.Uint64
+	isNumber := isInteger || kind == reflect.Float32 || kind == reflect.Float64
+	supported := true
+	switch rule.Name {
+	case RuleRequired:
+	case RuleMin, RuleMax:
+		supported = isLength || isNumber
+		if isLength {
+			if limit, err := strconv.Atoi(rule.Param); err != nil || limit < 0 {
+				return fmt.Errorf("invalid length %q", rule.Param)
+			}
+		} else if _, err := strconv.ParseFloat(rule.Param, 64); err != nil {
+			return fmt.Errorf("invalid number %q", rule.Param)
+		}
+	case RuleLen:
+		supported = isLength
+		if limit, err := strconv.Atoi(rule.Param); err != nil || limit < 0 {
+			return fmt.Errorf("invalid length %q", rule.Param)
+		}
+	case RulePattern:
+		supported = kind == reflect.String
+		if _, err := compilePattern(rule.Param); err != nil {
+			return err
+		}
+	case RuleOneOf:
+		supported = kind == reflect.String || isInteger
+	case RuleEmail:
+		supported = kind == reflect.String
+	default:
+		return fmt.Errorf("unknown rule")
+	}
+	if !supported {
+		return fmt.Errorf("not supported for %s", typ)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package dynamodb
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metaUser struct {
+	ID        string    `dynamodbav:"id" dynamo:"id,key=hash"`
+	Email     string    `dynamodbav:"email" dynamo:"email,required,email,index=email-index"`
+	Name      string    `dynamodbav:"name" dynamo:"name,required,min=2,max=50"`
+	Role      string    `dynamodbav:"role" dynamo:"role,oneof=admin member"`
+	Version   int       `dynamodbav:"version" dynamo:"version,version"`
+	CreatedAt time.Time `dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	UpdatedAt int64     `dynamodbav:"updated_at" dynamo:"updated_at,autoUpdateTime=milli"`
+	Note      string    `dynamodbav:"note"`
+}
+
+func (u *metaUser) TableName() string {
+	return "MetaUsers"
+}
+
+func TestMetadataOf(t *testing.T) {
+	meta, err := metadataOf(reflect.TypeOf(metaUser{}))
+	require.NoError(t, err)
+	assert.Equal(t, "id", meta.hashKey)
+	assert.Equal(t, "", meta.rangeKey)
+	assert.Equal(t, []string{"ID"}, fieldNames(meta.keyFields))
+	assert.Equal(t, []string{"ID", "Email", "Name", "Role", "Version", "CreatedAt", "UpdatedAt"}, fieldNames(meta.fields))
+	assert.NotContains(t, meta.byName, "Note")
+	assert.Equal(t, map[string]string{"email": "email-index"}, meta.indexes)
+	assert.Equal(t, []int{4}, meta.version.index)
+	assert.Equal(t, "version", meta.version.tag.AttributeName)
+	assert.Equal(t, []string{"CreatedAt"}, fieldNames(meta.createFields))
+	assert.Equal(t, []string{"UpdatedAt"}, fieldNames(meta.updateFields))
+	assert.Equal(t, TimeUnitMilli, meta.byAttribute["updated_at"].tag.TimeUnit)
+
+	again, err := metadataOf(reflect.TypeOf(metaUser{}))
+	require.NoError(t, err)
+	assert.Same(t, meta, again)
+}
+
+func fieldNames(fields []*fieldMeta) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.name
+	}
+	return names
+}
+
+type metaBase struct {
+	ID        string    `dynamodbav:"id" dynamo:"id,key=hash"`
+	Version   int       `dynamodbav:"version" dynamo:"version,version"`
+	CreatedAt time.Time `dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	Note      string    `dynamodbav:"note" dynamo:"note"`
+}
+
+type metaAddress struct {
+	City string `dynamodbav:"city" dynamo:"city,required"`
+}
+
+type metaEmbedded struct {
+	metaBase
+	Note    string       `dynamodbav:"note" dynamo:"note,max=5"`
+	Address metaAddress  `dynamodbav:"address"`
+	Billing *metaAddress `dynamodbav:"billing"`
+}
+
+type metaEmbeddedPtr struct {
+	*metaBase
+	Name string `dynamodbav:"name" dynamo:"name"`
+}
+
+func TestMetadataOf_Embedded(t *testing.T) {
+	meta, err := metadataOf(reflect.TypeOf(metaEmbedded{}))
+	require.NoError(t, err)
+	assert.Equal(t, "id", meta.hashKey)
+	assert.Equal(t, []int{0, 0}, meta.keyFields[0].index)
+	assert.Equal(t, []int{0, 1}, meta.version.index)
+	assert.Equal(t, []string{"CreatedAt"}, fieldNames(meta.createFields))
+	// The outer Note hides the embedded one.
+	assert.Equal(t, []string{"ID", "Version", "CreatedAt", "Note"}, fieldNames(meta.fields))
+	assert.Equal(t, []int{1}, meta.byAttribute["note"].index)
+	require.Len(t, meta.nested, 2)
+	assert.Equal(t, "address", meta.nested[0].attribute)
+	assert.Equal(t, reflect.TypeOf(metaAddress{}), meta.nested[1].elem)
+
+	item := metaEmbedded{metaBase: metaBase{ID: "u1", Version: 2}, Address: metaAddress{City: "Tokyo"}}
+	key, hashKey, err := primaryKey(reflect.ValueOf(item))
+	require.NoError(t, err)
+	assert.Equal(t, "id", hashKey)
+	assert.Contains(t, key, "id")
+
+	input, err := NewRepository(&recordingClient{}, "Default").updateInput(&item)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "u1"}}, input.Key)
+	assert.Equal(t, "attribute_exists(#n1) AND #n2 = :v1", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, "id", input.ExpressionAttributeNames["#n1"])
+	assert.Equal(t, "version", input.ExpressionAttributeNames["#n2"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, input.ExpressionAttributeValues[":v1"])
+
+	type otherBase struct {
+		Key string `dynamo:"id"`
+	}
+	type sameDepth struct {
+		metaBase
+		otherBase
+	}
+	_, err = metadataOf(reflect.TypeOf(sameDepth{}))
+	assert.ErrorContains(t, err, "fields ID and Key both use attribute id")
+}
+
+func TestMetadataOf_EmbeddedPointer(t *testing.T) {
+	// A nil embedded pointer reads as zero values.
+	item := &metaEmbeddedPtr{Name: "n"}
+	key, _, err := primaryKey(reflect.ValueOf(item).Elem())
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: ""}, key["id"])
+	av := map[string]types.AttributeValue{}
+	require.NoError(t, initVersion(item, av))
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, av["version"])
+
+	// It is allocated when a field is set, if its type is exported.
+	type Base struct {
+		ID      string `dynamo:"id,key=hash"`
+		Version int    `dynamo:"version,version"`
+	}
+	type withPointer struct {
+		*Base
+	}
+	other := &withPointer{}
+	require.NoError(t, initVersion(other, map[string]types.AttributeValue{}))
+	require.NotNil(t, other.Base)
+	assert.Equal(t, 1, other.Version)
+}
+
+func TestMetadataOf_Concurrent(t *testing.T) {
+	type concurrentModel struct {
+		ID string `dynamo:"id,key=hash"`
+	}
+	typ := reflect.TypeOf(concurrentModel{})
+	results := make([]*modelMeta, 16)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			meta, err := metadataOf(typ)
+			assert.NoError(t, err)
+			results[i] = meta
+		}(i)
+	}
+	wg.Wait()
+	for _, meta := range results {
+		assert.Same(t, results[0], meta)
+	}
+}
+
+func TestMetadataOf_MalformedTags(t *testing.T) {
+	type unknownOption struct {
+		ID   string `dynamo:"id,key=hash"`
+		Name string `dynamo:"name,requird"`
+	}
+	type duplicateAttribute struct {
+		ID    string `dynamo:"id,key=hash"`
+		Name  string `dynamo:"name"`
+		Alias string `dynamo:"name"`
+	}
+	type twoRangeKeys struct {
+		ID string `dynamo:"id,key=hash"`
+		A  string `dynamo:"a,key=range"`
+		B  string `dynamo:"b,key=range"`
+	}
+	type badTimeUnit struct {
+		ID        string `dynamo:"id,key=hash"`
+		CreatedAt int64  `dynamo:"created_at,autoCreateTime=nano"`
+	}
+	type badRuleParam struct {
+		ID   string `dynamo:"id,key=hash"`
+		Name string `dynamo:"name,max=ten"`
+	}
+	type badRuleType struct {
+		ID     string `dynamo:"id,key=hash"`
+		Active bool   `dynamo:"active,email"`
+	}
+	for _, tc := range []struct {
+		model    interface{}
+		expected string
+	}{
+		{unknownOption{}, `field Name: unknown option "requird"`},
+		{duplicateAttribute{}, "fields Name and Alias both use attribute name"},
+		{twoRangeKeys{}, "at most one range key, found a and b"},
+		{badTimeUnit{}, `unknown time unit "nano"`},
+		{badRuleParam{}, `field Name: rule max: invalid length "ten"`},
+		{badRuleType{}, "field Active: rule email: not supported for bool"},
+	} {
+		typ := reflect.TypeOf(tc.model)
+		_, err := metadataOf(typ)
+		assert.ErrorContains(t, err, "invalid model dynamodb."+typ.Name(), "%T", tc.model)
+		assert.ErrorContains(t, err, tc.expected, "%T", tc.model)
+		// The error is cached as well.
+		_, again := metadataOf(typ)
+		assert.Equal(t, err, again)
+	}
+
+	// Malformed tags surface from the repository methods.
+	repo := NewRepository(&recordingClient{}, "Default")
+	err := repo.Create(context.Background(), &unknownOption{ID: "1"})
+	assert.ErrorContains(t, err, `unknown option "requird"`)
+	var found unknownOption
+	err = repo.FindByID(context.Background(), "1", &found)
+	assert.ErrorContains(t, err, `unknown option "requird"`)
+}
+
+// forgetMetadata removes typ from the cache, so the next call parses its tags
+// again like before the cache existed.
+func forgetMetadata(typ reflect.Type) {
+	models.Delete(typ)
+}
+
+func benchmarkCached(b *testing.B, typ reflect.Type, fn func(b *testing.B)) {
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			forgetMetadata(typ)
+			fn(b)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fn(b)
+		}
+	})
+}
+
+func BenchmarkCreateInput(b *testing.B) {
+	repo := NewRepository(&recordingClient{}, "Default")
+	user := &metaUser{ID: "u1", Email: "alice@example.com", Name: "Alice", Role: "admin"}
+	benchmarkCached(b, reflect.TypeOf(metaUser{}), func(b *testing.B) {
+		user.CreatedAt = time.Time{}
+		if _, err := repo.createInput(user); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkUpdateInput(b *testing.B) {
+	repo := NewRepository(&recordingClient{}, "Default")
+	user := &metaUser{ID: "u1", Email: "alice@example.com", Name: "Alice", Role: "admin", Version: 3}
+	benchmarkCached(b, reflect.TypeOf(metaUser{}), func(b *testing.B) {
+		if _, err := repo.updateInput(user); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkFindByID(b *testing.B) {
+	repo := NewRepository(&recordingClient{}, "Default")
+	ctx := context.Background()
+	benchmarkCached(b, reflect.TypeOf(metaUser{}), func(b *testing.B) {
+		var user metaUser
+		if err := repo.FindByID(ctx, "u1", &user); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkFindByParameter(b *testing.B) {
+	repo := NewRepository(&recordingClient{}, "Default")
+	ctx := context.Background()
+	benchmarkCached(b, reflect.TypeOf(metaUser{}), func(b *testing.B) {
+		var users []metaUser
+		if err := repo.FindByParameter(ctx, "email", "alice@example.com", &users); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -13,12 +14,47 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Key types accepted by the `key=` option of the `dynamo` struct tag.
+const (
+	KeyTypeHash  = "hash"
+	KeyTypeRange = "range"
+)
+
 // DynamoTagParser holds parsed values from the `dynamo` struct tag.
 type DynamoTagParser struct {
 	AttributeName string
 	KeyType       string
 	Index         string
 	Required      bool
+	Version       bool
+	// Rules holds the validation rules of the field in tag order, including
+	// required. A `pattern=` rule takes the rest of the tag, so that the
+	// expression may contain commas, and must therefore come last.
+	Rules []ValidationRule
+	// AutoCreateTime and AutoUpdateTime mark timestamp fields managed by the
+	// repository. TimeUnit is "milli" for integer fields holding unix
+	// milliseconds and empty for unix seconds.
+	AutoCreateTime bool
+	AutoUpdateTime bool
+	TimeUnit       string
+	// SoftDelete marks the timestamp field Delete sets instead of deleting
+	// the item; TimeUnit applies to it as well. TTL is the retention, such as
+	// "720h", after which the integer field tagged `ttl=` expires a
+	// soft-deleted item through DynamoDB TTL.
+	SoftDelete bool
+	TTL        string
+	// Tenant marks the string field holding the tenant an item belongs to,
+	// which a TenantRepository fills and checks.
+	Tenant bool
+	// Encrypt marks a string or []byte field stored encrypted with a data
+	// key of the repository's KeyProvider.
+	Encrypt bool
+	// Entity, PartitionKey and SortKey describe an entity of a SingleTable.
+	// They are set on a blank marker field, e.g.
+	// `dynamo:",entity=Order,pk=USER#{UserID},sk=ORDER#{OrderID}"`.
+	Entity       string
+	PartitionKey string
+	SortKey      string
 }
 
 // TableNamer should be implemented by items which specify their own table name.
@@ -27,16 +63,35 @@ type TableNamer interface {
 }
 
 // ParseDynamoTag parses a struct field tag and returns a parser.
+// Unknown options are ignored here; the repository reports them as an error
+// the first time a model with such a tag is used.
 func ParseDynamoTag(tag string) *DynamoTagParser {
+	parser, _ := parseDynamoTag(tag)
+	return parser
+}
+
+// parseDynamoTag parses tag like ParseDynamoTag and reports the first unknown option.
+func parseDynamoTag(tag string) (*DynamoTagParser, error) {
 	parser := &DynamoTagParser{}
 	if tag == "" {
-		return parser
+		return parser, nil
 	}
+	var unknown []string
+	tag, pattern, hasPattern := strings.Cut(tag, ","+RulePattern+"=")
 	options := strings.Split(tag, ",")
 	if len(options) > 0 && options[0] != "" {
 		parser.AttributeName = options[0]
 	}
 	for _, opt := range options[1:] {
+		name, param, _ := strings.Cut(opt, "=")
+		switch name {
+		case RuleMin, RuleMax, RuleLen, RuleOneOf:
+			parser.Rules = append(parser.Rules, ValidationRule{Name: name, Param: param})
+			continue
+		case RuleEmail:
+			parser.Rules = append(parser.Rules, ValidationRule{Name: name})
+			continue
+		}
 		switch {
 		case strings.HasPrefix(opt, "key="):
 			parser.KeyType = strings.TrimPrefix(opt, "key=")
@@ -44,9 +99,42 @@ func ParseDynamoTag(tag string) *DynamoTagParser {
 			parser.Index = strings.TrimPrefix(opt, "index=")
 		case opt == "required":
 			parser.Required = true
+			parser.Rules = append(parser.Rules, ValidationRule{Name: RuleRequired})
+		case opt == "version":
+			parser.Version = true
+		case opt == "autoCreateTime" || strings.HasPrefix(opt, "autoCreateTime="):
+			parser.AutoCreateTime = true
+			parser.TimeUnit = strings.TrimPrefix(strings.TrimPrefix(opt, "autoCreateTime"), "=")
+		case opt == "autoUpdateTime" || strings.HasPrefix(opt, "autoUpdateTime="):
+			parser.AutoUpdateTime = true
+			parser.TimeUnit = strings.TrimPrefix(strings.TrimPrefix(opt, "autoUpdateTime"), "=")
+		case opt == "softDelete" || strings.HasPrefix(opt, "softDelete="):
+			parser.SoftDelete = true
+			parser.TimeUnit = strings.TrimPrefix(strings.TrimPrefix(opt, "softDelete"), "=")
+		case strings.HasPrefix(opt, "ttl="):
+			parser.TTL = strings.TrimPrefix(opt, "ttl=")
+		case opt == "tenant":
+			parser.Tenant = true
+		case opt == "encrypt":
+			parser.Encrypt = true
+		case strings.HasPrefix(opt, "entity="):
+			parser.Entity = strings.TrimPrefix(opt, "entity=")
+		case strings.HasPrefix(opt, "pk="):
+			parser.PartitionKey = strings.TrimPrefix(opt, "pk=")
+		case strings.HasPrefix(opt, "sk="):
+			parser.SortKey = strings.TrimPrefix(opt, "sk=")
+		case opt == "":
+		default:
+			unknown = append(unknown, opt)
 		}
 	}
-	return parser
+	if hasPattern {
+		parser.Rules = append(parser.Rules, ValidationRule{Name: RulePattern, Param: pattern})
+	}
+	if len(unknown) > 0 {
+		return parser, fmt.Errorf("unknown option %q", unknown[0])
+	}
+	return parser, nil
 }
 
 // DynamoDBClient is used by the repository to interface with DynamoDB.
@@ -57,25 +145,72 @@ type DynamoDBClient interface {
 	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 // Repository implements basic CRUD operations using DynamoDB.
 type Repository struct {
-	client    DynamoDBClient
-	tableName string
+	client           DynamoDBClient
+	tableName        string
+	cursorSecret     []byte
+	batchConcurrency int
+	batchMaxRetries  int
+	batchBaseDelay   time.Duration
+	now              func() time.Time
+	retryPolicy      *RetryPolicy
+	keyProvider      KeyProvider
+	// tenant is set on the copies a TenantRepository scopes to one tenant.
+	tenant string
+}
+
+// RepositoryOption configures optional Repository behaviour.
+type RepositoryOption func(*Repository)
+
+// WithClock sets the function used to fill `autoCreateTime` and
+// `autoUpdateTime` fields. It defaults to time.Now.
+func WithClock(now func() time.Time) RepositoryOption {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// WithCursorSecret signs pagination cursors with an HMAC keyed by secret.
+// Without it each Repository signs with a random secret of its own, so its
+// cursors are refused by other processes and by repositories created later.
+func WithCursorSecret(secret []byte) RepositoryOption {
+	return func(r *Repository) {
+		r.cursorSecret = secret
+	}
 }
 
 var (
-	ErrDuplicateKey = errors.New("item with this key already exists")
-	ErrNotFound     = errors.New("item not found")
+	ErrDuplicateKey    = errors.New("item with this key already exists")
+	ErrNotFound        = errors.New("item not found")
+	ErrVersionConflict = errors.New("item was modified by another writer")
 )
 
 // NewRepository returns a new Repository.
-func NewRepository(client DynamoDBClient, defaultTableName string) *Repository {
-	return &Repository{
-		client:    client,
-		tableName: defaultTableName,
+func NewRepository(client DynamoDBClient, defaultTableName string, opts ...RepositoryOption) *Repository {
+	r := &Repository{
+		client:           client,
+		tableName:        defaultTableName,
+		batchConcurrency: defaultBatchConcurrency,
+		batchMaxRetries:  defaultBatchMaxRetries,
+		batchBaseDelay:   defaultBatchBaseDelay,
+		now:              time.Now,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.cursorSecret) == 0 {
+		r.cursorSecret = newCursorSecret()
+	}
+	if r.retryPolicy != nil && r.retryPolicy.MaxAttempts > 1 {
+		r.client = newRetryClient(r.client, *r.retryPolicy)
+	}
+	return r
 }
 
 // getTableName returns the table name for an item. It checks if the item implements TableNamer.
@@ -91,21 +226,20 @@ func (r *Repository) getTableName(item interface{}) string {
 	return r.tableName
 }
 
-// Create stores an item in DynamoDB.
+// Create stores an item in DynamoDB. Fields tagged `autoUpdateTime`, and
+// `autoCreateTime` fields that are still zero, are set to the current time.
+// The BeforeCreate hook of item is called first.
 func (r *Repository) Create(ctx context.Context, item interface{}) error {
-	if err := validateStruct(item); err != nil {
-		return fmt.Errorf("validation error: %w", err)
+	item, err := beforeCreate(ctx, item)
+	if err != nil {
+		return err
 	}
-	av, err := attributevalue.MarshalMap(item)
+	input, err := r.createInput(item)
 	if err != nil {
-		return fmt.Errorf("failed to marshal item: %w", err)
+		return err
 	}
-	tableName := r.getTableName(item)
-	conditionExpression := createConditionExpression(item)
-	input := &dynamodb.PutItemInput{
-		TableName:           aws.String(tableName),
-		Item:                av,
-		ConditionExpression: aws.String(conditionExpression),
+	if err := r.sealItem(ctx, item, aws.ToString(input.TableName), input.Item); err != nil {
+		return err
 	}
 	_, err = r.client.PutItem(ctx, input)
 	if err != nil {
@@ -118,48 +252,55 @@ func (r *Repository) Create(ctx context.Context, item interface{}) error {
 	return nil
 }
 
-// validateStruct ensures that required fields (as specified by the `dynamo` tag) are non-zero.
-func validateStruct(v interface{}) error {
-	val := reflect.ValueOf(v)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
+// createInput builds the PutItem request used by Create.
+func (r *Repository) createInput(item interface{}) (*dynamodb.PutItemInput, error) {
+	item, err := r.scopeItem(item)
+	if err != nil {
+		return nil, err
 	}
-	if val.Kind() != reflect.Struct {
-		return fmt.Errorf("item must be a struct")
-	}
-	typ := val.Type()
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		if tag, ok := field.Tag.Lookup("dynamo"); ok {
-			parser := ParseDynamoTag(tag)
-			if parser.Required {
-				fieldValue := val.Field(i)
-				if fieldValue.IsZero() {
-					return fmt.Errorf("field %s is required", field.Name)
-				}
-			}
-		}
+	if item, err = r.setTimestamps(item, true); err != nil {
+		return nil, err
 	}
-	return nil
+	if err := validateStruct(item); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item: %w", err)
+	}
+	if err := initVersion(item, av); err != nil {
+		return nil, err
+	}
+	if err := omitUndeleted(item, av); err != nil {
+		return nil, err
+	}
+	tableName := r.getTableName(item)
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      av,
+	}
+	b := newExpressionBuilder()
+	if conditionExpression := createConditionExpression(b, item); conditionExpression != "" {
+		input.ConditionExpression = aws.String(conditionExpression)
+		input.ExpressionAttributeNames = b.attributeNames()
+	}
+	return input, nil
 }
 
 // createConditionExpression builds a condition expression that checks for non-existence of key attributes.
 // This is used during Create to protect against duplicate keys.
-func createConditionExpression(v interface{}) string {
+func createConditionExpression(b *expressionBuilder, v interface{}) string {
 	val := reflect.ValueOf(v)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
-	typ := val.Type()
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return ""
+	}
 	conditions := []string{}
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		if tag, ok := field.Tag.Lookup("dynamo"); ok {
-			parser := ParseDynamoTag(tag)
-			if parser.KeyType == "hash" {
-				conditions = append(conditions, fmt.Sprintf("attribute_not_exists(%s)", parser.AttributeName))
-			}
-		}
+	for _, field := range meta.keyFields {
+		conditions = append(conditions, fmt.Sprintf("attribute_not_exists(%s)", b.name(field.tag.AttributeName)))
 	}
 	if len(conditions) == 0 {
 		return ""
@@ -167,279 +308,729 @@ func createConditionExpression(v interface{}) string {
 	return strings.Join(conditions, " AND ")
 }
 
-// FindByID retrieves an item by its key.
-func (r *Repository) FindByID(ctx context.Context, id interface{}, out interface{}) error {
-	tableName := r.getTableName(out)
+// keyAttributes returns the attribute names of the hash and range key fields
+// declared with `key=hash` and `key=range`. rangeKey is empty for tables
+// that only have a partition key.
+func keyAttributes(typ reflect.Type) (hashKey, rangeKey string) {
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return "", ""
+	}
+	return meta.hashKey, meta.rangeKey
+}
+
+// primaryKey builds the primary key of a struct value from its hash and
+// range key fields. It also returns the hash key attribute name.
+func primaryKey(val reflect.Value) (map[string]types.AttributeValue, string, error) {
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return nil, "", err
+	}
+	if meta.hashKey == "" {
+		return nil, "", fmt.Errorf("no hash key defined in struct")
+	}
+	key := make(map[string]types.AttributeValue, len(meta.keyFields))
+	for _, field := range meta.keyFields {
+		av, err := attributevalue.Marshal(field.get(val).Interface())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to marshal key field %s: %w", field.name, err)
+		}
+		key[field.tag.AttributeName] = av
+	}
+	return key, meta.hashKey, nil
+}
+
+// FindByID retrieves an item by its hash key.
+// Use FindByKey for tables that also have a range key.
+func (r *Repository) FindByID(ctx context.Context, id interface{}, out interface{}, opts ...ReadOption) error {
+	return r.findByKey(ctx, id, nil, out, r.readOptions(opts))
+}
+
+// FindByKey retrieves an item by its hash key and range key.
+func (r *Repository) FindByKey(ctx context.Context, hashKey, rangeKey interface{}, out interface{}, opts ...ReadOption) error {
+	if rangeKey == nil {
+		return fmt.Errorf("range key must not be nil")
+	}
+	return r.findByKey(ctx, hashKey, rangeKey, out, r.readOptions(opts))
+}
+
+func (r *Repository) findByKey(ctx context.Context, hashKey, rangeKey interface{}, out interface{}, options readOptions) error {
+	if options.indexName != "" {
+		return fmt.Errorf("an item cannot be read by key from index %s: use Query", options.indexName)
+	}
 	elemType := reflect.TypeOf(out)
-	if elemType.Kind() != reflect.Ptr {
+	if elemType == nil || elemType.Kind() != reflect.Ptr {
 		return fmt.Errorf("out must be a pointer")
 	}
 	elemType = elemType.Elem()
 	if elemType.Kind() != reflect.Struct {
 		return fmt.Errorf("out must be a pointer to struct")
 	}
-	var keyAttribute string
-	for i := 0; i < elemType.NumField(); i++ {
-		field := elemType.Field(i)
-		if tag, ok := field.Tag.Lookup("dynamo"); ok {
-			parser := ParseDynamoTag(tag)
-			if parser.KeyType == "hash" {
-				keyAttribute = parser.AttributeName
-				break
-			}
-		}
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return err
 	}
-	if keyAttribute == "" {
+	hashAttribute, rangeAttribute := meta.hashKey, meta.rangeKey
+	if hashAttribute == "" {
 		return fmt.Errorf("no hash key defined in struct")
 	}
-	av, err := attributevalue.Marshal(id)
+	if options.tenant != "" && meta.tenant != nil {
+		// The tenant is the hash key: FindByID takes the range key, and
+		// FindByKey fills an empty hash key.
+		if rangeAttribute != "" && rangeKey == nil {
+			hashKey, rangeKey = options.tenant, hashKey
+		} else if hashKey == nil || hashKey == "" {
+			hashKey = options.tenant
+		}
+	}
+	if rangeAttribute != "" && rangeKey == nil {
+		return fmt.Errorf("struct defines range key %s: use FindByKey", rangeAttribute)
+	}
+	if rangeAttribute == "" && rangeKey != nil {
+		return fmt.Errorf("no range key defined in struct")
+	}
+	if options.tenant != "" {
+		if meta.tenant == nil {
+			return noTenantField(meta)
+		}
+		if fmt.Sprint(hashKey) != options.tenant {
+			return &CrossTenantError{Tenant: options.tenant}
+		}
+	}
+	av, err := attributevalue.Marshal(hashKey)
 	if err != nil {
 		return fmt.Errorf("failed to marshal key: %w", err)
 	}
 	key := map[string]types.AttributeValue{
-		keyAttribute: av,
+		hashAttribute: av,
+	}
+	if rangeAttribute != "" {
+		av, err := attributevalue.Marshal(rangeKey)
+		if err != nil {
+			return fmt.Errorf("failed to marshal range key: %w", err)
+		}
+		key[rangeAttribute] = av
+	}
+	b := newExpressionBuilder()
+	projection, err := options.projectionExpression(b, elemType, options.guardAttributes(meta)...)
+	if err != nil {
+		return err
 	}
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key:       key,
+		TableName:                aws.String(options.table(r, elemType)),
+		Key:                      key,
+		ConsistentRead:           options.consistentReadValue(),
+		ProjectionExpression:     projection,
+		ExpressionAttributeNames: b.attributeNames(),
 	}
 	result, err := r.client.GetItem(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to get item: %w", err)
 	}
 	if result.Item == nil {
-		return fmt.Errorf("item not found")
+		return ErrNotFound
+	}
+	if visible, err := options.visible(meta, result.Item); err != nil || !visible {
+		if err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	if err := r.openItems(ctx, meta, aws.ToString(input.TableName), []map[string]types.AttributeValue{result.Item}); err != nil {
+		return err
 	}
 	err = attributevalue.UnmarshalMap(result.Item, out)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal item: %w", err)
 	}
-	return nil
+	return afterFind(ctx, out)
 }
 
-// FindByParameter retrieves items by a given parameter value.
-// It uses a Query if an index exists for the parameter
-// and a Scan otherwise.
-func (r *Repository) FindByParameter(ctx context.Context, parameter string, value interface{}, out interface{}) error {
+// sliceElemType checks that out is a pointer to a slice of structs (or of
+// pointers to structs) and returns the struct type.
+func sliceElemType(out interface{}) (reflect.Type, error) {
 	outType := reflect.TypeOf(out)
-	if outType.Kind() != reflect.Ptr {
-		return fmt.Errorf("out must be a pointer to slice")
+	if outType == nil || outType.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("out must be a pointer to slice")
 	}
 	sliceType := outType.Elem()
 	if sliceType.Kind() != reflect.Slice {
-		return fmt.Errorf("out must be a pointer to slice")
+		return nil, fmt.Errorf("out must be a pointer to slice")
 	}
 	elemType := sliceType.Elem()
 	if elemType.Kind() == reflect.Ptr {
 		elemType = elemType.Elem()
 	}
 	if elemType.Kind() != reflect.Struct {
-		return fmt.Errorf("slice element must be a struct")
-	}
-	tableName := r.getTableName(reflect.New(elemType).Interface())
-	var useQuery bool
-	var indexName string
-	for i := 0; i < elemType.NumField(); i++ {
-		field := elemType.Field(i)
-		if tag, ok := field.Tag.Lookup("dynamo"); ok {
-			parser := ParseDynamoTag(tag)
-			if parser.AttributeName == parameter && parser.Index != "" {
-				useQuery = true
-				indexName = parser.Index
-				break
-			}
+		return nil, fmt.Errorf("slice element must be a struct")
+	}
+	return elemType, nil
+}
+
+// readRequest is a Query or a Scan that can be executed one page at a time.
+type readRequest struct {
+	tableName string
+	indexName string
+	query     *dynamodb.QueryInput
+	scan      *dynamodb.ScanInput
+	// meta describes the items read, whose encrypted attributes are
+	// decrypted as each page arrives.
+	meta *modelMeta
+}
+
+// fetchPage executes one page of the request starting after startKey.
+func (r *Repository) fetchPage(ctx context.Context, req *readRequest, startKey map[string]types.AttributeValue, limit int32) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	if req.query != nil {
+		input := *req.query
+		input.ExclusiveStartKey = startKey
+		if limit > 0 {
+			input.Limit = aws.Int32(limit)
 		}
+		result, err := r.client.Query(ctx, &input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query: %w", err)
+		}
+		if err := r.openItems(ctx, req.meta, req.tableName, result.Items); err != nil {
+			return nil, nil, err
+		}
+		return result.Items, result.LastEvaluatedKey, nil
 	}
-	marshaledValue, err := attributevalue.Marshal(value)
+	input := *req.scan
+	input.ExclusiveStartKey = startKey
+	if limit > 0 {
+		input.Limit = aws.Int32(limit)
+	}
+	result, err := r.client.Scan(ctx, &input)
 	if err != nil {
-		return fmt.Errorf("failed to marshal value: %w", err)
+		return nil, nil, fmt.Errorf("failed to scan: %w", err)
 	}
-	exprAttrValues := map[string]types.AttributeValue{
-		":v": marshaledValue,
+	if err := r.openItems(ctx, req.meta, req.tableName, result.Items); err != nil {
+		return nil, nil, err
+	}
+	return result.Items, result.LastEvaluatedKey, nil
+}
+
+// readAll follows LastEvaluatedKey until every page has been read and
+// unmarshals the items into out.
+func (r *Repository) readAll(ctx context.Context, req *readRequest, out interface{}) error {
+	var items []map[string]types.AttributeValue
+	var startKey map[string]types.AttributeValue
+	for {
+		page, lastKey, err := r.fetchPage(ctx, req, startKey, 0)
+		if err != nil {
+			return err
+		}
+		items = append(items, page...)
+		if len(lastKey) == 0 {
+			break
+		}
+		startKey = lastKey
+	}
+	if err := attributevalue.UnmarshalListOfMaps(items, out); err != nil {
+		return fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	return afterFind(ctx, out)
+}
+
+// readPage reads a single page of at most pageSize items starting at cursor,
+// unmarshals it into out and returns the cursor of the next page. The
+// returned cursor is empty when there are no more pages.
+func (r *Repository) readPage(ctx context.Context, req *readRequest, pageSize int32, cursor string, out interface{}) (string, error) {
+	if pageSize <= 0 {
+		return "", fmt.Errorf("page size must be positive")
+	}
+	startKey, err := r.decodeCursor(cursor, req)
+	if err != nil {
+		return "", err
+	}
+	items, lastKey, err := r.fetchPage(ctx, req, startKey, pageSize)
+	if err != nil {
+		return "", err
+	}
+	if err := attributevalue.UnmarshalListOfMaps(items, out); err != nil {
+		return "", fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	if err := afterFind(ctx, out); err != nil {
+		return "", err
+	}
+	return r.encodeCursor(req, lastKey)
+}
+
+// parameterRequest builds the request used by FindByParameter. It uses a
+// Query if an index exists for the parameter and a Scan otherwise.
+func (r *Repository) parameterRequest(parameter string, value interface{}, out interface{}, options readOptions) (*readRequest, error) {
+	elemType, err := sliceElemType(out)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return nil, err
+	}
+	tableName := options.table(r, elemType)
+	useQuery := options.indexName != ""
+	indexName := options.indexName
+	if index, ok := meta.indexes[parameter]; ok && !useQuery {
+		useQuery = true
+		indexName = index
+		// Indexes declared with index= are global secondary indexes.
+		if options.consistentRead {
+			return nil, fmt.Errorf("consistent reads are not supported on global secondary index %s", indexName)
+		}
+	}
+	if encryptedAttribute(meta, parameter) {
+		return nil, fmt.Errorf("items cannot be found by encrypted attribute %s", parameter)
+	}
+	b := newExpressionBuilder()
+	condition, err := Equal(parameter, value).build(b)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := options.projectionExpression(b, elemType)
+	if err != nil {
+		return nil, err
+	}
+	partition := ""
+	if useQuery {
+		partition = parameter
+	}
+	filter, err := options.filter(b, meta, partition, value)
+	if err != nil {
+		return nil, err
 	}
 	if useQuery {
 		input := &dynamodb.QueryInput{
 			TableName:                 aws.String(tableName),
 			IndexName:                 aws.String(indexName),
-			KeyConditionExpression:    aws.String(fmt.Sprintf("%s = :v", parameter)),
-			ExpressionAttributeValues: exprAttrValues,
+			KeyConditionExpression:    aws.String(condition),
+			ConsistentRead:            options.consistentReadValue(),
+			ProjectionExpression:      projection,
+			ExpressionAttributeNames:  b.attributeNames(),
+			ExpressionAttributeValues: b.attributeValues(),
 		}
-		result, err := r.client.Query(ctx, input)
-		if err != nil {
-			return fmt.Errorf("failed to query: %w", err)
+		if filter != "" {
+			input.FilterExpression = aws.String(filter)
 		}
-		err = attributevalue.UnmarshalListOfMaps(result.Items, out)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal query result: %w", err)
-		}
-	} else {
-		input := &dynamodb.ScanInput{
+		return &readRequest{tableName: tableName, indexName: indexName, query: input, meta: meta}, nil
+	}
+	if filter != "" {
+		condition += " AND " + filter
+	}
+	return &readRequest{
+		tableName: tableName,
+		meta:      meta,
+		scan: &dynamodb.ScanInput{
 			TableName:                 aws.String(tableName),
-			FilterExpression:          aws.String(fmt.Sprintf("%s = :v", parameter)),
-			ExpressionAttributeValues: exprAttrValues,
-		}
-		result, err := r.client.Scan(ctx, input)
-		if err != nil {
-			return fmt.Errorf("failed to scan: %w", err)
-		}
-		err = attributevalue.UnmarshalListOfMaps(result.Items, out)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal scan result: %w", err)
-		}
+			FilterExpression:          aws.String(condition),
+			ConsistentRead:            options.consistentReadValue(),
+			ProjectionExpression:      projection,
+			ExpressionAttributeNames:  b.attributeNames(),
+			ExpressionAttributeValues: b.attributeValues(),
+		},
+	}, nil
+}
+
+// FindByParameter retrieves every item with the given parameter value,
+// following LastEvaluatedKey across pages.
+// It uses a Query if an index exists for the parameter
+// and a Scan otherwise.
+func (r *Repository) FindByParameter(ctx context.Context, parameter string, value interface{}, out interface{}, opts ...ReadOption) error {
+	req, err := r.parameterRequest(parameter, value, out, r.readOptions(opts))
+	if err != nil {
+		return err
 	}
-	return nil
+	return r.readAll(ctx, req, out)
 }
 
-// GetAll retrieves all items from a table.
-func (r *Repository) GetAll(ctx context.Context, out interface{}) error {
-	outType := reflect.TypeOf(out)
-	if outType.Kind() != reflect.Ptr {
-		return fmt.Errorf("out must be a pointer to slice")
+// FindByParameterPage retrieves one page of at most pageSize items with the
+// given parameter value. Pass an empty cursor for the first page and the
+// returned cursor for the next one; an empty returned cursor means there are
+// no more pages. A Scan page may hold fewer than pageSize items because the
+// filter is applied after the page is read.
+func (r *Repository) FindByParameterPage(ctx context.Context, parameter string, value interface{}, pageSize int32, cursor string, out interface{}, opts ...ReadOption) (string, error) {
+	req, err := r.parameterRequest(parameter, value, out, r.readOptions(opts))
+	if err != nil {
+		return "", err
 	}
-	sliceType := outType.Elem()
-	if sliceType.Kind() != reflect.Slice {
-		return fmt.Errorf("out must be a pointer to slice")
+	return r.readPage(ctx, req, pageSize, cursor, out)
+}
+
+// scanRequest builds the request used by GetAll.
+func (r *Repository) scanRequest(out interface{}, options readOptions) (*readRequest, error) {
+	elemType, err := sliceElemType(out)
+	if err != nil {
+		return nil, err
 	}
-	elemType := sliceType.Elem()
-	if elemType.Kind() == reflect.Ptr {
-		elemType = elemType.Elem()
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return nil, err
 	}
-	if elemType.Kind() != reflect.Struct {
-		return fmt.Errorf("slice element must be a struct")
+	tableName := options.table(r, elemType)
+	b := newExpressionBuilder()
+	projection, err := options.projectionExpression(b, elemType)
+	if err != nil {
+		return nil, err
 	}
-	tableName := r.getTableName(reflect.New(elemType).Interface())
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName:      aws.String(tableName),
+		ConsistentRead: options.consistentReadValue(),
 	}
-	result, err := r.client.Scan(ctx, input)
+	filter, err := options.filter(b, meta, "", nil)
 	if err != nil {
-		return fmt.Errorf("failed to scan: %w", err)
+		return nil, err
+	}
+	if filter != "" {
+		input.FilterExpression = aws.String(filter)
+	}
+	input.ProjectionExpression = projection
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	if options.indexName != "" {
+		input.IndexName = aws.String(options.indexName)
 	}
-	err = attributevalue.UnmarshalListOfMaps(result.Items, out)
+	return &readRequest{
+		tableName: tableName,
+		indexName: options.indexName,
+		scan:      input,
+		meta:      meta,
+	}, nil
+}
+
+// GetAll retrieves all items from a table, following LastEvaluatedKey across pages.
+func (r *Repository) GetAll(ctx context.Context, out interface{}, opts ...ReadOption) error {
+	req, err := r.scanRequest(out, r.readOptions(opts))
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal scan result: %w", err)
+		return err
 	}
-	return nil
+	return r.readAll(ctx, req, out)
+}
+
+// GetPage retrieves one page of at most pageSize items from a table.
+// Pass an empty cursor for the first page and the returned cursor for the
+// next one; an empty returned cursor means there are no more pages.
+func (r *Repository) GetPage(ctx context.Context, pageSize int32, cursor string, out interface{}, opts ...ReadOption) (string, error) {
+	req, err := r.scanRequest(out, r.readOptions(opts))
+	if err != nil {
+		return "", err
+	}
+	return r.readPage(ctx, req, pageSize, cursor, out)
 }
 
 // Update updates an existing item in DynamoDB. It:
-//  1. Uses reflection to extract the key field (the one with `key=hash`).
-//  2. Builds an UpdateExpression with all other fields tagged with `dynamo`.
+//  1. Uses reflection to extract the key fields (the ones with `key=hash` and `key=range`).
+//  2. Builds an UpdateExpression with all other fields tagged with `dynamo`,
+//     except `autoCreateTime` fields. `autoUpdateTime` fields are set to the current time.
 //  3. Uses a ConditionExpression to ensure the item exists.
 //
 // If no updatable field is found or if the key is missing the update will return an error.
+// The BeforeUpdate hook of item is called first.
 func (r *Repository) Update(ctx context.Context, item interface{}) error {
+	item, err := beforeUpdate(ctx, item)
+	if err != nil {
+		return err
+	}
+	input, err := r.updateInput(item)
+	if err != nil {
+		return err
+	}
+	if err := r.sealUpdate(ctx, item, aws.ToString(input.TableName), input.Key, input.ExpressionAttributeValues); err != nil {
+		return err
+	}
+	_, err = r.client.UpdateItem(ctx, input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if err := r.tenantError(item, ccf.Item); err != nil {
+				return err
+			}
+			// With ReturnValuesOnConditionCheckFailure set, an existing item is
+			// returned when only the version check failed.
+			if ccf.Item != nil && !deletedItem(item, ccf.Item) {
+				return ErrVersionConflict
+			}
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+	return incrementVersion(item)
+}
+
+// updateInput builds the UpdateItem request used by Update.
+func (r *Repository) updateInput(item interface{}) (*dynamodb.UpdateItemInput, error) {
+	item, err := r.scopeItem(item)
+	if err != nil {
+		return nil, err
+	}
+	if item, err = r.setTimestamps(item, false); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(item); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
 	// Get the underlying struct value.
 	val := reflect.ValueOf(item)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
 	if val.Kind() != reflect.Struct {
-		return fmt.Errorf("item must be a struct")
+		return nil, fmt.Errorf("item must be a struct")
 	}
 	tableName := r.getTableName(item)
 
-	var keyAttr string
-	keyMap := make(map[string]types.AttributeValue)
+	keyMap, keyAttr, err := primaryKey(val)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return nil, err
+	}
 	updateExpressions := []string{}
-	exprAttrNames := make(map[string]string)
-	exprAttrValues := make(map[string]types.AttributeValue)
-
-	typ := val.Type()
-	// Walk through all struct fields.
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		fieldValue := val.Field(i)
-		tag, ok := field.Tag.Lookup("dynamo")
-		if !ok {
+	b := newExpressionBuilder()
+	// Encrypted values are sealed by sealUpdate once the request is built.
+	encrypted := make(map[string]types.AttributeValue, len(meta.encrypted))
+
+	// Walk through all tagged struct fields.
+	for _, field := range meta.fields {
+		parser := field.tag
+		if parser.AttributeName == "" || parser.KeyType == KeyTypeHash || parser.KeyType == KeyTypeRange || parser.Version || parser.AutoCreateTime || parser.SoftDelete || parser.TTL != "" {
 			continue
 		}
-		parser := ParseDynamoTag(tag)
-		if parser.AttributeName == "" {
-			continue
+		// Build update expression part for non-key fields.
+		marshaledVal, err := attributevalue.Marshal(field.get(val).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal field %s: %w", field.name, err)
 		}
-		if parser.KeyType == "hash" {
-			// Mark this as the key field.
-			keyAttr = parser.AttributeName
-			marshaledVal, err := attributevalue.Marshal(fieldValue.Interface())
-			if err != nil {
-				return fmt.Errorf("failed to marshal key field %s: %w", field.Name, err)
-			}
-			keyMap[keyAttr] = marshaledVal
-		} else {
-			// Build update expression part for non-key fields.
-			placeholderName := "#" + parser.AttributeName
-			placeholderValue := ":" + parser.AttributeName
-			updateExpressions = append(updateExpressions, fmt.Sprintf("%s = %s", placeholderName, placeholderValue))
-			exprAttrNames[placeholderName] = parser.AttributeName
-			marshaledVal, err := attributevalue.Marshal(fieldValue.Interface())
-			if err != nil {
-				return fmt.Errorf("failed to marshal field %s: %w", field.Name, err)
-			}
-			exprAttrValues[placeholderValue] = marshaledVal
+		if parser.Encrypt {
+			placeholder := encryptedPlaceholder(encryptedIndex(meta, field))
+			encrypted[placeholder] = marshaledVal
+			updateExpressions = append(updateExpressions, fmt.Sprintf("%s = %s", b.name(parser.AttributeName), placeholder))
+			continue
 		}
-	}
-	if keyAttr == "" {
-		return fmt.Errorf("no hash key defined in struct")
+		updateExpressions = append(updateExpressions, fmt.Sprintf("%s = %s", b.name(parser.AttributeName), b.attributeValue(marshaledVal)))
 	}
 	if len(updateExpressions) == 0 {
-		return fmt.Errorf("no updatable fields found")
+		return nil, fmt.Errorf("no updatable fields found")
 	}
-	updateExpr := "SET " + strings.Join(updateExpressions, ", ")
 
-	// Add a condition to ensure that the item exists.
-	keyPlaceholder := "#k"
-	exprAttrNames[keyPlaceholder] = keyAttr
-	conditionExpr := fmt.Sprintf("attribute_exists(%s)", keyPlaceholder)
+	// Add a condition to ensure that the item exists and is not soft deleted.
+	conditionExpr := fmt.Sprintf("attribute_exists(%s)", b.name(keyAttr))
+	if meta.softDelete != nil {
+		conditionExpr += fmt.Sprintf(" AND attribute_not_exists(%s)", b.name(meta.softDelete.tag.AttributeName))
+	}
 
 	input := &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(tableName),
-		Key:                       keyMap,
-		UpdateExpression:          aws.String(updateExpr),
-		ExpressionAttributeNames:  exprAttrNames,
-		ExpressionAttributeValues: exprAttrValues,
-		ConditionExpression:       aws.String(conditionExpr),
+		TableName: aws.String(tableName),
+		Key:       keyMap,
 	}
-
-	_, err := r.client.UpdateItem(ctx, input)
+	tenantCondition, err := r.tenantCondition(b, meta)
 	if err != nil {
-		var ccf *types.ConditionalCheckFailedException
-		if errors.As(err, &ccf) {
-			return ErrNotFound
+		return nil, err
+	}
+	if tenantCondition != "" {
+		// The stored item tells a missing item from one of another tenant.
+		conditionExpr += " AND " + tenantCondition
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	if meta.version != nil {
+		// Only update the item if nobody else has since the caller read it.
+		expected := versionValue(meta.version.get(val))
+		versionName := b.name(meta.version.tag.AttributeName)
+		expectedValue, err := b.value(expected)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("failed to update item: %w", err)
+		if expected == 0 {
+			conditionExpr += fmt.Sprintf(" AND (attribute_not_exists(%s) OR %s = %s)", versionName, versionName, expectedValue)
+		} else {
+			conditionExpr += fmt.Sprintf(" AND %s = %s", versionName, expectedValue)
+		}
+		nextValue, err := b.value(expected + 1)
+		if err != nil {
+			return nil, err
+		}
+		updateExpressions = append(updateExpressions, fmt.Sprintf("%s = %s", versionName, nextValue))
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
 	}
-	return nil
+
+	input.UpdateExpression = aws.String("SET " + strings.Join(updateExpressions, ", "))
+	input.ConditionExpression = aws.String(conditionExpr)
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	for placeholder, av := range encrypted {
+		if input.ExpressionAttributeValues == nil {
+			input.ExpressionAttributeValues = make(map[string]types.AttributeValue, len(encrypted))
+		}
+		input.ExpressionAttributeValues[placeholder] = av
+	}
+	return input, nil
 }
 
-// Delete deletes an item from DynamoDB by its primary key id (assumed to be of type string).
-// This method assumes that the primary key attribute in the table is named "id".
-// A conditional expression is used to ensure that the item exists.
-func (r *Repository) Delete(ctx context.Context, id string) error {
-	// Marshal the id value.
-	idAttr, err := attributevalue.Marshal(id)
+// versionField returns the field declared with the `version` option, or nil
+// if the struct has none.
+func versionField(typ reflect.Type) (*fieldMeta, error) {
+	meta, err := metadataOf(typ)
 	if err != nil {
-		return fmt.Errorf("failed to marshal key: %w", err)
+		return nil, err
 	}
+	return meta.version, nil
+}
 
-	// Build the key map – here we assume the primary key attribute is "id".
-	key := map[string]types.AttributeValue{
-		"id": idAttr,
+// versionValue returns the value of an integer version field.
+func versionValue(field reflect.Value) int64 {
+	if field.CanInt() {
+		return field.Int()
 	}
+	return int64(field.Uint())
+}
 
-	input := &dynamodb.DeleteItemInput{
-		TableName:           aws.String(r.tableName),
-		Key:                 key,
-		ConditionExpression: aws.String("attribute_exists(id)"),
+// setVersionValue stores v in an integer version field.
+func setVersionValue(field reflect.Value, v int64) {
+	if field.CanInt() {
+		field.SetInt(v)
+		return
 	}
+	field.SetUint(uint64(v))
+}
 
+// initVersion sets the version attribute of a new item to 1, both in the
+// marshaled item and, when item is a pointer, in the struct itself.
+func initVersion(item interface{}, av map[string]types.AttributeValue) error {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	version, err := versionField(val.Type())
+	if err != nil || version == nil {
+		return err
+	}
+	av[version.tag.AttributeName] = &types.AttributeValueMemberN{Value: "1"}
+	if val.CanAddr() {
+		setVersionValue(version.settable(val), 1)
+	}
+	return nil
+}
+
+// incrementVersion advances the version field of an updated item when item
+// is a pointer, so that the struct can be updated again.
+func incrementVersion(item interface{}) error {
+	val := reflect.ValueOf(item)
+	if val.Kind() != reflect.Ptr {
+		return nil
+	}
+	val = val.Elem()
+	version, err := versionField(val.Type())
+	if err != nil || version == nil {
+		return err
+	}
+	field := version.settable(val)
+	setVersionValue(field, versionValue(field)+1)
+	return nil
+}
+
+// Delete deletes an item from DynamoDB.
+//
+// When item is a struct (or a pointer to one), the key is built from its
+// `key=hash` and `key=range` fields and the table name is resolved the same
+// way as for Create. Any other value is treated as the id of an item in the
+// default table whose primary key attribute is named "id".
+// A conditional expression is used to ensure that the item exists.
+// The BeforeDelete hook of a struct item is called first.
+//
+// When the model declares a `softDelete` field, the item is kept and only
+// that field, and the `ttl=` field if any, are set; reads then skip the item
+// unless WithDeleted is passed. Deleting an item that is already soft deleted
+// returns ErrNotFound. Use Purge to remove the item itself.
+func (r *Repository) Delete(ctx context.Context, item interface{}) error {
+	if err := beforeDelete(ctx, item); err != nil {
+		return err
+	}
+	now := r.now()
+	input, err := r.softDeleteInput(item, now)
+	if err != nil {
+		return err
+	}
+	if input != nil {
+		return r.softDelete(ctx, item, input, now)
+	}
+	return r.deleteItem(ctx, item)
+}
+
+// deleteItem deletes item with DeleteItem.
+func (r *Repository) deleteItem(ctx context.Context, item interface{}) error {
+	input, err := r.deleteInput(item)
+	if err != nil {
+		return err
+	}
 	_, err = r.client.DeleteItem(ctx, input)
 	if err != nil {
 		var ccf *types.ConditionalCheckFailedException
 		if errors.As(err, &ccf) {
+			if err := r.tenantError(item, ccf.Item); err != nil {
+				return err
+			}
 			return ErrNotFound
 		}
 		return fmt.Errorf("failed to delete item: %w", err)
 	}
 	return nil
 }
+
+// deleteInput builds the DeleteItem request used by Delete.
+func (r *Repository) deleteInput(item interface{}) (*dynamodb.DeleteItemInput, error) {
+	tableName, key, keyAttr, err := r.deleteKey(item)
+	if err != nil {
+		return nil, err
+	}
+	b := newExpressionBuilder()
+	condition := fmt.Sprintf("attribute_exists(%s)", b.name(keyAttr))
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key:       key,
+	}
+	if r.tenant != "" {
+		meta, err := metadataOf(reflect.Indirect(reflect.ValueOf(item)).Type())
+		if err != nil {
+			return nil, err
+		}
+		tenantCondition, err := r.tenantCondition(b, meta)
+		if err != nil {
+			return nil, err
+		}
+		condition += " AND " + tenantCondition
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	input.ConditionExpression = aws.String(condition)
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	return input, nil
+}
+
+// deleteKey resolves the table name, primary key and hash key attribute of
+// the item passed to Delete or BatchDelete.
+func (r *Repository) deleteKey(item interface{}) (string, map[string]types.AttributeValue, string, error) {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if r.tenant != "" {
+		scoped, err := r.scopeItem(item)
+		if err != nil {
+			return "", nil, "", err
+		}
+		val = reflect.Indirect(reflect.ValueOf(scoped))
+	}
+	if val.Kind() == reflect.Struct {
+		key, keyAttr, err := primaryKey(val)
+		if err != nil {
+			return "", nil, "", err
+		}
+		return r.getTableName(item), key, keyAttr, nil
+	}
+	// Marshal the id value.
+	idAttr, err := attributevalue.Marshal(item)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to marshal key: %w", err)
+	}
+	return r.tableName, map[string]types.AttributeValue{"id": idAttr}, "id", nil
+}
@@ -0,0 +1,63 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+func TestRepository_Batch_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users", db.WithBatchConcurrency(2))
+	err := createUsersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	// 25件と100件の上限をまたぐ件数で試す
+	users := make([]User, 130)
+	ids := make([]string, len(users))
+	for i := range users {
+		users[i] = *generateTestUser(fmt.Sprintf("batch-%03d", i))
+		ids[i] = users[i].ID
+	}
+
+	t.Run("BatchCreate and BatchFindByIDs", func(t *testing.T) {
+		require.NoError(t, repo.BatchCreate(context.Background(), users))
+
+		var found []User
+		err := repo.BatchFindByIDs(context.Background(), append(ids, "non-existing-id", ids[0]), &found)
+		require.NoError(t, err)
+		require.Len(t, found, len(users))
+		for i := range users {
+			assert.Equal(t, users[i].ID, found[i].ID)
+		}
+	})
+
+	t.Run("BatchCreate validates every item first", func(t *testing.T) {
+		invalid := []*User{generateTestUser("batch-valid"), {ID: "batch-invalid"}}
+		err := repo.BatchCreate(context.Background(), invalid)
+		assert.ErrorContains(t, err, "validation error")
+
+		var found []User
+		require.NoError(t, repo.BatchFindByIDs(context.Background(), []string{invalid[0].ID}, &found))
+		assert.Empty(t, found)
+	})
+
+	t.Run("BatchDelete", func(t *testing.T) {
+		require.NoError(t, repo.BatchDelete(context.Background(), users))
+
+		var found []User
+		require.NoError(t, repo.BatchFindByIDs(context.Background(), ids, &found))
+		assert.Empty(t, found)
+	})
+}
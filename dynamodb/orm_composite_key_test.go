@@ -0,0 +1,130 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type Order struct {
+	UserID    string `json:"user_id" dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID   string `json:"order_id" dynamodbav:"order_id" dynamo:"order_id,key=range"`
+	Total     int64  `json:"total" dynamodbav:"total" dynamo:"total"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+}
+
+func (o *Order) TableName() string {
+	return "Orders"
+}
+
+func createOrdersTable(client *dynamodb.Client) error {
+	_, err := client.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("user_id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("order_id"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("user_id"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("order_id"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		},
+		TableName: aws.String("Orders"),
+	})
+	return err
+}
+
+func generateTestOrder(userID string) *Order {
+	return &Order{
+		UserID:    userID,
+		OrderID:   fmt.Sprintf("order-%s", time.Now().Format("20060102150405.000000")),
+		Total:     1200,
+		CreatedAt: time.Now().Unix(),
+	}
+}
+
+func TestRepository_CompositeKey_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Orders")
+	err := createOrdersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+	userID := generateTestUser("composite").ID
+
+	t.Run("Create guards both key attributes", func(t *testing.T) {
+		order := generateTestOrder(userID)
+		require.NoError(t, repo.Create(context.Background(), order))
+
+		err := repo.Create(context.Background(), order)
+		assert.ErrorIs(t, err, db.ErrDuplicateKey)
+
+		// 同じパーティションでもソートキーが違えば作成できる
+		other := generateTestOrder(userID)
+		other.OrderID = order.OrderID + "-2"
+		assert.NoError(t, repo.Create(context.Background(), other))
+	})
+
+	t.Run("Find by hash and range key", func(t *testing.T) {
+		order := generateTestOrder(userID)
+		require.NoError(t, repo.Create(context.Background(), order))
+
+		var found Order
+		err := repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found)
+		assert.NoError(t, err)
+		assert.Equal(t, order.Total, found.Total)
+
+		err = repo.FindByKey(context.Background(), order.UserID, "non-existing-order", &found)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+
+		// FindByID cannot address an item without its range key
+		err = repo.FindByID(context.Background(), order.UserID, &found)
+		assert.Error(t, err)
+	})
+
+	t.Run("Update and delete with composite key", func(t *testing.T) {
+		order := generateTestOrder(userID)
+		require.NoError(t, repo.Create(context.Background(), order))
+
+		order.Total = 3400
+		require.NoError(t, repo.Update(context.Background(), order))
+
+		var found Order
+		require.NoError(t, repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found))
+		assert.Equal(t, int64(3400), found.Total)
+
+		require.NoError(t, repo.Delete(context.Background(), order))
+		err := repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+
+		err = repo.Delete(context.Background(), order)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+}
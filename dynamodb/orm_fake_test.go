@@ -0,0 +1,647 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+	"github.com/yuki5155/go-aws/dynamodb/dynamodbtest"
+	"github.com/yuki5155/go-aws/lambda"
+)
+
+// setupFakeRepository returns a repository backed by an in-memory client
+// with the Users and Orders tables created from their models.
+func setupFakeRepository(t *testing.T) (*dynamodbtest.Client, *db.Repository) {
+	t.Helper()
+	client := dynamodbtest.NewClient()
+	repo := db.NewRepository(client, "Users")
+	require.NoError(t, repo.CreateTable(context.Background(), &User{}))
+	require.NoError(t, repo.CreateTable(context.Background(), &Order{}))
+	return client, repo
+}
+
+func TestRepository_CRUD_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+
+	user := &User{ID: "user-1", Email: "user-1@example.com", Name: "User 1"}
+	require.NoError(t, repo.Create(ctx, user))
+	assert.ErrorIs(t, repo.Create(ctx, user), db.ErrDuplicateKey)
+	assert.Len(t, client.Items("Users"), 1)
+
+	var found User
+	require.NoError(t, repo.FindByID(ctx, "user-1", &found))
+	assert.Equal(t, *user, found)
+
+	user.Name = "Updated"
+	require.NoError(t, repo.Update(ctx, user))
+	require.NoError(t, repo.FindByID(ctx, "user-1", &found))
+	assert.Equal(t, "Updated", found.Name)
+	assert.ErrorIs(t, repo.Update(ctx, &User{ID: "missing", Email: "x@example.com", Name: "x"}), db.ErrNotFound)
+
+	require.NoError(t, repo.Delete(ctx, "user-1"))
+	assert.ErrorIs(t, repo.Delete(ctx, "user-1"), db.ErrNotFound)
+	assert.ErrorIs(t, repo.FindByID(ctx, "user-1", &found), db.ErrNotFound)
+}
+
+func TestRepository_FindByParameter_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+	// 1回の呼び出しで読む件数を制限してページングを確認する
+	client.PageSize = 2
+
+	for i := 0; i < 5; i++ {
+		user := &User{ID: fmt.Sprintf("user-%d", i), Email: "shared@example.com", Name: fmt.Sprintf("User %d", i)}
+		require.NoError(t, repo.Create(ctx, user))
+	}
+
+	var byIndex []User
+	require.NoError(t, repo.FindByParameter(ctx, "email", "shared@example.com", &byIndex))
+	assert.Len(t, byIndex, 5)
+
+	var byScan []User
+	require.NoError(t, repo.FindByParameter(ctx, "name", "User 3", &byScan))
+	require.Len(t, byScan, 1)
+	assert.Equal(t, "user-3", byScan[0].ID)
+
+	var all []User
+	require.NoError(t, repo.GetAll(ctx, &all))
+	assert.Len(t, all, 5)
+}
+
+func TestRepository_GetPage_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	for i := 0; i < 7; i++ {
+		user := &User{ID: fmt.Sprintf("user-%d", i), Email: fmt.Sprintf("user-%d@example.com", i), Name: "User"}
+		require.NoError(t, repo.Create(ctx, user))
+	}
+
+	seen := map[string]bool{}
+	pages := 0
+	cursor := ""
+	for {
+		var page []User
+		next, err := repo.GetPage(ctx, 3, cursor, &page)
+		require.NoError(t, err)
+		pages++
+		for _, u := range page {
+			assert.False(t, seen[u.ID], "duplicate user %s", u.ID)
+			seen[u.ID] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Len(t, seen, 7)
+	assert.Equal(t, 3, pages)
+}
+
+func TestRepository_Query_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	for i := 1; i <= 5; i++ {
+		order := &Order{UserID: "user-1", OrderID: fmt.Sprintf("order-%d", i), Total: int64(i * 100)}
+		require.NoError(t, repo.Create(ctx, order))
+	}
+	require.NoError(t, repo.Create(ctx, &Order{UserID: "user-2", OrderID: "order-1", Total: 100}))
+
+	var orders []Order
+	err := repo.Query("user_id", "user-1").
+		Where(db.Between("order_id", "order-2", "order-4")).
+		Filter(db.GreaterThan("total", 200)).
+		Descending().
+		All(ctx, &orders)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "order-4", orders[0].OrderID)
+	assert.Equal(t, "order-3", orders[1].OrderID)
+
+	var found Order
+	require.NoError(t, repo.FindByKey(ctx, "user-2", "order-1", &found))
+	assert.Equal(t, int64(100), found.Total)
+}
+
+func TestRepository_Transaction_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+
+	user := &User{ID: "user-1", Email: "user-1@example.com", Name: "User 1"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	err := repo.Transaction().
+		Create(&Order{UserID: "user-1", OrderID: "order-1", Total: 100}).
+		Create(user).
+		Commit(ctx)
+	var txErr *db.TransactionError
+	require.ErrorAs(t, err, &txErr)
+	assert.ErrorIs(t, err, db.ErrDuplicateKey)
+	require.Len(t, txErr.Failures, 1)
+	assert.Equal(t, 1, txErr.Failures[0].Index)
+	assert.Empty(t, client.Items("Orders"), "a cancelled transaction writes nothing")
+
+	err = repo.Transaction().
+		Create(&Order{UserID: "user-1", OrderID: "order-1", Total: 100}).
+		ConditionCheck(user, db.Equal("name", "User 1")).
+		Commit(ctx)
+	require.NoError(t, err)
+	assert.Len(t, client.Items("Orders"), 1)
+}
+
+func TestRepository_Version_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	order := &VersionedOrder{UserID: "user-1", OrderID: "order-1", Total: 100}
+	require.NoError(t, repo.Create(ctx, order))
+
+	stale := *order
+	order.Total = 200
+	require.NoError(t, repo.Update(ctx, order))
+	assert.Equal(t, int64(2), order.Version)
+
+	stale.Total = 300
+	assert.ErrorIs(t, repo.Update(ctx, &stale), db.ErrVersionConflict)
+}
+
+func TestRepository_Patch_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	user := &PatchedUser{ID: "user-1", Email: "user-1@example.com", Name: "User 1", Nickname: "u1"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	patched := &PatchedUser{ID: "user-1"}
+	update := db.NewUpdate().
+		Add("logins", 1).
+		Append("roles", []string{"admin"}).
+		Remove("nickname").
+		If(db.Equal("name", "User 1")).
+		Returning(types.ReturnValueAllNew)
+	require.NoError(t, repo.Patch(ctx, patched, update))
+	assert.Equal(t, "User 1", patched.Name)
+	assert.Equal(t, 1, patched.Logins)
+	assert.Equal(t, []string{"admin"}, patched.Roles)
+	assert.Empty(t, patched.Nickname)
+
+	err := repo.Patch(ctx, &PatchedUser{ID: "user-1"}, db.NewUpdate().Set("name", "x").If(db.Equal("name", "other")))
+	assert.ErrorIs(t, err, db.ErrConditionFailed)
+	err = repo.Patch(ctx, &PatchedUser{ID: "missing"}, db.NewUpdate().Set("name", "x"))
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func TestRepository_Batch_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	users := make([]User, 60)
+	ids := make([]string, 0, len(users))
+	for i := range users {
+		users[i] = User{ID: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user-%02d@example.com", i), Name: "User"}
+		ids = append(ids, users[i].ID)
+	}
+	require.NoError(t, repo.BatchCreate(ctx, users))
+
+	var found []User
+	require.NoError(t, repo.BatchFindByIDs(ctx, append(ids, "missing"), &found))
+	require.Len(t, found, 60)
+	assert.Equal(t, "user-00", found[0].ID)
+	assert.Equal(t, "user-59", found[59].ID)
+
+	require.NoError(t, repo.BatchDelete(ctx, ids))
+	var all []User
+	require.NoError(t, repo.GetAll(ctx, &all))
+	assert.Empty(t, all)
+}
+
+func TestRepository_ReadOptions_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+
+	user := &User{ID: "user-1", Email: "user-1@example.com", Name: "User 1"}
+	require.NoError(t, repo.Create(ctx, user))
+
+	var found User
+	require.NoError(t, repo.FindByID(ctx, "user-1", &found, db.WithConsistentRead(), db.WithProjection("Name")))
+	assert.Equal(t, User{Name: "User 1"}, found)
+
+	var users []User
+	require.NoError(t, repo.FindByParameter(ctx, "email", user.Email, &users, db.WithProjection("ID", "email")))
+	require.Len(t, users, 1)
+	assert.Equal(t, User{ID: "user-1", Email: user.Email}, users[0])
+
+	err := repo.FindByID(ctx, "user-1", &found, db.WithTable("Missing"))
+	assert.Error(t, err)
+}
+
+// ValidatedUser declares validation rules beyond required.
+type ValidatedUser struct {
+	ID    string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Email string `json:"email" dynamodbav:"email" dynamo:"email,required,email,index=email-index"`
+	Name  string `json:"name" dynamodbav:"name" dynamo:"name,required,min=2,max=20"`
+	Role  string `json:"role" dynamodbav:"role" dynamo:"role,oneof=admin member"`
+}
+
+func (u *ValidatedUser) TableName() string {
+	return "Users"
+}
+
+func TestRepository_Validation_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+
+	err := repo.Create(ctx, &ValidatedUser{ID: "user-1", Email: "not-an-email", Name: "A", Role: "owner"})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Errors, 3)
+	assert.Empty(t, client.Items("Users"))
+
+	// ハンドラーはフィールドごとの詳細付きで400を返せる
+	response := lambda.NewValidationFailedError("invalid user", err).ToAPIGatewayResponse()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.JSONEq(t, `{
+		"message": "invalid user",
+		"fields": {
+			"email": ["must be a valid email address"],
+			"name": ["must be at least 2 characters"],
+			"role": ["must be one of admin, member"]
+		}
+	}`, response.Body)
+
+	user := &ValidatedUser{ID: "user-1", Email: "user-1@example.com", Name: "User 1", Role: "member"}
+	require.NoError(t, repo.Create(ctx, user))
+	user.Role = "owner"
+	err = repo.Update(ctx, user)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "role", validationErr.Errors[0].Attribute)
+
+	// Patchも同じルールで検証される
+	key := &ValidatedUser{ID: "user-1"}
+	err = repo.Patch(ctx, key, db.NewUpdate().Set("email", "bad").Set("name", "A"))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string][]string{
+		"email": {"must be a valid email address"},
+		"name":  {"must be at least 2 characters"},
+	}, validationErr.FieldErrors())
+	err = repo.Patch(ctx, key, db.NewUpdate().Remove("name"))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string][]string{"name": {"is required"}}, validationErr.FieldErrors())
+	require.NoError(t, repo.Patch(ctx, key, db.NewUpdate().Set("email", "new@example.com").Remove("role")))
+
+	var found ValidatedUser
+	require.NoError(t, repo.FindByID(ctx, "user-1", &found))
+	assert.Equal(t, ValidatedUser{ID: "user-1", Email: "new@example.com", Name: "User 1"}, found)
+}
+
+// BaseEntity is a common base type embedded by models.
+type BaseEntity struct {
+	ID        string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Version   int    `json:"version" dynamodbav:"version" dynamo:"version,version"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	UpdatedAt int64  `json:"updated_at" dynamodbav:"updated_at" dynamo:"updated_at,autoUpdateTime"`
+}
+
+type Address struct {
+	City    string `json:"city" dynamodbav:"city" dynamo:"city,required"`
+	Country string `json:"country" dynamodbav:"country" dynamo:"country,len=2"`
+}
+
+// Customer embeds BaseEntity and holds nested addresses.
+type Customer struct {
+	BaseEntity
+	Email    string    `json:"email" dynamodbav:"email" dynamo:"email,required,email,index=email-index"`
+	Address  Address   `json:"address" dynamodbav:"address"`
+	Shipping []Address `json:"shipping" dynamodbav:"shipping"`
+}
+
+func (c *Customer) TableName() string {
+	return "Users"
+}
+
+func TestRepository_Embedded_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+
+	customer := &Customer{
+		BaseEntity: BaseEntity{ID: "customer-1"},
+		Email:      "customer-1@example.com",
+		Address:    Address{City: "Tokyo", Country: "JP"},
+	}
+	require.NoError(t, repo.Create(ctx, customer))
+	assert.Equal(t, 1, customer.Version)
+	assert.NotZero(t, customer.CreatedAt)
+
+	var found Customer
+	require.NoError(t, repo.FindByID(ctx, "customer-1", &found))
+	assert.Equal(t, *customer, found)
+
+	// 埋め込み構造体のキーで重複作成を防ぐ
+	err := repo.Create(ctx, &Customer{BaseEntity: BaseEntity{ID: "customer-1"}, Email: "other@example.com", Address: Address{City: "Osaka"}})
+	assert.ErrorIs(t, err, db.ErrDuplicateKey)
+
+	customer.Address.City = "Kyoto"
+	require.NoError(t, repo.Update(ctx, customer))
+	assert.Equal(t, 2, customer.Version)
+	stale := found
+	stale.Address.City = "Nara"
+	assert.ErrorIs(t, repo.Update(ctx, &stale), db.ErrVersionConflict)
+
+	// ネストした構造体も検証される
+	customer.Address.City = ""
+	customer.Shipping = []Address{{City: "Sapporo", Country: "JPN"}}
+	err = repo.Update(ctx, customer)
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string][]string{
+		"address.city":        {"is required"},
+		"shipping[0].country": {"must be exactly 2 characters"},
+	}, validationErr.FieldErrors())
+	assert.Equal(t, "Address.City", validationErr.Errors[0].Field)
+
+	require.NoError(t, repo.Delete(ctx, customer))
+	assert.Empty(t, client.Items("Users"))
+}
+
+func TestTypedRepository_PutThenUpdate_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, repo := setupFakeRepository(t)
+	customers, err := db.NewTypedRepository[Customer](repo)
+	require.NoError(t, err)
+
+	customer := &Customer{BaseEntity: BaseEntity{ID: "customer-1"}, Email: "customer-1@example.com", Address: Address{City: "Tokyo"}}
+	require.NoError(t, customers.Put(ctx, customer))
+	assert.Equal(t, 1, customer.Version)
+	assert.NotZero(t, customer.CreatedAt)
+
+	// Putで書き込んだバージョンのままUpdateできる
+	customer.Email = "customer-1@example.jp"
+	require.NoError(t, customers.Update(ctx, customer))
+	assert.Equal(t, 2, customer.Version)
+
+	found, err := customers.Get(ctx, "customer-1")
+	require.NoError(t, err)
+	assert.Equal(t, *customer, found)
+}
+
+// Member is soft deleted and expires 30 days later through DynamoDB TTL.
+type Member struct {
+	ID        string     `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Email     string     `json:"email" dynamodbav:"email" dynamo:"email,required,index=email-index"`
+	Name      string     `json:"name" dynamodbav:"name" dynamo:"name"`
+	DeletedAt *time.Time `json:"deleted_at" dynamodbav:"deleted_at" dynamo:"deleted_at,softDelete"`
+	ExpiresAt int64      `json:"expires_at" dynamodbav:"expires_at" dynamo:"expires_at,ttl=720h"`
+}
+
+func (m *Member) TableName() string {
+	return "Users"
+}
+
+func TestRepository_SoftDelete_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	client := dynamodbtest.NewClient()
+	repo := db.NewRepository(client, "Users", db.WithClock(func() time.Time { return now }))
+	require.NoError(t, repo.CreateTable(ctx, &Member{}))
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, repo.Create(ctx, &Member{ID: fmt.Sprintf("member-%d", i), Email: "team@example.com"}))
+	}
+	member := &Member{ID: "member-1"}
+	require.NoError(t, repo.Delete(ctx, member))
+	require.NotNil(t, member.DeletedAt)
+	assert.Equal(t, now.AddDate(0, 0, 30).Unix(), member.ExpiresAt)
+	assert.Len(t, client.Items("Users"), 3)
+
+	// 論理削除されたアイテムは既定で読み取りから除外される
+	var found Member
+	assert.ErrorIs(t, repo.FindByID(ctx, "member-1", &found), db.ErrNotFound)
+	require.NoError(t, repo.FindByID(ctx, "member-1", &found, db.WithDeleted()))
+	assert.Equal(t, now, *found.DeletedAt)
+
+	var members []Member
+	require.NoError(t, repo.GetAll(ctx, &members))
+	assert.Len(t, members, 2)
+	require.NoError(t, repo.FindByParameter(ctx, "email", "team@example.com", &members))
+	assert.Len(t, members, 2)
+	require.NoError(t, repo.FindByParameter(ctx, "email", "team@example.com", &members, db.WithDeleted()))
+	assert.Len(t, members, 3)
+	require.NoError(t, repo.BatchFindByIDs(ctx, []string{"member-1", "member-2"}, &members))
+	assert.Len(t, members, 1)
+	var scanned atomic.Int32
+	count := func(item interface{}) error {
+		scanned.Add(1)
+		return nil
+	}
+	require.NoError(t, repo.ParallelScan(ctx, &Member{}, count))
+	assert.Equal(t, int32(2), scanned.Load())
+	require.NoError(t, repo.ParallelScan(ctx, &Member{}, count, db.ScanWithDeleted()))
+	assert.Equal(t, int32(5), scanned.Load())
+
+	// Deleted items can be neither deleted again nor updated.
+	assert.ErrorIs(t, repo.Delete(ctx, &Member{ID: "member-1"}), db.ErrNotFound)
+	assert.ErrorIs(t, repo.Update(ctx, &Member{ID: "member-1", Email: "new@example.com"}), db.ErrNotFound)
+	assert.ErrorIs(t, repo.Patch(ctx, &Member{ID: "member-1"}, db.NewUpdate().Set("name", "x")), db.ErrNotFound)
+	update := db.NewUpdate().Set("name", "x").If(db.Equal("email", "team@example.com"))
+	assert.ErrorIs(t, repo.Patch(ctx, &Member{ID: "member-1"}, update), db.ErrNotFound)
+	assert.ErrorContains(t, repo.BatchDelete(ctx, []Member{{ID: "member-2"}}), "BatchDelete cannot soft delete")
+
+	require.NoError(t, repo.Transaction().Delete(&Member{ID: "member-2"}).Commit(ctx))
+	require.NoError(t, repo.GetAll(ctx, &members))
+	assert.Len(t, members, 1)
+
+	// Patch restores a deleted item by removing its softDelete attribute.
+	restore := db.NewUpdate().Remove("deleted_at").Remove("expires_at")
+	require.NoError(t, repo.Patch(ctx, &Member{ID: "member-2"}, restore))
+	require.NoError(t, repo.GetAll(ctx, &members))
+	assert.Len(t, members, 2)
+
+	// Purge removes the item itself.
+	require.NoError(t, repo.Purge(ctx, &Member{ID: "member-1"}))
+	assert.Len(t, client.Items("Users"), 2)
+	assert.ErrorIs(t, repo.Purge(ctx, &Member{ID: "member-1"}), db.ErrNotFound)
+}
+
+// Invoice belongs to the tenant in its partition key.
+type Invoice struct {
+	TenantID string `json:"tenant_id" dynamodbav:"tenant_id" dynamo:"tenant_id,key=hash,tenant"`
+	ID       string `json:"id" dynamodbav:"id" dynamo:"id,key=range"`
+	Total    int    `json:"total" dynamodbav:"total" dynamo:"total"`
+}
+
+func (i *Invoice) TableName() string {
+	return "Invoices"
+}
+
+func TestRepository_Tenant_Fake(t *testing.T) {
+	t.Parallel()
+	client := dynamodbtest.NewClient()
+	repo := db.NewRepository(client, "Invoices")
+	require.NoError(t, repo.CreateTable(context.Background(), &Invoice{}))
+	tenants := db.NewTenantRepository(repo)
+	acme := db.WithTenant(context.Background(), "acme")
+	globex := db.WithTenant(context.Background(), "globex")
+
+	// テナントはコンテキストから書き込み時にパーティションキーへ設定される
+	invoice := &Invoice{ID: "inv-1", Total: 100}
+	require.NoError(t, tenants.Create(acme, invoice))
+	assert.Equal(t, "acme", invoice.TenantID)
+	// Each tenant has its own ids, so an id used by another tenant is free.
+	require.NoError(t, tenants.Create(globex, &Invoice{ID: "inv-1", Total: 200}))
+	assert.ErrorIs(t, tenants.Create(acme, &Invoice{ID: "inv-3", TenantID: "globex"}), db.ErrCrossTenant)
+	assert.ErrorIs(t, tenants.Create(context.Background(), &Invoice{ID: "inv-3"}), db.ErrTenantRequired)
+
+	var found Invoice
+	require.NoError(t, tenants.FindByKey(acme, "acme", "inv-1", &found))
+	assert.Equal(t, *invoice, found)
+	var crossTenant *db.CrossTenantError
+	require.ErrorAs(t, tenants.FindByKey(globex, "acme", "inv-1", &found), &crossTenant)
+	assert.Equal(t, "globex", crossTenant.Tenant)
+	// The hash key is filled from the context: FindByID takes the range key.
+	require.NoError(t, tenants.FindByID(globex, "inv-1", &found))
+	assert.Equal(t, 200, found.Total)
+	require.NoError(t, tenants.FindByKey(acme, "", "inv-1", &found))
+	assert.Equal(t, 100, found.Total)
+	assert.ErrorIs(t, tenants.FindByID(acme, "inv-2", &found), db.ErrNotFound)
+
+	var invoices []Invoice
+	require.NoError(t, tenants.GetAll(acme, &invoices))
+	require.Len(t, invoices, 1)
+	assert.Equal(t, 100, invoices[0].Total)
+	require.NoError(t, tenants.Query(globex, "tenant_id", "globex").All(globex, &invoices))
+	require.Len(t, invoices, 1)
+	assert.Equal(t, 200, invoices[0].Total)
+	assert.ErrorIs(t, tenants.Query(globex, "tenant_id", "acme").All(globex, &invoices), db.ErrCrossTenant)
+
+	var scanned []int
+	require.NoError(t, tenants.ParallelScan(acme, &Invoice{}, func(item interface{}) error {
+		scanned = append(scanned, item.(*Invoice).Total)
+		return nil
+	}, db.WithSegments(1)))
+	assert.Equal(t, []int{100}, scanned)
+
+	// Writes to an item of another tenant are refused and leave it unchanged.
+	other := &Invoice{TenantID: "acme", ID: "inv-1", Total: 1}
+	assert.ErrorIs(t, tenants.Update(globex, other), db.ErrCrossTenant)
+	assert.ErrorIs(t, tenants.Patch(globex, other, db.NewUpdate().Set("total", 1)), db.ErrCrossTenant)
+	assert.ErrorIs(t, tenants.Delete(globex, other), db.ErrCrossTenant)
+	assert.ErrorContains(t, tenants.Delete(globex, "inv-1"), "a tenant-scoped item must be a struct")
+	assert.ErrorIs(t, tenants.Transaction(globex).Update(other).Commit(globex), db.ErrCrossTenant)
+	assert.ErrorIs(t, tenants.Update(globex, &Invoice{ID: "inv-2", Total: 1}), db.ErrNotFound)
+	require.NoError(t, repo.FindByKey(context.Background(), "acme", "inv-1", &found))
+	assert.Equal(t, 100, found.Total)
+
+	invoice.Total = 150
+	require.NoError(t, tenants.Update(acme, invoice))
+	require.NoError(t, tenants.BatchCreate(acme, []Invoice{{ID: "inv-4", Total: 400}, {ID: "inv-5", Total: 500}}))
+
+	// 複合キーのモデルでは ID はレンジキーとして扱われる
+	require.NoError(t, tenants.BatchFindByIDs(acme, []string{"inv-5", "inv-1", "inv-9"}, &invoices))
+	require.Len(t, invoices, 2)
+	assert.Equal(t, []int{500, 150}, []int{invoices[0].Total, invoices[1].Total})
+	require.NoError(t, tenants.BatchFindByIDs(globex, []string{"inv-1", "inv-5"}, &invoices))
+	require.Len(t, invoices, 1)
+	assert.Equal(t, 200, invoices[0].Total)
+
+	// BatchDelete only deletes items of the tenant.
+	assert.ErrorIs(t, tenants.BatchDelete(globex, []Invoice{{TenantID: "acme", ID: "inv-4"}}), db.ErrCrossTenant)
+	assert.ErrorContains(t, tenants.BatchDelete(globex, []string{"inv-4"}), "a tenant-scoped item must be a struct")
+	require.NoError(t, tenants.BatchDelete(globex, []Invoice{{ID: "inv-4"}, {ID: "inv-5"}}))
+	assert.Len(t, client.Items("Invoices"), 4)
+	require.NoError(t, tenants.BatchDelete(acme, []Invoice{{ID: "inv-4"}, {ID: "inv-5"}}))
+
+	require.NoError(t, tenants.Delete(acme, &Invoice{ID: "inv-1"}))
+	assert.ErrorIs(t, tenants.Delete(acme, &Invoice{ID: "inv-1"}), db.ErrNotFound)
+	assert.Len(t, client.Items("Invoices"), 1)
+}
+
+func TestRepository_RetryPolicy_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := dynamodbtest.NewClient()
+	repo := db.NewRepository(client, "Users", db.WithRetryPolicy(db.DefaultRetryPolicy()))
+
+	// Table management still reaches the wrapped client.
+	require.NoError(t, repo.CreateTable(ctx, &User{}))
+	user := &User{ID: "user-1", Email: "user-1@example.com", Name: "User 1"}
+	require.NoError(t, repo.Create(ctx, user))
+	assert.ErrorIs(t, repo.Create(ctx, user), db.ErrDuplicateKey)
+}
+
+// Patient stores its SSN and notes encrypted.
+type Patient struct {
+	ID    string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Name  string `json:"name" dynamodbav:"name" dynamo:"name"`
+	SSN   string `json:"ssn" dynamodbav:"ssn" dynamo:"ssn,encrypt"`
+	Notes []byte `json:"notes" dynamodbav:"notes" dynamo:"notes,encrypt"`
+}
+
+func (p *Patient) TableName() string {
+	return "Patients"
+}
+
+func TestRepository_Encrypt_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := dynamodbtest.NewClient()
+	provider, err := db.NewStaticKeyProvider(make([]byte, 32))
+	require.NoError(t, err)
+	repo := db.NewRepository(client, "Patients", db.WithKeyProvider(provider))
+	require.NoError(t, repo.CreateTable(ctx, &Patient{}))
+
+	patient := &Patient{ID: "patient-1", Name: "Alice", SSN: "123-45-6789", Notes: []byte("allergic")}
+	require.NoError(t, repo.Create(ctx, patient))
+
+	// テーブルには暗号文のみが保存される
+	stored := client.Items("Patients")[0]
+	assert.IsType(t, &types.AttributeValueMemberB{}, stored["ssn"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "Alice"}, stored["name"])
+
+	var found Patient
+	require.NoError(t, repo.FindByID(ctx, "patient-1", &found))
+	assert.Equal(t, *patient, found)
+
+	patient.SSN = "987-65-4321"
+	require.NoError(t, repo.Update(ctx, patient))
+	require.NoError(t, repo.Transaction().Create(&Patient{ID: "patient-2", SSN: "555-55-5555"}).Commit(ctx))
+	require.NoError(t, repo.BatchCreate(ctx, []Patient{{ID: "patient-3", SSN: "444-44-4444"}}))
+
+	var patients []Patient
+	require.NoError(t, repo.GetAll(ctx, &patients))
+	ssns := map[string]string{}
+	for _, p := range patients {
+		ssns[p.ID] = p.SSN
+	}
+	assert.Equal(t, map[string]string{"patient-1": "987-65-4321", "patient-2": "555-55-5555", "patient-3": "444-44-4444"}, ssns)
+	require.NoError(t, repo.BatchFindByIDs(ctx, []string{"patient-1"}, &patients, db.WithProjection("SSN")))
+	require.Len(t, patients, 1)
+	assert.Equal(t, "987-65-4321", patients[0].SSN)
+
+	// 別のキーでは復号できない
+	other, err := db.NewStaticKeyProvider([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, db.NewRepository(client, "Patients", db.WithKeyProvider(other)).FindByID(ctx, "patient-1", &found), db.ErrDecryption)
+	assert.ErrorContains(t, db.NewRepository(client, "Patients").FindByID(ctx, "patient-1", &found), "set a KeyProvider")
+}
@@ -0,0 +1,240 @@
+package dynamodb_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+	"github.com/yuki5155/go-aws/dynamodb/dynamodbtest"
+)
+
+type actorKey struct{}
+
+var errProtected = errors.New("user is protected")
+
+// HookedUser normalizes its email, derives its ID and redacts its token via hooks.
+type HookedUser struct {
+	ID        string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Email     string `json:"email" dynamodbav:"email" dynamo:"email,required,index=email-index"`
+	Name      string `json:"name" dynamodbav:"name" dynamo:"name,required"`
+	Token     string `json:"token" dynamodbav:"token" dynamo:"token"`
+	CreatedBy string `json:"created_by" dynamodbav:"created_by" dynamo:"created_by"`
+	Protected bool   `json:"protected" dynamodbav:"protected" dynamo:"protected"`
+}
+
+func (u *HookedUser) TableName() string {
+	return "Users"
+}
+
+func (u *HookedUser) BeforeCreate(ctx context.Context) error {
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+	if u.ID == "" {
+		u.ID = "user#" + u.Email
+	}
+	actor, _ := ctx.Value(actorKey{}).(string)
+	u.CreatedBy = actor
+	return nil
+}
+
+func (u *HookedUser) BeforeUpdate(ctx context.Context) error {
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+	return nil
+}
+
+func (u *HookedUser) BeforeDelete(ctx context.Context) error {
+	if u.Protected {
+		return errProtected
+	}
+	return nil
+}
+
+func (u *HookedUser) AfterFind(ctx context.Context) error {
+	if u.Token != "" {
+		u.Token = "***"
+	}
+	return nil
+}
+
+func TestRepository_Hooks_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), actorKey{}, "admin")
+	client, repo := setupFakeRepository(t)
+
+	user := &HookedUser{Email: "  Alice@Example.COM ", Name: "Alice", Token: "secret"}
+	require.NoError(t, repo.Create(ctx, user))
+	assert.Equal(t, "user#alice@example.com", user.ID)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, "admin", user.CreatedBy)
+	// The hook only redacts what is read, the stored token is untouched.
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "secret"}, client.Items("Users")[0]["token"])
+
+	var found HookedUser
+	require.NoError(t, repo.FindByID(ctx, user.ID, &found))
+	assert.Equal(t, "***", found.Token)
+	assert.Equal(t, "alice@example.com", found.Email)
+
+	var byEmail []HookedUser
+	require.NoError(t, repo.FindByParameter(ctx, "email", "alice@example.com", &byEmail))
+	require.Len(t, byEmail, 1)
+	assert.Equal(t, "***", byEmail[0].Token)
+
+	var all []*HookedUser
+	require.NoError(t, repo.GetAll(ctx, &all))
+	require.Len(t, all, 1)
+	assert.Equal(t, "***", all[0].Token)
+
+	found.Email = "ALICE@example.com"
+	found.Token = "rotated"
+	require.NoError(t, repo.Update(ctx, &found))
+	assert.Equal(t, "alice@example.com", found.Email)
+
+	// Patch calls BeforeUpdate too, but only writes the named attributes.
+	patched := &HookedUser{ID: found.ID, Email: "ALICE@EXAMPLE.COM"}
+	require.NoError(t, repo.Patch(ctx, patched, db.NewUpdate().Set("name", "Alice B")))
+	assert.Equal(t, "alice@example.com", patched.Email)
+
+	// Structs passed by value run their hooks on a copy.
+	require.NoError(t, repo.Create(ctx, HookedUser{Email: "BOB@example.com", Name: "Bob", Protected: true}))
+	var bob HookedUser
+	require.NoError(t, repo.FindByID(ctx, "user#bob@example.com", &bob))
+
+	err := repo.Delete(ctx, &bob)
+	assert.ErrorIs(t, err, errProtected)
+	require.NoError(t, repo.FindByID(ctx, bob.ID, &bob))
+
+	require.NoError(t, repo.Delete(ctx, &found))
+	assert.ErrorIs(t, repo.FindByID(ctx, found.ID, &found), db.ErrNotFound)
+}
+
+type failingHookUser struct {
+	ID    string `dynamodbav:"id" dynamo:"id,key=hash"`
+	Email string `dynamodbav:"email" dynamo:"email,index=email-index"`
+	Name  string `dynamodbav:"name" dynamo:"name"`
+}
+
+var errHook = errors.New("hook failed")
+
+func (u *failingHookUser) TableName() string { return "Users" }
+
+func (u *failingHookUser) BeforeCreate(ctx context.Context) error {
+	if u.Name == "" {
+		return errHook
+	}
+	return nil
+}
+
+func (u *failingHookUser) AfterFind(ctx context.Context) error {
+	if u.Name == "broken" {
+		return errHook
+	}
+	return nil
+}
+
+func TestRepository_HookErrors_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+
+	err := repo.Create(ctx, &failingHookUser{ID: "u1"})
+	assert.ErrorIs(t, err, errHook)
+	err = repo.BatchCreate(ctx, []failingHookUser{{ID: "u2", Name: "ok"}, {ID: "u3"}})
+	assert.ErrorIs(t, err, errHook)
+	assert.Empty(t, client.Items("Users"))
+
+	require.NoError(t, repo.Create(ctx, &failingHookUser{ID: "u4", Email: "u4@example.com", Name: "broken"}))
+	var found failingHookUser
+	assert.ErrorIs(t, repo.FindByID(ctx, "u4", &found), errHook)
+	var all []failingHookUser
+	assert.ErrorIs(t, repo.GetAll(ctx, &all), errHook)
+}
+
+func TestTransaction_Hooks_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), actorKey{}, "admin")
+	client, repo := setupFakeRepository(t)
+
+	// トランザクションでもCommitのコンテキストでフックが呼ばれる
+	user := &HookedUser{Email: " Carol@Example.COM", Name: "Carol"}
+	require.NoError(t, repo.Transaction().Create(user).Commit(ctx))
+	assert.Equal(t, "user#carol@example.com", user.ID)
+	assert.Equal(t, "admin", user.CreatedBy)
+
+	user.Email = "CAROL@example.com"
+	require.NoError(t, repo.Transaction().Update(user).Commit(ctx))
+	assert.Equal(t, "carol@example.com", user.Email)
+
+	user.Protected = true
+	require.NoError(t, repo.Update(ctx, user))
+	err := repo.Transaction().Delete(user).Commit(ctx)
+	assert.ErrorIs(t, err, errProtected)
+	err = repo.Transaction().Purge(user).Commit(ctx)
+	assert.ErrorIs(t, err, errProtected)
+	assert.Len(t, client.Items("Users"), 1)
+
+	// A failing hook aborts the whole transaction.
+	err = repo.Transaction().
+		Create(&HookedUser{Email: "dave@example.com", Name: "Dave"}).
+		Create(&failingHookUser{ID: "u1"}).
+		Commit(ctx)
+	assert.ErrorIs(t, err, errHook)
+	assert.Len(t, client.Items("Users"), 1)
+}
+
+// HookedEntry is a single-table entity with hooks.
+type HookedEntry struct {
+	_         struct{} `dynamo:",entity=Entry,pk=ENTRY#{ID},sk=ENTRY"`
+	ID        string   `json:"id" dynamodbav:"id" dynamo:"id"`
+	Token     string   `json:"token" dynamodbav:"token" dynamo:"token"`
+	CreatedBy string   `json:"created_by" dynamodbav:"created_by" dynamo:"created_by"`
+	Protected bool     `json:"protected" dynamodbav:"protected" dynamo:"protected"`
+}
+
+func (e *HookedEntry) BeforeCreate(ctx context.Context) error {
+	e.CreatedBy, _ = ctx.Value(actorKey{}).(string)
+	return nil
+}
+
+func (e *HookedEntry) BeforeDelete(ctx context.Context) error {
+	if e.Protected {
+		return errProtected
+	}
+	return nil
+}
+
+func (e *HookedEntry) AfterFind(ctx context.Context) error {
+	e.Token = "***"
+	return nil
+}
+
+func TestSingleTable_Hooks_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), actorKey{}, "admin")
+	client := dynamodbtest.NewClient()
+	require.NoError(t, createAppTable(client))
+	table := db.NewSingleTable(db.NewRepository(client, "App"), "App")
+	require.NoError(t, table.Register(&HookedEntry{}))
+
+	entry := &HookedEntry{ID: "e1", Token: "secret"}
+	require.NoError(t, table.Create(ctx, entry))
+	assert.Equal(t, "admin", entry.CreatedBy)
+	require.NoError(t, table.Put(ctx, &HookedEntry{ID: "e2", Token: "secret"}))
+
+	found := &HookedEntry{ID: "e1"}
+	require.NoError(t, table.Get(ctx, found))
+	assert.Equal(t, "***", found.Token)
+	assert.Equal(t, "admin", found.CreatedBy)
+
+	items, err := table.Query(ctx, "ENTRY#e2", "")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "***", items[0].(*HookedEntry).Token)
+
+	assert.ErrorIs(t, table.Delete(ctx, &HookedEntry{ID: "e1", Protected: true}), errProtected)
+	require.NoError(t, table.Delete(ctx, &HookedEntry{ID: "e1"}))
+	assert.Len(t, client.Items("App"), 1)
+}
@@ -0,0 +1,76 @@
+package dynamodb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type PatchedUser struct {
+	ID       string   `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Email    string   `json:"email" dynamodbav:"email" dynamo:"email,required,index=email-index"`
+	Name     string   `json:"name" dynamodbav:"name" dynamo:"name,required"`
+	Logins   int      `json:"logins" dynamodbav:"logins" dynamo:"logins"`
+	Roles    []string `json:"roles" dynamodbav:"roles,omitempty" dynamo:"roles"`
+	Nickname string   `json:"nickname" dynamodbav:"nickname,omitempty" dynamo:"nickname"`
+}
+
+func (u *PatchedUser) TableName() string {
+	return "Users"
+}
+
+func TestRepository_Patch_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+	err := createUsersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	base := generateTestUser("patch")
+	user := &PatchedUser{ID: base.ID, Email: base.Email, Name: base.Name, Nickname: "nick"}
+	require.NoError(t, repo.Create(context.Background(), user))
+
+	t.Run("Applies only the given actions and returns the new item", func(t *testing.T) {
+		// キーだけを持つ構造体でも他の属性は上書きされない
+		result := &PatchedUser{ID: user.ID}
+		err := repo.Patch(context.Background(), result, db.NewUpdate().
+			Add("logins", 1).
+			Append("roles", []string{"admin"}).
+			Remove("nickname").
+			Returning(types.ReturnValueAllNew))
+		require.NoError(t, err)
+		assert.Equal(t, user.Name, result.Name)
+		assert.Equal(t, user.Email, result.Email)
+		assert.Equal(t, 1, result.Logins)
+		assert.Equal(t, []string{"admin"}, result.Roles)
+		assert.Empty(t, result.Nickname)
+
+		err = repo.Patch(context.Background(), result, db.NewUpdate().
+			Add("logins", 2).
+			SetIfNotExists("name", "ignored").
+			Returning(types.ReturnValueAllNew))
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Logins)
+		assert.Equal(t, user.Name, result.Name)
+	})
+
+	t.Run("Conditions and missing items", func(t *testing.T) {
+		err := repo.Patch(context.Background(), &PatchedUser{ID: user.ID}, db.NewUpdate().
+			Set("name", "New Name").
+			If(db.Equal("name", "someone else")))
+		assert.ErrorIs(t, err, db.ErrConditionFailed)
+
+		err = repo.Patch(context.Background(), &PatchedUser{ID: "non-existing-id"}, db.NewUpdate().Set("name", "x"))
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+}
@@ -0,0 +1,119 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+func TestRepository_Query_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Orders")
+	err := createOrdersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+	userID := generateTestUser("query").ID
+	for i := 1; i <= 5; i++ {
+		order := &Order{
+			UserID:  userID,
+			OrderID: fmt.Sprintf("2024-%02d", i),
+			Total:   int64(i * 1000),
+		}
+		require.NoError(t, repo.Create(context.Background(), order))
+	}
+	require.NoError(t, repo.Create(context.Background(), &Order{UserID: userID, OrderID: "2023-12", Total: 500}))
+
+	t.Run("Sort key condition", func(t *testing.T) {
+		var orders []Order
+		err := repo.Query("user_id", userID).
+			Where(db.BeginsWith("order_id", "2024-")).
+			All(context.Background(), &orders)
+		require.NoError(t, err)
+		assert.Len(t, orders, 5)
+		assert.Equal(t, "2024-01", orders[0].OrderID)
+	})
+
+	t.Run("Filter, descending order and limit", func(t *testing.T) {
+		var orders []Order
+		err := repo.Query("user_id", userID).
+			Where(db.Between("order_id", "2024-01", "2024-12")).
+			Filter(db.GreaterThanOrEqual("total", 2000)).
+			Descending().
+			Limit(2).
+			All(context.Background(), &orders)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Equal(t, "2024-05", orders[0].OrderID)
+		assert.Equal(t, "2024-04", orders[1].OrderID)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		var first []Order
+		cursor, err := repo.Query("user_id", userID).Page(context.Background(), 4, "", &first)
+		require.NoError(t, err)
+		assert.Len(t, first, 4)
+		require.NotEmpty(t, cursor)
+
+		var second []Order
+		cursor, err = repo.Query("user_id", userID).Page(context.Background(), 4, cursor, &second)
+		require.NoError(t, err)
+		assert.Len(t, second, 2)
+		assert.Empty(t, cursor)
+	})
+
+	t.Run("Rejects non-key conditions in Where", func(t *testing.T) {
+		var orders []Order
+		err := repo.Query("user_id", userID).Where(db.Contains("order_id", "2024")).All(context.Background(), &orders)
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_Query_Index_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+	err := createUsersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	testUser := generateTestUser("query-index")
+	require.NoError(t, repo.Create(context.Background(), testUser))
+
+	t.Run("Selects the index declared for the attribute", func(t *testing.T) {
+		var users []User
+		// name は予約語だがプレースホルダー経由なので使える
+		err := repo.Query("email", testUser.Email).
+			Filter(db.Equal("name", testUser.Name)).
+			All(context.Background(), &users)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, testUser.ID, users[0].ID)
+	})
+
+	t.Run("FindByParameter on a reserved word", func(t *testing.T) {
+		var users []User
+		err := repo.FindByParameter(context.Background(), "name", testUser.Name, &users)
+		require.NoError(t, err)
+		assert.Len(t, users, 1)
+	})
+
+	t.Run("Attribute without index", func(t *testing.T) {
+		var users []User
+		err := repo.Query("name", testUser.Name).All(context.Background(), &users)
+		assert.Error(t, err)
+	})
+}
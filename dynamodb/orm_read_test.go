@@ -171,3 +171,49 @@ func TestRepository_GetAll_Integration(t *testing.T) {
 		}
 	})
 }
+
+func TestRepository_GetPage_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users", db.WithCursorSecret([]byte("test-secret")))
+
+	t.Run("Walk every page with cursors", func(t *testing.T) {
+		testUsers := []*User{
+			generateTestUser("page1"),
+			generateTestUser("page2"),
+			generateTestUser("page3"),
+		}
+		for _, user := range testUsers {
+			err := repo.Create(context.Background(), user)
+			require.NoError(t, err)
+		}
+
+		foundIDs := make(map[string]bool)
+		cursor := ""
+		for {
+			var users []User
+			next, err := repo.GetPage(context.Background(), 2, cursor, &users)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(users), 2)
+			for _, user := range users {
+				foundIDs[user.ID] = true
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		for _, testUser := range testUsers {
+			assert.True(t, foundIDs[testUser.ID])
+		}
+	})
+
+	t.Run("Reject tampered cursor", func(t *testing.T) {
+		var users []User
+		_, err := repo.GetPage(context.Background(), 2, "dGFtcGVyZWQ.c2lnbmF0dXJl", &users)
+		assert.ErrorIs(t, err, db.ErrInvalidCursor)
+	})
+}
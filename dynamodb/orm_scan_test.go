@@ -0,0 +1,83 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+func TestRepository_ParallelScan_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client, repo := setupFakeRepository(t)
+	client.PageSize = 3
+
+	users := make([]User, 50)
+	for i := range users {
+		users[i] = User{ID: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user-%02d@example.com", i), Name: "User"}
+	}
+	require.NoError(t, repo.BatchCreate(ctx, users))
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	err := repo.ParallelScan(ctx, &User{}, func(item interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[item.(*User).ID]++
+		return nil
+	}, db.WithSegments(5), db.WithReadCapacityLimit(1000))
+	require.NoError(t, err)
+	require.Len(t, seen, 50)
+	for id, n := range seen {
+		assert.Equal(t, 1, n, "user %s was read more than once", id)
+	}
+
+	typed, err := db.NewTypedRepository[User](repo)
+	require.NoError(t, err)
+	count := 0
+	err = typed.ParallelScan(ctx, func(user User) error {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		return nil
+	}, db.WithSegments(1), db.WithScanPageSize(7))
+	require.NoError(t, err)
+	assert.Equal(t, 50, count)
+}
+
+func TestRepository_ParallelScan_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+	err := createUsersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	testUser := generateTestUser("scan")
+	require.NoError(t, repo.Create(context.Background(), testUser))
+	defer repo.Delete(context.Background(), testUser.ID)
+
+	// 並列スキャンで作成したユーザーが1回だけ見つかること
+	var mu sync.Mutex
+	found := 0
+	err = repo.ParallelScan(context.Background(), &User{}, func(item interface{}) error {
+		if item.(*User).ID == testUser.ID {
+			mu.Lock()
+			found++
+			mu.Unlock()
+		}
+		return nil
+	}, db.WithSegments(4), db.WithReadCapacityLimit(50))
+	require.NoError(t, err)
+	assert.Equal(t, 1, found)
+}
@@ -0,0 +1,162 @@
+package dynamodb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+	"github.com/yuki5155/go-aws/dynamodb/dynamodbtest"
+)
+
+type AppUser struct {
+	_     struct{} `dynamo:",entity=User,pk=USER#{ID},sk=PROFILE"`
+	ID    string   `json:"id" dynamodbav:"id" dynamo:"id"`
+	Name  string   `json:"name" dynamodbav:"name" dynamo:"name,required"`
+	Email string   `json:"email" dynamodbav:"email" dynamo:"email"`
+}
+
+type AppOrder struct {
+	_       struct{} `dynamo:",entity=Order,pk=USER#{UserID},sk=ORDER#{OrderID}"`
+	UserID  string   `json:"user_id" dynamodbav:"user_id" dynamo:"user_id"`
+	OrderID string   `json:"order_id" dynamodbav:"order_id" dynamo:"order_id"`
+	Total   int64    `json:"total" dynamodbav:"total" dynamo:"total"`
+}
+
+func createAppTable(client interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+}) error {
+	_, err := client.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName: aws.String("App"),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("SK"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("SK"), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	return err
+}
+
+func newAppTable(t *testing.T, repo *db.Repository) *db.SingleTable {
+	table := db.NewSingleTable(repo, "App")
+	require.NoError(t, table.Register(&AppUser{}, &AppOrder{}))
+	return table
+}
+
+func TestSingleTable_Fake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := dynamodbtest.NewClient()
+	require.NoError(t, createAppTable(client))
+	table := newAppTable(t, db.NewRepository(client, "App"))
+
+	user := &AppUser{ID: "u1", Name: "Alice"}
+	require.NoError(t, table.Create(ctx, user))
+	assert.ErrorIs(t, table.Create(ctx, user), db.ErrDuplicateKey)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, table.Create(ctx, &AppOrder{UserID: "u1", OrderID: fmt.Sprintf("o%d", i), Total: int64(i * 100)}))
+	}
+	require.NoError(t, table.Create(ctx, &AppOrder{UserID: "u2", OrderID: "o1"}))
+
+	stored := client.Items("App")
+	require.Len(t, stored, 5)
+
+	t.Run("Get", func(t *testing.T) {
+		found := &AppOrder{UserID: "u1", OrderID: "o2"}
+		require.NoError(t, table.Get(ctx, found))
+		assert.Equal(t, int64(200), found.Total)
+		assert.ErrorIs(t, table.Get(ctx, &AppOrder{UserID: "u1", OrderID: "missing"}), db.ErrNotFound)
+	})
+
+	t.Run("Mixed query", func(t *testing.T) {
+		items, err := table.Query(ctx, "USER#u1", "")
+		require.NoError(t, err)
+		require.Len(t, items, 4)
+		var orders int
+		for _, item := range items {
+			switch v := item.(type) {
+			case *AppUser:
+				assert.Equal(t, "Alice", v.Name)
+			case *AppOrder:
+				orders++
+			default:
+				t.Fatalf("unexpected item %T", item)
+			}
+		}
+		assert.Equal(t, 3, orders)
+
+		items, err = table.Query(ctx, "USER#u1", "ORDER#")
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+	})
+
+	t.Run("Collection", func(t *testing.T) {
+		var found AppUser
+		var orders []AppOrder
+		require.NoError(t, table.Collection(ctx, &AppUser{ID: "u1"}, &found, &orders))
+		assert.Equal(t, "Alice", found.Name)
+		require.Len(t, orders, 3)
+		assert.Equal(t, "o1", orders[0].OrderID)
+
+		var orderPtrs []*AppOrder
+		require.NoError(t, table.Collection(ctx, &AppOrder{UserID: "u2"}, &orderPtrs))
+		assert.Len(t, orderPtrs, 1)
+
+		assert.ErrorIs(t, table.Collection(ctx, &AppUser{ID: "nobody"}, &found), db.ErrNotFound)
+		assert.Error(t, table.Collection(ctx, &AppUser{ID: "u1"}, found))
+	})
+
+	t.Run("Put and Delete", func(t *testing.T) {
+		require.NoError(t, table.Put(ctx, &AppUser{ID: "u1", Name: "Alice Smith"}))
+		found := &AppUser{ID: "u1"}
+		require.NoError(t, table.Get(ctx, found))
+		assert.Equal(t, "Alice Smith", found.Name)
+
+		require.NoError(t, table.Delete(ctx, &AppOrder{UserID: "u2", OrderID: "o1"}))
+		assert.ErrorIs(t, table.Delete(ctx, &AppOrder{UserID: "u2", OrderID: "o1"}), db.ErrNotFound)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		assert.ErrorContains(t, table.Create(ctx, &AppUser{ID: "u3"}), "field Name is required")
+		assert.ErrorContains(t, table.Create(ctx, &User{ID: "u3"}), "not registered")
+	})
+}
+
+func TestSingleTable_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	err := createAppTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+	table := newAppTable(t, db.NewRepository(client, "App"))
+
+	base := generateTestUser("single")
+	user := &AppUser{ID: base.ID, Name: base.Name, Email: base.Email}
+	require.NoError(t, table.Put(context.Background(), user))
+	order := &AppOrder{UserID: user.ID, OrderID: "o1", Total: 1200}
+	require.NoError(t, table.Put(context.Background(), order))
+	defer table.Delete(context.Background(), user)
+	defer table.Delete(context.Background(), order)
+
+	// ユーザーと注文を1回のクエリで取得
+	var found AppUser
+	var orders []AppOrder
+	require.NoError(t, table.Collection(context.Background(), user, &found, &orders))
+	assert.Equal(t, user.Name, found.Name)
+	require.Len(t, orders, 1)
+	assert.Equal(t, int64(1200), orders[0].Total)
+}
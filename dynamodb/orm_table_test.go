@@ -0,0 +1,141 @@
+package dynamodb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type invalidKeyModel struct {
+	ID map[string]string `dynamo:"id,key=hash"`
+}
+
+type duplicateIndexModel struct {
+	ID    string `dynamo:"id,key=hash"`
+	Email string `dynamo:"email,index=lookup-index"`
+	Phone string `dynamo:"phone,index=lookup-index"`
+}
+
+func TestRepository_CreateTableInput(t *testing.T) {
+	repo := db.NewRepository(nil, "Default")
+
+	t.Run("Hash key and global secondary index", func(t *testing.T) {
+		input, err := repo.CreateTableInput(&UserDev{})
+		require.NoError(t, err)
+		assert.Equal(t, "Users-dev", aws.ToString(input.TableName))
+		assert.Equal(t, types.BillingModePayPerRequest, input.BillingMode)
+		assert.Equal(t, []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("email"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("name"), AttributeType: types.ScalarAttributeTypeS},
+		}, input.AttributeDefinitions)
+		assert.Equal(t, []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		}, input.KeySchema)
+		require.Len(t, input.GlobalSecondaryIndexes, 2)
+		assert.Equal(t, "email-index", aws.ToString(input.GlobalSecondaryIndexes[0].IndexName))
+		assert.Equal(t, "name-index", aws.ToString(input.GlobalSecondaryIndexes[1].IndexName))
+		assert.Equal(t, types.ProjectionTypeAll, input.GlobalSecondaryIndexes[0].Projection.ProjectionType)
+	})
+
+	t.Run("Range key and provisioned throughput", func(t *testing.T) {
+		input, err := repo.CreateTableInput(Order{}, db.WithProvisionedThroughput(5, 5))
+		require.NoError(t, err)
+		assert.Equal(t, "Default", aws.ToString(input.TableName), "value receivers do not implement TableNamer")
+		assert.Equal(t, types.BillingModeProvisioned, input.BillingMode)
+		assert.Equal(t, int64(5), aws.ToInt64(input.ProvisionedThroughput.ReadCapacityUnits))
+		assert.Equal(t, []types.KeySchemaElement{
+			{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("order_id"), KeyType: types.KeyTypeRange},
+		}, input.KeySchema)
+		assert.Len(t, input.AttributeDefinitions, 2)
+	})
+
+	t.Run("Invalid models", func(t *testing.T) {
+		_, err := repo.CreateTableInput(&noKeyModel{})
+		assert.ErrorContains(t, err, "hash key")
+
+		_, err = repo.CreateTableInput(&invalidKeyModel{})
+		assert.ErrorContains(t, err, "cannot be used as a key")
+
+		_, err = repo.CreateTableInput(&duplicateIndexModel{})
+		assert.ErrorContains(t, err, "index lookup-index is declared on both email and phone")
+
+		_, err = repo.CreateTableInput("Users")
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_EnsureTable_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+
+	model := &ProvisionedModel{}
+	_, _ = client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(model.TableName())})
+
+	require.NoError(t, repo.EnsureTable(context.Background(), model))
+	// 2回目は既存のテーブルをそのまま使う
+	require.NoError(t, repo.EnsureTable(context.Background(), model))
+	assert.Error(t, repo.CreateTable(context.Background(), model))
+
+	out, err := client.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String(model.TableName())})
+	require.NoError(t, err)
+	assert.Equal(t, types.TableStatusActive, out.Table.TableStatus)
+	require.Len(t, out.Table.GlobalSecondaryIndexes, 1)
+	assert.Equal(t, "status-index", aws.ToString(out.Table.GlobalSecondaryIndexes[0].IndexName))
+
+	require.NoError(t, repo.Create(context.Background(), &ProvisionedModel{TenantID: "t1", CreatedAt: 1, Status: "new"}))
+}
+
+type ProvisionedModel struct {
+	TenantID  string `dynamodbav:"tenant_id" dynamo:"tenant_id,key=hash"`
+	CreatedAt int64  `dynamodbav:"created_at" dynamo:"created_at,key=range"`
+	Status    string `dynamodbav:"status" dynamo:"status,index=status-index"`
+}
+
+func (m *ProvisionedModel) TableName() string {
+	return "ProvisionedModels"
+}
+
+func TestRepository_VerifySchema_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+
+	model := &ProvisionedModel{}
+	require.NoError(t, repo.EnsureTable(context.Background(), model))
+	assert.NoError(t, repo.VerifySchema(context.Background(), model))
+
+	// 同じテーブルにインデックスを追加したモデル
+	err := repo.VerifySchema(context.Background(), &ProvisionedModelV2{})
+	require.ErrorIs(t, err, db.ErrSchemaDrift)
+	var drift *db.SchemaDriftError
+	require.ErrorAs(t, err, &drift)
+	require.Len(t, drift.Differences, 1)
+	assert.Equal(t, db.DriftMissingIndex, drift.Differences[0].Kind)
+	assert.Equal(t, "region-index", drift.Differences[0].Name)
+}
+
+type ProvisionedModelV2 struct {
+	TenantID  string `dynamodbav:"tenant_id" dynamo:"tenant_id,key=hash"`
+	CreatedAt int64  `dynamodbav:"created_at" dynamo:"created_at,key=range"`
+	Status    string `dynamodbav:"status" dynamo:"status,index=status-index"`
+	Region    string `dynamodbav:"region" dynamo:"region,index=region-index"`
+}
+
+func (m *ProvisionedModelV2) TableName() string {
+	return "ProvisionedModels"
+}
@@ -49,7 +49,7 @@ type User struct {
 	ID        string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
 	Email     string `json:"email" dynamodbav:"email" dynamo:"email,required,index=email-index"`
 	Name      string `json:"name" dynamodbav:"name" dynamo:"name,required"`
-	CreatedAt int64  `json:"created_at" dynamodbav:"created_at" dynamo:"created_at"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
 }
 
 func (u *User) TableName() string {
@@ -0,0 +1,52 @@
+package dynamodb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type TimestampedOrder struct {
+	UserID    string    `json:"user_id" dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID   string    `json:"order_id" dynamodbav:"order_id" dynamo:"order_id,key=range"`
+	Total     int64     `json:"total" dynamodbav:"total" dynamo:"total"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	UpdatedAt int64     `json:"updated_at" dynamodbav:"updated_at" dynamo:"updated_at,autoUpdateTime=milli"`
+}
+
+func (o *TimestampedOrder) TableName() string {
+	return "Orders"
+}
+
+func TestRepository_Timestamps_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := db.NewRepository(client, "Orders", db.WithClock(func() time.Time { return now }))
+	err := createOrdersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	order := &TimestampedOrder{UserID: generateTestUser("timestamps").ID, OrderID: "order-1", Total: 100}
+	require.NoError(t, repo.Create(context.Background(), order))
+	assert.Equal(t, now, order.CreatedAt)
+	assert.Equal(t, now.UnixMilli(), order.UpdatedAt)
+
+	now = now.Add(time.Hour)
+	update := &TimestampedOrder{UserID: order.UserID, OrderID: order.OrderID, Total: 200}
+	require.NoError(t, repo.Update(context.Background(), update))
+
+	var found TimestampedOrder
+	require.NoError(t, repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found))
+	assert.True(t, found.CreatedAt.Equal(order.CreatedAt), "Update must not touch the creation time")
+	assert.Equal(t, now.UnixMilli(), found.UpdatedAt)
+}
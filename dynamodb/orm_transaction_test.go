@@ -0,0 +1,73 @@
+package dynamodb_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+func TestRepository_Transaction_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Users")
+	for _, create := range []func() error{
+		func() error { return createUsersTable(client) },
+		func() error { return createOrdersTable(client) },
+	} {
+		if err := create(); err != nil && !strings.Contains(err.Error(), "Table already exists") {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Commits writes across tables", func(t *testing.T) {
+		user := generateTestUser("tx")
+		order := generateTestOrder(user.ID)
+
+		err := repo.Transaction().Create(user).Create(order).Commit(context.Background())
+		require.NoError(t, err)
+
+		var foundUser User
+		assert.NoError(t, repo.FindByID(context.Background(), user.ID, &foundUser))
+		var foundOrder Order
+		assert.NoError(t, repo.FindByKey(context.Background(), order.UserID, order.OrderID, &foundOrder))
+	})
+
+	t.Run("Cancels everything when one operation fails", func(t *testing.T) {
+		existing := generateTestUser("tx-existing")
+		require.NoError(t, repo.Create(context.Background(), existing))
+		order := generateTestOrder(existing.ID)
+
+		err := repo.Transaction().Create(order).Create(existing).Commit(context.Background())
+		assert.ErrorIs(t, err, db.ErrDuplicateKey)
+
+		var txErr *db.TransactionError
+		require.True(t, errors.As(err, &txErr))
+		require.Len(t, txErr.Failures, 1)
+		assert.Equal(t, 1, txErr.Failures[0].Index)
+		assert.Equal(t, "Users", txErr.Failures[0].TableName)
+
+		// 注文も作成されていないこと
+		var found Order
+		err = repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+
+	t.Run("Condition check", func(t *testing.T) {
+		user := generateTestUser("tx-check")
+		require.NoError(t, repo.Create(context.Background(), user))
+
+		err := repo.Transaction().
+			ConditionCheck(user, db.Equal("name", "someone else")).
+			Create(generateTestOrder(user.ID)).
+			Commit(context.Background())
+		assert.ErrorIs(t, err, db.ErrConditionFailed)
+	})
+}
@@ -0,0 +1,69 @@
+package dynamodb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type VersionedOrder struct {
+	UserID  string `json:"user_id" dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID string `json:"order_id" dynamodbav:"order_id" dynamo:"order_id,key=range"`
+	Total   int64  `json:"total" dynamodbav:"total" dynamo:"total"`
+	Version int64  `json:"version" dynamodbav:"version" dynamo:"version,version"`
+}
+
+func (o *VersionedOrder) TableName() string {
+	return "Orders"
+}
+
+func TestRepository_Version_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	repo := db.NewRepository(client, "Orders")
+	err := createOrdersTable(client)
+	if err != nil && !strings.Contains(err.Error(), "Table already exists") {
+		t.Fatal(err)
+	}
+
+	t.Run("Create initializes and Update increments the version", func(t *testing.T) {
+		order := &VersionedOrder{UserID: generateTestUser("version").ID, OrderID: "order-1", Total: 100}
+		require.NoError(t, repo.Create(context.Background(), order))
+		assert.Equal(t, int64(1), order.Version)
+
+		order.Total = 200
+		require.NoError(t, repo.Update(context.Background(), order))
+		assert.Equal(t, int64(2), order.Version)
+
+		var found VersionedOrder
+		require.NoError(t, repo.FindByKey(context.Background(), order.UserID, order.OrderID, &found))
+		assert.Equal(t, int64(2), found.Version)
+		assert.Equal(t, int64(200), found.Total)
+	})
+
+	t.Run("Stale update returns ErrVersionConflict", func(t *testing.T) {
+		order := &VersionedOrder{UserID: generateTestUser("version-conflict").ID, OrderID: "order-1", Total: 100}
+		require.NoError(t, repo.Create(context.Background(), order))
+
+		// 2つの Lambda が同じバージョンを読み込んだ状況
+		first, second := *order, *order
+		first.Total = 200
+		require.NoError(t, repo.Update(context.Background(), &first))
+
+		second.Total = 300
+		err := repo.Update(context.Background(), &second)
+		assert.ErrorIs(t, err, db.ErrVersionConflict)
+		assert.Equal(t, int64(1), second.Version)
+
+		missing := &VersionedOrder{UserID: order.UserID, OrderID: "non-existing-order", Version: 1}
+		err = repo.Update(context.Background(), missing)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+}
@@ -0,0 +1,345 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// UpdateBuilder describes a partial update applied by Repository.Patch.
+// Attributes are referred to by their `dynamo` tag names, for example:
+//
+//	update := db.NewUpdate().
+//		Set("name", "Alice").
+//		Add("login_count", 1).
+//		Append("tags", []string{"admin"}).
+//		Remove("nickname").
+//		Returning(types.ReturnValueAllNew)
+type UpdateBuilder struct {
+	sets         []updateAction
+	adds         []updateAction
+	removes      []string
+	conditions   []Condition
+	returnValues types.ReturnValue
+	err          error
+}
+
+// Kinds of updateAction.
+const (
+	actionSet = iota
+	actionSetIfNotExists
+	actionAppend
+	actionAdd
+)
+
+// updateAction is a single SET or ADD on an attribute.
+type updateAction struct {
+	kind      int
+	attribute string
+	value     interface{}
+}
+
+// build renders the action using placeholders from b.
+func (a updateAction) build(b *expressionBuilder) (string, error) {
+	name := b.name(a.attribute)
+	value, err := b.value(a.value)
+	if err != nil {
+		return "", err
+	}
+	switch a.kind {
+	case actionSetIfNotExists:
+		return fmt.Sprintf("%s = if_not_exists(%s, %s)", name, name, value), nil
+	case actionAppend:
+		emptyList := b.attributeValue(&types.AttributeValueMemberL{Value: []types.AttributeValue{}})
+		return fmt.Sprintf("%s = list_append(if_not_exists(%s, %s), %s)", name, name, emptyList, value), nil
+	case actionAdd:
+		return fmt.Sprintf("%s %s", name, value), nil
+	}
+	return fmt.Sprintf("%s = %s", name, value), nil
+}
+
+// NewUpdate returns an empty UpdateBuilder.
+func NewUpdate() *UpdateBuilder {
+	return &UpdateBuilder{}
+}
+
+// Set sets attribute to value.
+func (u *UpdateBuilder) Set(attribute string, value interface{}) *UpdateBuilder {
+	u.sets = append(u.sets, updateAction{kind: actionSet, attribute: attribute, value: value})
+	return u
+}
+
+// SetIfNotExists sets attribute to value only if the item does not have the
+// attribute yet.
+func (u *UpdateBuilder) SetIfNotExists(attribute string, value interface{}) *UpdateBuilder {
+	u.sets = append(u.sets, updateAction{kind: actionSetIfNotExists, attribute: attribute, value: value})
+	return u
+}
+
+// Append appends the elements of values, which must be a slice, to the list
+// attribute. A missing attribute is treated as an empty list.
+func (u *UpdateBuilder) Append(attribute string, values interface{}) *UpdateBuilder {
+	if reflect.ValueOf(values).Kind() != reflect.Slice {
+		u.err = fmt.Errorf("append to %s: values must be a slice", attribute)
+		return u
+	}
+	u.sets = append(u.sets, updateAction{kind: actionAppend, attribute: attribute, value: values})
+	return u
+}
+
+// Add atomically adds a number to a numeric attribute, or the elements of a
+// set to a set attribute. A missing attribute starts at zero or the empty set.
+func (u *UpdateBuilder) Add(attribute string, value interface{}) *UpdateBuilder {
+	u.adds = append(u.adds, updateAction{kind: actionAdd, attribute: attribute, value: value})
+	return u
+}
+
+// Remove removes attribute from the item.
+func (u *UpdateBuilder) Remove(attribute string) *UpdateBuilder {
+	u.removes = append(u.removes, attribute)
+	return u
+}
+
+// If adds conditions that must hold for the update to be applied. When they
+// do not, Patch returns ErrConditionFailed.
+func (u *UpdateBuilder) If(conditions ...Condition) *UpdateBuilder {
+	u.conditions = append(u.conditions, conditions...)
+	return u
+}
+
+// Returning asks DynamoDB to return item attributes, which Patch unmarshals
+// into its item argument. Use types.ReturnValueAllNew for the updated item.
+func (u *UpdateBuilder) Returning(returnValues types.ReturnValue) *UpdateBuilder {
+	u.returnValues = returnValues
+	return u
+}
+
+// returnsValues reports whether the update requests return values.
+func (u *UpdateBuilder) returnsValues() bool {
+	return u.returnValues != "" && u.returnValues != types.ReturnValueNone
+}
+
+// Patch applies a partial update to the item with the key of item. Unlike
+// Update, only the attributes named in update are written, so zero values in
+// item never overwrite stored data.
+//
+// The BeforeUpdate hook of item is called first; fields it changes are only
+// written if update names them. Values set with Set and SetIfNotExists are
+// checked against the validation rules of their fields, and required
+// attributes cannot be removed.
+//
+// `autoUpdateTime` fields are set to the current time and a `version` field
+// is incremented. Patch returns ErrNotFound if the item does not exist or is
+// soft deleted, unless update writes the `softDelete` attribute, and
+// ErrConditionFailed if a condition added with If does not hold. When update
+// requests return values, they are unmarshaled into item, which must then be
+// a pointer.
+func (r *Repository) Patch(ctx context.Context, item interface{}, update *UpdateBuilder) error {
+	// The hook runs on a copy of a struct passed by value, which cannot
+	// receive the return values.
+	if update != nil && update.returnsValues() && reflect.ValueOf(item).Kind() != reflect.Ptr {
+		return fmt.Errorf("item must be a pointer to receive return values")
+	}
+	item, err := beforeUpdate(ctx, item)
+	if err != nil {
+		return err
+	}
+	input, err := r.patchInput(item, update)
+	if err != nil {
+		return err
+	}
+	result, err := r.client.UpdateItem(ctx, input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if err := r.tenantError(item, ccf.Item); err != nil {
+				return err
+			}
+			if ccf.Item != nil && !deletedItem(item, ccf.Item) {
+				return ErrConditionFailed
+			}
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+	if len(result.Attributes) > 0 {
+		if err := r.decryptItem(ctx, item, aws.ToString(input.TableName), result.Attributes); err != nil {
+			return err
+		}
+		if err := attributevalue.UnmarshalMap(result.Attributes, item); err != nil {
+			return fmt.Errorf("failed to unmarshal item: %w", err)
+		}
+	}
+	return nil
+}
+
+// patchInput builds the UpdateItem request used by Patch.
+func (r *Repository) patchInput(item interface{}, update *UpdateBuilder) (*dynamodb.UpdateItemInput, error) {
+	if update == nil {
+		return nil, fmt.Errorf("update must not be nil")
+	}
+	if update.err != nil {
+		return nil, update.err
+	}
+	if len(update.sets)+len(update.adds)+len(update.removes) == 0 {
+		return nil, fmt.Errorf("update has no actions")
+	}
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("item must be a struct")
+	}
+	if update.returnsValues() && reflect.ValueOf(item).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("item must be a pointer to receive return values")
+	}
+	scoped, err := r.scopeItem(item)
+	if err != nil {
+		return nil, err
+	}
+	val = reflect.Indirect(reflect.ValueOf(scoped))
+	keyMap, keyAttr, err := primaryKey(val)
+	if err != nil {
+		return nil, err
+	}
+
+	// Timestamps and the version are maintained unless the caller updates them explicitly.
+	explicit := map[string]bool{}
+	for _, action := range append(append([]updateAction(nil), update.sets...), update.adds...) {
+		explicit[action.attribute] = true
+	}
+	for _, attribute := range update.removes {
+		explicit[attribute] = true
+	}
+	sets := append([]updateAction(nil), update.sets...)
+	adds := append([]updateAction(nil), update.adds...)
+	typ := val.Type()
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUpdate(meta, update); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	now := r.now()
+	for _, field := range meta.updateFields {
+		parser := field.tag
+		if explicit[parser.AttributeName] {
+			continue
+		}
+		stamp := reflect.New(field.typ).Elem()
+		setTimestamp(stamp, parser.TimeUnit, now)
+		sets = append(sets, updateAction{kind: actionSet, attribute: parser.AttributeName, value: stamp.Interface()})
+	}
+	if meta.version != nil && !explicit[meta.version.tag.AttributeName] {
+		adds = append(adds, updateAction{kind: actionAdd, attribute: meta.version.tag.AttributeName, value: 1})
+	}
+
+	// Reject updates of key attributes and overlapping paths up front with a
+	// clearer message than DynamoDB's validation error.
+	seen := map[string]bool{}
+	check := func(attribute string) error {
+		if attribute == "" {
+			return fmt.Errorf("attribute name must not be empty")
+		}
+		if _, isKey := keyMap[attribute]; isKey {
+			return fmt.Errorf("key attribute %s cannot be updated", attribute)
+		}
+		if r.tenant != "" && meta.tenant != nil && attribute == meta.tenant.tag.AttributeName {
+			return fmt.Errorf("tenant attribute %s cannot be updated", attribute)
+		}
+		if seen[attribute] {
+			return fmt.Errorf("attribute %s is updated more than once", attribute)
+		}
+		seen[attribute] = true
+		return nil
+	}
+
+	b := newExpressionBuilder()
+	var clauses []string
+	var setParts, addParts, removeParts []string
+	for _, action := range append(sets, adds...) {
+		if err := check(action.attribute); err != nil {
+			return nil, err
+		}
+		if encryptedAttribute(meta, action.attribute) {
+			return nil, fmt.Errorf("encrypted attribute %s cannot be patched: use Update", action.attribute)
+		}
+		part, err := action.build(b)
+		if err != nil {
+			return nil, err
+		}
+		if action.kind == actionAdd {
+			addParts = append(addParts, part)
+		} else {
+			setParts = append(setParts, part)
+		}
+	}
+	for _, attribute := range update.removes {
+		if err := check(attribute); err != nil {
+			return nil, err
+		}
+		removeParts = append(removeParts, b.name(attribute))
+	}
+	if len(setParts) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(setParts, ", "))
+	}
+	if len(addParts) > 0 {
+		clauses = append(clauses, "ADD "+strings.Join(addParts, ", "))
+	}
+	if len(removeParts) > 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(removeParts, ", "))
+	}
+
+	// Ensure that the item exists, then apply the caller's conditions.
+	conditionExpr := fmt.Sprintf("attribute_exists(%s)", b.name(keyAttr))
+	// Soft-deleted items are left alone, unless the update restores them by
+	// writing the `softDelete` attribute itself.
+	guardDeleted := meta.softDelete != nil && !explicit[meta.softDelete.tag.AttributeName]
+	if guardDeleted {
+		conditionExpr += fmt.Sprintf(" AND attribute_not_exists(%s)", b.name(meta.softDelete.tag.AttributeName))
+	}
+	tenantCondition, err := r.tenantCondition(b, meta)
+	if err != nil {
+		return nil, err
+	}
+	if tenantCondition != "" {
+		conditionExpr += " AND " + tenantCondition
+	}
+	if len(update.conditions) > 0 {
+		if err := checkEncryptedConditions(meta, update.conditions); err != nil {
+			return nil, err
+		}
+		conditions, err := buildConditions(b, update.conditions)
+		if err != nil {
+			return nil, err
+		}
+		conditionExpr += " AND (" + conditions + ")"
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.getTableName(item)),
+		Key:                       keyMap,
+		UpdateExpression:          aws.String(strings.Join(clauses, " ")),
+		ConditionExpression:       aws.String(conditionExpr),
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+	}
+	if update.returnValues != "" {
+		input.ReturnValues = update.returnValues
+	}
+	if len(update.conditions) > 0 || tenantCondition != "" || guardDeleted {
+		// The stored item tells a missing or soft-deleted item from one
+		// failing the other conditions.
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	return input, nil
+}
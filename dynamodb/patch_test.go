@@ -0,0 +1,80 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type patchItem struct {
+	ID        string   `dynamodbav:"id" dynamo:"id,key=hash"`
+	Name      string   `dynamodbav:"name" dynamo:"name"`
+	Logins    int      `dynamodbav:"logins" dynamo:"logins"`
+	Tags      []string `dynamodbav:"tags" dynamo:"tags"`
+	UpdatedAt int64    `dynamodbav:"updated_at" dynamo:"updated_at,autoUpdateTime"`
+	Version   int      `dynamodbav:"version" dynamo:"version,version"`
+}
+
+func TestPatchInput(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewRepository(nil, "Items", fixedClock(now))
+
+	update := NewUpdate().
+		Set("name", "Alice").
+		SetIfNotExists("nickname", "al").
+		Append("tags", []string{"admin"}).
+		Add("logins", 1).
+		Remove("legacy").
+		If(Equal("name", "Bob")).
+		Returning(types.ReturnValueAllNew)
+	input, err := repo.patchInput(&patchItem{ID: "a"}, update)
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		"SET #n0 = :v0, #n1 = if_not_exists(#n1, :v1), #n2 = list_append(if_not_exists(#n2, :v3), :v2), #n3 = :v4 ADD #n4 :v5, #n5 :v6 REMOVE #n6",
+		aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(#n7) AND (#n0 = :v7)", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, map[string]string{
+		"#n0": "name", "#n1": "nickname", "#n2": "tags", "#n3": "updated_at",
+		"#n4": "logins", "#n5": "version", "#n6": "legacy", "#n7": "id",
+	}, input.ExpressionAttributeNames)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1714564800"}, input.ExpressionAttributeValues[":v4"])
+	assert.Equal(t, types.ReturnValueAllNew, input.ReturnValues)
+	assert.Equal(t, types.ReturnValuesOnConditionCheckFailureAllOld, input.ReturnValuesOnConditionCheckFailure)
+	assert.Equal(t, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "a"}}, input.Key)
+}
+
+func TestPatchInput_ExplicitTimestampAndVersion(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+
+	input, err := repo.patchInput(&patchItem{ID: "a"}, NewUpdate().Set("updated_at", 1).Set("version", 5))
+	require.NoError(t, err)
+	assert.Equal(t, "SET #n0 = :v0, #n1 = :v1", aws.ToString(input.UpdateExpression))
+}
+
+func TestPatchInput_Invalid(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+
+	tests := []struct {
+		name   string
+		item   interface{}
+		update *UpdateBuilder
+		err    string
+	}{
+		{name: "no actions", item: &patchItem{ID: "a"}, update: NewUpdate(), err: "no actions"},
+		{name: "key attribute", item: &patchItem{ID: "a"}, update: NewUpdate().Set("id", "b"), err: "key attribute id"},
+		{name: "same attribute twice", item: &patchItem{ID: "a"}, update: NewUpdate().Set("name", "a").Remove("name"), err: "more than once"},
+		{name: "append non-slice", item: &patchItem{ID: "a"}, update: NewUpdate().Append("tags", "x"), err: "must be a slice"},
+		{name: "return values into value", item: patchItem{ID: "a"}, update: NewUpdate().Set("name", "a").Returning(types.ReturnValueAllNew), err: "must be a pointer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.patchInput(tt.item, tt.update)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
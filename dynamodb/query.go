@@ -0,0 +1,220 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// QueryBuilder builds a DynamoDB Query step by step. Create one with
+// Repository.Query and run it with All or Page, for example:
+//
+//	var orders []Order
+//	err := repo.Query("user_id", userID).
+//		Where(db.BeginsWith("order_id", "2024-")).
+//		Filter(db.GreaterThanOrEqual("total", 1000)).
+//		Descending().
+//		Limit(20).
+//		All(ctx, &orders)
+type QueryBuilder struct {
+	repo           *Repository
+	partitionKey   string
+	partitionValue interface{}
+	indexName      string
+	sortCondition  *Condition
+	filters        []Condition
+	descending     bool
+	limit          int32
+	err            error
+}
+
+// Query starts a query for the items whose partition key attribute equals value.
+// When attribute is not the table's hash key, the index declared for it with
+// `index=` is used unless Index selects one explicitly.
+func (r *Repository) Query(attribute string, value interface{}) *QueryBuilder {
+	return &QueryBuilder{
+		repo:           r,
+		partitionKey:   attribute,
+		partitionValue: value,
+	}
+}
+
+// Index queries the named secondary index instead of the table.
+func (q *QueryBuilder) Index(name string) *QueryBuilder {
+	q.indexName = name
+	return q
+}
+
+// Where adds a sort key condition. Only Equal, LessThan, LessThanOrEqual,
+// GreaterThan, GreaterThanOrEqual, Between and BeginsWith are allowed, and
+// only one sort key condition can be set.
+func (q *QueryBuilder) Where(condition Condition) *QueryBuilder {
+	switch {
+	case q.sortCondition != nil:
+		q.err = fmt.Errorf("only one sort key condition is allowed")
+	case !condition.isKeyCondition():
+		q.err = fmt.Errorf("%s cannot be used as a sort key condition", condition.op)
+	default:
+		q.sortCondition = &condition
+	}
+	return q
+}
+
+// Filter adds a filter applied after items are read. Multiple filters are combined with AND.
+func (q *QueryBuilder) Filter(condition Condition) *QueryBuilder {
+	q.filters = append(q.filters, condition)
+	return q
+}
+
+// Descending returns items in descending sort key order.
+func (q *QueryBuilder) Descending() *QueryBuilder {
+	q.descending = true
+	return q
+}
+
+// Limit caps the number of items All returns.
+func (q *QueryBuilder) Limit(n int32) *QueryBuilder {
+	q.limit = n
+	return q
+}
+
+// request builds the read request for items of the slice type out points to.
+func (q *QueryBuilder) request(out interface{}, options readOptions) (*readRequest, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	elemType, err := sliceElemType(out)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return nil, err
+	}
+	tableName := options.table(q.repo, elemType)
+	indexName := q.indexName
+	if options.indexName != "" {
+		indexName = options.indexName
+	}
+	if indexName == "" {
+		indexName, err = queryIndex(elemType, q.partitionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := newExpressionBuilder()
+	keyConditions := []Condition{Equal(q.partitionKey, q.partitionValue)}
+	if q.sortCondition != nil {
+		keyConditions = append(keyConditions, *q.sortCondition)
+	}
+	keyCondition, err := buildConditions(b, keyConditions)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := options.projectionExpression(b, elemType)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String(keyCondition),
+		ConsistentRead:         options.consistentReadValue(),
+		ProjectionExpression:   projection,
+	}
+	if indexName != "" {
+		input.IndexName = aws.String(indexName)
+	}
+	var filters []string
+	if len(q.filters) > 0 {
+		if err := checkEncryptedConditions(meta, q.filters); err != nil {
+			return nil, err
+		}
+		filter, err := buildConditions(b, q.filters)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	filter, err := options.filter(b, meta, q.partitionKey, q.partitionValue)
+	if err != nil {
+		return nil, err
+	}
+	if filter != "" {
+		if len(filters) > 0 {
+			filters[0] = "(" + filters[0] + ")"
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if q.descending {
+		input.ScanIndexForward = aws.Bool(false)
+	}
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	return &readRequest{tableName: tableName, indexName: indexName, query: input, meta: meta}, nil
+}
+
+// queryIndex returns the index to query for a partition key attribute: none
+// for the table's hash key, otherwise the index declared with `index=`.
+func queryIndex(elemType reflect.Type, attribute string) (string, error) {
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return "", err
+	}
+	if attribute == meta.hashKey {
+		return "", nil
+	}
+	if index, ok := meta.indexes[attribute]; ok {
+		return index, nil
+	}
+	return "", fmt.Errorf("attribute %s is neither the hash key nor declared with index=: select an index with Index", attribute)
+}
+
+// All runs the query and unmarshals every matching item into out, which must
+// be a pointer to a slice. It follows LastEvaluatedKey across pages and stops
+// once Limit items have been collected.
+func (q *QueryBuilder) All(ctx context.Context, out interface{}, opts ...ReadOption) error {
+	req, err := q.request(out, q.repo.readOptions(opts))
+	if err != nil {
+		return err
+	}
+	if q.limit <= 0 {
+		return q.repo.readAll(ctx, req, out)
+	}
+	var items []map[string]types.AttributeValue
+	var startKey map[string]types.AttributeValue
+	for int32(len(items)) < q.limit {
+		page, lastKey, err := q.repo.fetchPage(ctx, req, startKey, q.limit-int32(len(items)))
+		if err != nil {
+			return err
+		}
+		items = append(items, page...)
+		if len(lastKey) == 0 {
+			break
+		}
+		startKey = lastKey
+	}
+	if err := attributevalue.UnmarshalListOfMaps(items, out); err != nil {
+		return fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	return afterFind(ctx, out)
+}
+
+// Page runs the query for a single page of at most pageSize items starting
+// at cursor and returns the cursor of the next page. See Repository.GetPage.
+func (q *QueryBuilder) Page(ctx context.Context, pageSize int32, cursor string, out interface{}, opts ...ReadOption) (string, error) {
+	req, err := q.request(out, q.repo.readOptions(opts))
+	if err != nil {
+		return "", err
+	}
+	return q.repo.readPage(ctx, req, pageSize, cursor, out)
+}
@@ -0,0 +1,213 @@
+package dynamodb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// readOptions holds the per-call settings of a read.
+type readOptions struct {
+	consistentRead bool
+	projection     []string
+	indexName      string
+	tableName      string
+	withDeleted    bool
+	// tenant is the tenant of a TenantRepository, which every read is
+	// restricted to.
+	tenant string
+}
+
+// ReadOption configures a single read: FindByID, FindByKey, FindByParameter,
+// FindByParameterPage, GetAll, GetPage, BatchFindByIDs and the QueryBuilder.
+type ReadOption func(*readOptions)
+
+// WithConsistentRead requests a strongly consistent read, for example right
+// after a write. Global secondary indexes do not support consistent reads.
+func WithConsistentRead() ReadOption {
+	return func(o *readOptions) {
+		o.consistentRead = true
+	}
+}
+
+// WithProjection reads only the given fields. Each field is a Go field name,
+// resolved to its attribute name through the `dynamo` tag, or an attribute
+// name. Fields that are not read keep their zero value.
+func WithProjection(fields ...string) ReadOption {
+	return func(o *readOptions) {
+		o.projection = append(o.projection, fields...)
+	}
+}
+
+// WithIndex reads from the named secondary index instead of the one chosen
+// automatically. FindByParameter then queries the index with the parameter
+// as its partition key, GetAll and GetPage scan it.
+func WithIndex(name string) ReadOption {
+	return func(o *readOptions) {
+		o.indexName = name
+	}
+}
+
+// WithTable reads from the named table instead of the one resolved from the
+// model, for example a per-environment copy.
+func WithTable(name string) ReadOption {
+	return func(o *readOptions) {
+		o.tableName = name
+	}
+}
+
+// WithDeleted also returns items that were soft deleted through a
+// `softDelete` field. Without it, reads skip them.
+func WithDeleted() ReadOption {
+	return func(o *readOptions) {
+		o.withDeleted = true
+	}
+}
+
+func newReadOptions(opts []ReadOption) readOptions {
+	var options readOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// table returns the table to read for items of type elemType.
+func (o readOptions) table(r *Repository, elemType reflect.Type) string {
+	if o.tableName != "" {
+		return o.tableName
+	}
+	return r.getTableName(reflect.New(elemType).Interface())
+}
+
+// readOptions returns the options of a read by r, restricted to the tenant
+// r is scoped to, if any.
+func (r *Repository) readOptions(opts []ReadOption) readOptions {
+	options := newReadOptions(opts)
+	options.tenant = r.tenant
+	return options
+}
+
+// skipDeleted reports whether soft-deleted items of meta must be skipped.
+func (o readOptions) skipDeleted(meta *modelMeta) bool {
+	return meta.softDelete != nil && !o.withDeleted
+}
+
+// filter returns the filter that skips soft-deleted items of meta and items
+// of other tenants, or an empty string if there is nothing to skip.
+// partition is the partition key attribute of a Query and value its value:
+// DynamoDB does not allow key attributes in a filter, so a query on the
+// tenant attribute is checked against the tenant instead.
+func (o readOptions) filter(b *expressionBuilder, meta *modelMeta, partition string, value interface{}) (string, error) {
+	var filters []string
+	if o.skipDeleted(meta) {
+		filters = append(filters, fmt.Sprintf("attribute_not_exists(%s)", b.name(meta.softDelete.tag.AttributeName)))
+	}
+	if o.tenant != "" {
+		if meta.tenant == nil {
+			return "", noTenantField(meta)
+		}
+		attribute := meta.tenant.tag.AttributeName
+		if attribute == partition {
+			if fmt.Sprint(value) != o.tenant {
+				return "", &CrossTenantError{Tenant: o.tenant}
+			}
+		} else {
+			tenant, err := b.value(o.tenant)
+			if err != nil {
+				return "", err
+			}
+			filters = append(filters, fmt.Sprintf("%s = %s", b.name(attribute), tenant))
+		}
+	}
+	return strings.Join(filters, " AND "), nil
+}
+
+// guardAttributes returns the attributes a projection must include to check
+// items of meta on the client with visible.
+func (o readOptions) guardAttributes(meta *modelMeta) []string {
+	var attributes []string
+	if o.skipDeleted(meta) {
+		attributes = append(attributes, meta.softDelete.tag.AttributeName)
+	}
+	if o.tenant != "" && meta.tenant != nil {
+		attributes = append(attributes, meta.tenant.tag.AttributeName)
+	}
+	return attributes
+}
+
+// visible reports whether the item av of meta read by key must be returned.
+// Soft-deleted items are skipped and items of another tenant are refused
+// with a *CrossTenantError.
+func (o readOptions) visible(meta *modelMeta, av map[string]types.AttributeValue) (bool, error) {
+	if o.tenant != "" {
+		if meta.tenant == nil {
+			return false, noTenantField(meta)
+		}
+		if err := checkTenant(o.tenant, meta, av); err != nil {
+			return false, err
+		}
+	}
+	return !(o.skipDeleted(meta) && isDeleted(meta, av)), nil
+}
+
+// consistentReadValue returns the ConsistentRead parameter, nil unless requested.
+func (o readOptions) consistentReadValue() *bool {
+	if !o.consistentRead {
+		return nil
+	}
+	return aws.Bool(true)
+}
+
+// projectionExpression resolves the projected fields of elemType and returns
+// the ProjectionExpression, or nil when every attribute is read. extra lists
+// attributes that must always be read, such as the keys a caller matches on.
+func (o readOptions) projectionExpression(b *expressionBuilder, elemType reflect.Type, extra ...string) (*string, error) {
+	if len(o.projection) == 0 {
+		return nil, nil
+	}
+	var placeholders []string
+	seen := make(map[string]bool)
+	add := func(attribute string) {
+		if !seen[attribute] {
+			seen[attribute] = true
+			placeholders = append(placeholders, b.name(attribute))
+		}
+	}
+	for _, field := range o.projection {
+		attribute, err := projectedAttribute(elemType, field)
+		if err != nil {
+			return nil, err
+		}
+		add(attribute)
+	}
+	for _, attribute := range extra {
+		add(attribute)
+	}
+	// Encrypted attributes are bound to the primary key of their item.
+	if meta, err := metadataOf(elemType); err == nil && len(meta.encrypted) > 0 {
+		for _, field := range meta.keyFields {
+			add(field.tag.AttributeName)
+		}
+	}
+	return aws.String(strings.Join(placeholders, ", ")), nil
+}
+
+// projectedAttribute resolves a Go field name or an attribute name of
+// elemType to its attribute name.
+func projectedAttribute(elemType reflect.Type, name string) (string, error) {
+	meta, err := metadataOf(elemType)
+	if err != nil {
+		return "", err
+	}
+	if field, ok := meta.byName[name]; ok && field.tag.AttributeName != "" {
+		return field.tag.AttributeName, nil
+	}
+	if _, ok := meta.byAttribute[name]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("cannot project %s: no field of %s with a dynamo tag has this name", name, elemType)
+}
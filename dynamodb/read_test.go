@@ -0,0 +1,131 @@
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingClient records read requests and returns empty results.
+type recordingClient struct {
+	DynamoDBClient
+	get      *dynamodb.GetItemInput
+	query    *dynamodb.QueryInput
+	scan     *dynamodb.ScanInput
+	batchGet *dynamodb.BatchGetItemInput
+}
+
+func (c *recordingClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.get = params
+	return &dynamodb.GetItemOutput{Item: params.Key}, nil
+}
+
+func (c *recordingClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.query = params
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (c *recordingClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.scan = params
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (c *recordingClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	c.batchGet = params
+	return &dynamodb.BatchGetItemOutput{}, nil
+}
+
+type readItem struct {
+	ID     string `dynamodbav:"id" dynamo:"id,key=hash"`
+	Email  string `dynamodbav:"email" dynamo:"email,index=email-index"`
+	Name   string `dynamodbav:"name" dynamo:"name"`
+	Status string `dynamodbav:"status" dynamo:"status"`
+	Note   string `dynamodbav:"note"`
+}
+
+func (r *readItem) TableName() string {
+	return "Items"
+}
+
+func TestReadOptions_FindByID(t *testing.T) {
+	client := &recordingClient{}
+	repo := NewRepository(client, "Default")
+
+	var item readItem
+	require.NoError(t, repo.FindByID(context.Background(), "1", &item))
+	assert.Nil(t, client.get.ConsistentRead)
+	assert.Nil(t, client.get.ProjectionExpression)
+	assert.Nil(t, client.get.ExpressionAttributeNames)
+
+	require.NoError(t, repo.FindByID(context.Background(), "1", &item,
+		WithConsistentRead(), WithProjection("Name", "status"), WithTable("Items-dev")))
+	assert.True(t, aws.ToBool(client.get.ConsistentRead))
+	assert.Equal(t, "Items-dev", aws.ToString(client.get.TableName))
+	assert.Equal(t, "#n0, #n1", aws.ToString(client.get.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#n0": "name", "#n1": "status"}, client.get.ExpressionAttributeNames)
+
+	err := repo.FindByID(context.Background(), "1", &item, WithProjection("Note"))
+	assert.ErrorContains(t, err, "cannot project Note")
+	err = repo.FindByID(context.Background(), "1", &item, WithIndex("email-index"))
+	assert.ErrorContains(t, err, "use Query")
+}
+
+func TestReadOptions_FindByParameter(t *testing.T) {
+	client := &recordingClient{}
+	repo := NewRepository(client, "Default")
+	var items []readItem
+
+	require.NoError(t, repo.FindByParameter(context.Background(), "email", "a@example.com", &items, WithProjection("ID", "Email")))
+	assert.Equal(t, "email-index", aws.ToString(client.query.IndexName))
+	assert.Equal(t, "#n0 = :v0", aws.ToString(client.query.KeyConditionExpression))
+	assert.Equal(t, "#n1, #n0", aws.ToString(client.query.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#n0": "email", "#n1": "id"}, client.query.ExpressionAttributeNames)
+
+	err := repo.FindByParameter(context.Background(), "email", "a@example.com", &items, WithConsistentRead())
+	assert.ErrorContains(t, err, "not supported on global secondary index email-index")
+
+	require.NoError(t, repo.FindByParameter(context.Background(), "status", "active", &items, WithIndex("status-index"), WithConsistentRead()))
+	assert.Equal(t, "status-index", aws.ToString(client.query.IndexName))
+	assert.True(t, aws.ToBool(client.query.ConsistentRead))
+
+	require.NoError(t, repo.FindByParameter(context.Background(), "name", "Alice", &items, WithConsistentRead()))
+	assert.True(t, aws.ToBool(client.scan.ConsistentRead))
+	assert.Equal(t, "#n0 = :v0", aws.ToString(client.scan.FilterExpression))
+}
+
+func TestReadOptions_ScanAndQuery(t *testing.T) {
+	client := &recordingClient{}
+	repo := NewRepository(client, "Default")
+	var items []readItem
+
+	_, err := repo.GetPage(context.Background(), 10, "", &items, WithIndex("email-index"), WithProjection("Email"))
+	require.NoError(t, err)
+	assert.Equal(t, "email-index", aws.ToString(client.scan.IndexName))
+	assert.Equal(t, "#n0", aws.ToString(client.scan.ProjectionExpression))
+
+	err = repo.Query("id", "1").All(context.Background(), &items, WithConsistentRead(), WithTable("Items-dev"))
+	require.NoError(t, err)
+	assert.Equal(t, "Items-dev", aws.ToString(client.query.TableName))
+	assert.True(t, aws.ToBool(client.query.ConsistentRead))
+	assert.Nil(t, client.query.IndexName)
+}
+
+func TestReadOptions_BatchFindByIDs(t *testing.T) {
+	client := &recordingClient{}
+	repo := NewRepository(client, "Default")
+	var items []readItem
+
+	require.NoError(t, repo.BatchFindByIDs(context.Background(), []string{"1", "2"}, &items, WithProjection("Name"), WithConsistentRead()))
+	request := client.batchGet.RequestItems["Items"]
+	assert.Len(t, request.Keys, 2)
+	assert.True(t, aws.ToBool(request.ConsistentRead))
+	// The hash key is added so items can be returned in the order of ids.
+	assert.Equal(t, "#n0, #n1", aws.ToString(request.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#n0": "name", "#n1": "id"}, request.ExpressionAttributeNames)
+	assert.IsType(t, map[string]types.KeysAndAttributes{}, client.batchGet.RequestItems)
+}
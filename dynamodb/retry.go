@@ -0,0 +1,260 @@
+package dynamodb
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// RetryPolicy configures how the repository retries DynamoDB calls that
+// failed with a transient error, such as throttling. Set it with
+// WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts of a call, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. It doubles on every
+	// retry up to MaxDelay, and each wait is drawn at random between zero
+	// and that backoff.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retryable reports whether an error is worth retrying. It defaults to
+	// IsRetryable.
+	Retryable func(err error) bool
+	// Observer, if set, is called after every failed attempt with a
+	// retryable error, including the last one.
+	Observer func(ctx context.Context, event RetryEvent)
+}
+
+// RetryEvent describes a failed attempt of a DynamoDB call.
+type RetryEvent struct {
+	// Operation is the DynamoDB API name, e.g. "PutItem".
+	Operation string
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+	Err     error
+	// Delay is the wait before the next attempt. Retrying is false when the
+	// call gives up: the attempts are exhausted or the context deadline
+	// leaves no time for another one.
+	Delay    time.Duration
+	Retrying bool
+}
+
+// DefaultRetryPolicy returns a policy of 5 attempts with a backoff starting
+// at 50ms and capped at 5 seconds.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// WithRetryPolicy retries the calls the repository makes to DynamoDB
+// according to policy. By default errors are returned as they are, after
+// the retries of the AWS SDK client itself.
+func WithRetryPolicy(policy RetryPolicy) RepositoryOption {
+	return func(r *Repository) {
+		r.retryPolicy = &policy
+	}
+}
+
+// retryableCodes lists the error codes of transient failures.
+var retryableCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+	"InternalServerError":                    true,
+	"TransactionConflictException":           true,
+}
+
+// retryableReasons lists the cancellation reasons of a transaction that
+// may succeed when it is sent again.
+var retryableReasons = map[string]bool{
+	"None":                          true,
+	"ThrottlingError":               true,
+	"ProvisionedThroughputExceeded": true,
+	"TransactionConflict":           true,
+}
+
+// IsRetryable reports whether err is a transient DynamoDB error: throttling,
+// exceeded provisioned throughput, an internal server error or a transaction
+// conflict. Failed condition checks, validation errors and context errors
+// are never retryable, and a cancelled transaction only is when none of its
+// operations failed its condition.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false
+	}
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) {
+		if len(tce.CancellationReasons) == 0 {
+			return false
+		}
+		for _, reason := range tce.CancellationReasons {
+			code := aws.ToString(reason.Code)
+			if code != "" && !retryableReasons[code] {
+				return false
+			}
+		}
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && retryableCodes[apiErr.ErrorCode()]
+}
+
+// retryClient retries the calls of the wrapped client according to a policy.
+type retryClient struct {
+	DynamoDBClient
+	policy RetryPolicy
+}
+
+// newRetryClient wraps client, filling the defaults of policy.
+func newRetryClient(client DynamoDBClient, policy RetryPolicy) *retryClient {
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultRetryPolicy().BaseDelay
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	return &retryClient{DynamoDBClient: client, policy: policy}
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// or the attempts or the time left before the context deadline run out. The
+// last error is returned unchanged.
+func (c *retryClient) do(ctx context.Context, operation string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !c.policy.Retryable(err) {
+			return err
+		}
+		event := RetryEvent{Operation: operation, Attempt: attempt, Err: err}
+		if attempt < c.policy.MaxAttempts {
+			event.Delay = c.backoff(attempt)
+			deadline, ok := ctx.Deadline()
+			event.Retrying = !ok || time.Until(deadline) > event.Delay
+		}
+		if c.policy.Observer != nil {
+			c.policy.Observer(ctx, event)
+		}
+		if !event.Retrying {
+			return err
+		}
+		timer := time.NewTimer(event.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the jittered wait after attempt.
+func (c *retryClient) backoff(attempt int) time.Duration {
+	delay := c.policy.BaseDelay << (attempt - 1)
+	if delay > c.policy.MaxDelay || delay <= 0 {
+		delay = c.policy.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func (c *retryClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.PutItemOutput, err error) {
+	err = c.do(ctx, "PutItem", func() error {
+		out, err = c.DynamoDBClient.PutItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.GetItemOutput, err error) {
+	err = c.do(ctx, "GetItem", func() error {
+		out, err = c.DynamoDBClient.GetItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.QueryOutput, err error) {
+	err = c.do(ctx, "Query", func() error {
+		out, err = c.DynamoDBClient.Query(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.ScanOutput, err error) {
+	err = c.do(ctx, "Scan", func() error {
+		out, err = c.DynamoDBClient.Scan(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.UpdateItemOutput, err error) {
+	err = c.do(ctx, "UpdateItem", func() error {
+		out, err = c.DynamoDBClient.UpdateItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.DeleteItemOutput, err error) {
+	err = c.do(ctx, "DeleteItem", func() error {
+		out, err = c.DynamoDBClient.DeleteItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.BatchGetItemOutput, err error) {
+	err = c.do(ctx, "BatchGetItem", func() error {
+		out, err = c.DynamoDBClient.BatchGetItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.BatchWriteItemOutput, err error) {
+	err = c.do(ctx, "BatchWriteItem", func() error {
+		out, err = c.DynamoDBClient.BatchWriteItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.TransactWriteItemsOutput, err error) {
+	if params.ClientRequestToken == nil {
+		// The SDK generates a token per call; share one across the attempts
+		// so that retrying a transaction that was committed is a no-op.
+		token := make([]byte, 16)
+		if _, err := crand.Read(token); err != nil {
+			return nil, fmt.Errorf("failed to generate client request token: %w", err)
+		}
+		copied := *params
+		copied.ClientRequestToken = aws.String(hex.EncodeToString(token))
+		params = &copied
+	}
+	err = c.do(ctx, "TransactWriteItems", func() error {
+		out, err = c.DynamoDBClient.TransactWriteItems(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
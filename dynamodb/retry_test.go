@@ -0,0 +1,131 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyClient fails every call with err until failures is exhausted.
+type flakyClient struct {
+	DynamoDBClient
+	err      error
+	failures int
+	calls    int
+	tokens   []string
+}
+
+func (c *flakyClient) fail() error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+func (c *flakyClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := c.fail(); err != nil {
+		return nil, err
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *flakyClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.tokens = append(c.tokens, aws.ToString(params.ClientRequestToken))
+	if err := c.fail(); err != nil {
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+var errThrottled = &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException", Message: "slow down"}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{errThrottled, true},
+		{fmt.Errorf("failed to put item: %w", &smithy.GenericAPIError{Code: "ThrottlingException"}), true},
+		{&types.InternalServerError{}, true},
+		{&types.ConditionalCheckFailedException{}, false},
+		{&smithy.GenericAPIError{Code: "ValidationException"}, false},
+		{context.DeadlineExceeded, false},
+		{&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("None")}, {Code: aws.String("TransactionConflict")},
+		}}, true},
+		{&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("ThrottlingError")}, {Code: aws.String("ConditionalCheckFailed")},
+		}}, false},
+	} {
+		assert.Equal(t, tc.retryable, IsRetryable(tc.err), "%v", tc.err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	client := &flakyClient{err: errThrottled, failures: 2}
+	var events []RetryEvent
+	repo := NewRepository(client, "Items", WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Observer: func(ctx context.Context, event RetryEvent) {
+			events = append(events, event)
+		},
+	}))
+
+	require.NoError(t, repo.Create(ctx, &readItem{ID: "a"}))
+	assert.Equal(t, 3, client.calls)
+	require.Len(t, events, 2)
+	assert.Equal(t, "PutItem", events[1].Operation)
+	assert.Equal(t, 2, events[1].Attempt)
+	assert.True(t, events[1].Retrying)
+	assert.LessOrEqual(t, events[1].Delay, 2*time.Millisecond)
+
+	// The attempts run out and the last error is returned.
+	client.calls, client.failures, events = 0, 5, nil
+	err := repo.Create(ctx, &readItem{ID: "a"})
+	assert.ErrorIs(t, err, errThrottled)
+	assert.Equal(t, 3, client.calls)
+	require.Len(t, events, 3)
+	assert.False(t, events[2].Retrying)
+
+	// Failed conditions are never retried.
+	client.calls, client.err, events = 0, &types.ConditionalCheckFailedException{}, nil
+	assert.ErrorIs(t, repo.Create(ctx, &readItem{ID: "a"}), ErrDuplicateKey)
+	assert.Equal(t, 1, client.calls)
+	assert.Empty(t, events)
+}
+
+func TestRetryPolicy_Deadline(t *testing.T) {
+	client := &flakyClient{err: errThrottled, failures: 5}
+	repo := NewRepository(client, "Items", WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Hour,
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Waiting would pass the deadline unless the jitter picks a tiny delay.
+	err := repo.Create(ctx, &readItem{ID: "a"})
+	assert.ErrorIs(t, err, errThrottled)
+	assert.LessOrEqual(t, client.calls, 2)
+}
+
+func TestRetryPolicy_TransactionToken(t *testing.T) {
+	client := &flakyClient{err: &types.TransactionConflictException{}, failures: 2}
+	repo := NewRepository(client, "Items", WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	require.NoError(t, repo.Transaction().Create(&readItem{ID: "a"}).Commit(context.Background()))
+	require.Len(t, client.tokens, 3)
+	assert.NotEmpty(t, client.tokens[0])
+	assert.Equal(t, client.tokens[0], client.tokens[2])
+}
@@ -0,0 +1,251 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const defaultScanSegments = 4
+
+// scanOptions holds the settings of a parallel scan.
+type scanOptions struct {
+	segments     int
+	pageSize     int32
+	readCapacity float64
+	withDeleted  bool
+}
+
+// ScanOption configures ParallelScan.
+type ScanOption func(*scanOptions)
+
+// WithSegments sets the number of segments, and so of concurrent workers,
+// the table is split into. The default is 4.
+func WithSegments(n int) ScanOption {
+	return func(o *scanOptions) {
+		if n > 0 {
+			o.segments = n
+		}
+	}
+}
+
+// WithScanPageSize sets the Limit of every Scan request. By default each
+// request reads up to 1 MB.
+func WithScanPageSize(n int32) ScanOption {
+	return func(o *scanOptions) {
+		o.pageSize = n
+	}
+}
+
+// WithReadCapacityLimit caps the read capacity units the scan consumes per
+// second across all workers. By default the scan is not throttled.
+func WithReadCapacityLimit(unitsPerSecond float64) ScanOption {
+	return func(o *scanOptions) {
+		o.readCapacity = unitsPerSecond
+	}
+}
+
+// ScanWithDeleted also passes items that were soft deleted through a
+// `softDelete` field to the callback. Without it, the scan skips them like
+// GetAll does.
+func ScanWithDeleted() ScanOption {
+	return func(o *scanOptions) {
+		o.withDeleted = true
+	}
+}
+
+// ParallelScan reads every item of the table of model, a struct or a pointer
+// to one, using a parallel Scan split into segments. Each item is decoded into
+// a new pointer of the model's type and passed to fn:
+//
+//	err := repo.ParallelScan(ctx, &User{}, func(item interface{}) error {
+//		user := item.(*User)
+//		...
+//	}, db.WithSegments(8), db.WithReadCapacityLimit(100))
+//
+// Soft-deleted items are skipped unless ScanWithDeleted is passed.
+// fn is called concurrently by the workers and must be safe for concurrent
+// use. The first error returned by fn or DynamoDB, or the cancellation of ctx,
+// stops every worker and is returned.
+func (r *Repository) ParallelScan(ctx context.Context, model interface{}, fn func(item interface{}) error, opts ...ScanOption) error {
+	typ := reflect.TypeOf(model)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a struct")
+	}
+	if fn == nil {
+		return fmt.Errorf("fn must not be nil")
+	}
+	options := scanOptions{segments: defaultScanSegments}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return err
+	}
+	b := newExpressionBuilder()
+	filter, err := readOptions{withDeleted: options.withDeleted, tenant: r.tenant}.filter(b, meta, "", nil)
+	if err != nil {
+		return err
+	}
+	template := &dynamodb.ScanInput{
+		TableName:     aws.String(r.getTableName(reflect.New(typ).Interface())),
+		TotalSegments: aws.Int32(int32(options.segments)),
+	}
+	if filter != "" {
+		template.FilterExpression = aws.String(filter)
+		template.ExpressionAttributeNames = b.attributeNames()
+		template.ExpressionAttributeValues = b.attributeValues()
+	}
+	if options.pageSize > 0 {
+		template.Limit = aws.Int32(options.pageSize)
+	}
+	var limiter *capacityLimiter
+	if options.readCapacity > 0 {
+		limiter = newCapacityLimiter(options.readCapacity)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for segment := 0; segment < options.segments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			err := r.scanSegment(ctx, template, typ, segment, limiter, fn)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(segment)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// scanSegment reads every page of one segment of the scan described by template.
+func (r *Repository) scanSegment(ctx context.Context, template *dynamodb.ScanInput, typ reflect.Type, segment int, limiter *capacityLimiter, fn func(item interface{}) error) error {
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return err
+	}
+	input := *template
+	input.Segment = aws.Int32(int32(segment))
+	if limiter != nil {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+	}
+	for {
+		if limiter != nil {
+			if err := limiter.wait(ctx); err != nil {
+				return err
+			}
+		}
+		result, err := r.client.Scan(ctx, &input)
+		if err != nil {
+			return fmt.Errorf("failed to scan segment %d: %w", segment, err)
+		}
+		if limiter != nil {
+			limiter.consume(consumedUnits(result))
+		}
+		if err := r.openItems(ctx, meta, aws.ToString(input.TableName), result.Items); err != nil {
+			return err
+		}
+		for _, av := range result.Items {
+			item := reflect.New(typ).Interface()
+			if err := attributevalue.UnmarshalMap(av, item); err != nil {
+				return fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			if err := callAfterFind(ctx, item); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// consumedUnits returns the capacity a Scan reported, or an estimate of half
+// a unit per scanned item if it did not report any.
+func consumedUnits(result *dynamodb.ScanOutput) float64 {
+	if result.ConsumedCapacity != nil && result.ConsumedCapacity.CapacityUnits != nil {
+		return *result.ConsumedCapacity.CapacityUnits
+	}
+	return float64(result.ScannedCount) * 0.5
+}
+
+// capacityLimiter is a token bucket holding up to one second of read
+// capacity. Workers wait until the bucket is no longer in debt before each
+// request and pay for the units the response reports afterwards, because the
+// cost of a Scan page is only known once it has been read.
+type capacityLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	available float64
+	last      time.Time
+}
+
+func newCapacityLimiter(rate float64) *capacityLimiter {
+	return &capacityLimiter{rate: rate, available: rate, last: time.Now()}
+}
+
+// refill adds the capacity accrued since the last call. l.mu must be held.
+func (l *capacityLimiter) refill() {
+	now := time.Now()
+	l.available += now.Sub(l.last).Seconds() * l.rate
+	if l.available > l.rate {
+		l.available = l.rate
+	}
+	l.last = now
+}
+
+// wait blocks until capacity is available or ctx is done.
+func (l *capacityLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.available > 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((-l.available/l.rate)*float64(time.Second)) + time.Millisecond
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// consume records units as used.
+func (l *capacityLimiter) consume(units float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.available -= units
+}
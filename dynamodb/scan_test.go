@@ -0,0 +1,120 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// segmentClient returns pages of one item forever and fails segment failing
+// after its first page.
+type segmentClient struct {
+	DynamoDBClient
+	mu       sync.Mutex
+	failing  int32
+	segments map[int32]bool
+	units    float64
+}
+
+func (c *segmentClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	segment := aws.ToInt32(params.Segment)
+	c.mu.Lock()
+	c.segments[segment] = true
+	c.mu.Unlock()
+	if segment == c.failing && params.ExclusiveStartKey != nil {
+		return nil, errors.New("throttled")
+	}
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "x"}}
+	out := &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{item}, LastEvaluatedKey: item}
+	if params.ReturnConsumedCapacity == types.ReturnConsumedCapacityTotal {
+		out.ConsumedCapacity = &types.ConsumedCapacity{CapacityUnits: aws.Float64(c.units)}
+	}
+	return out, nil
+}
+
+func TestParallelScan_FirstErrorStopsWorkers(t *testing.T) {
+	client := &segmentClient{failing: 2, segments: map[int32]bool{}}
+	repo := NewRepository(client, "Items")
+
+	err := repo.ParallelScan(context.Background(), batchItem{}, func(item interface{}) error {
+		assert.IsType(t, &batchItem{}, item)
+		return nil
+	}, WithSegments(3))
+	// The other workers never finish on their own, so returning at all
+	// means they were stopped.
+	assert.ErrorContains(t, err, "failed to scan segment 2: throttled")
+	assert.True(t, client.segments[2])
+}
+
+func TestParallelScan_CallbackError(t *testing.T) {
+	client := &segmentClient{failing: -1, segments: map[int32]bool{}}
+	repo := NewRepository(client, "Items")
+	stop := errors.New("stop")
+
+	var mu sync.Mutex
+	calls := 0
+	err := repo.ParallelScan(context.Background(), &batchItem{}, func(item interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 10 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestParallelScan_Cancellation(t *testing.T) {
+	client := &segmentClient{failing: -1, segments: map[int32]bool{}}
+	repo := NewRepository(client, "Items")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := repo.ParallelScan(ctx, &batchItem{}, func(item interface{}) error {
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParallelScan_ReadCapacityLimit(t *testing.T) {
+	// Every page costs 10 units against a limit of 200 units per second: the
+	// first 20 pages use up the initial second of capacity and every further
+	// page has to wait about 50ms.
+	client := &segmentClient{failing: -1, segments: map[int32]bool{}, units: 10}
+	repo := NewRepository(client, "Items")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var mu sync.Mutex
+	pages := 0
+	err := repo.ParallelScan(ctx, &batchItem{}, func(item interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pages++
+		return nil
+	}, WithSegments(4), WithReadCapacityLimit(200))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// 200 units of burst plus 60 units accrued in 300ms, with one page of
+	// slack per worker.
+	assert.GreaterOrEqual(t, pages, 20)
+	assert.LessOrEqual(t, pages, 30)
+}
+
+func TestParallelScan_InvalidArguments(t *testing.T) {
+	repo := NewRepository(&segmentClient{}, "Items")
+	noop := func(interface{}) error { return nil }
+	require.Error(t, repo.ParallelScan(context.Background(), "Items", noop))
+	require.Error(t, repo.ParallelScan(context.Background(), &batchItem{}, nil))
+}
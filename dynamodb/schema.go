@@ -0,0 +1,160 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrSchemaDrift is matched by a SchemaDriftError.
+var ErrSchemaDrift = errors.New("table schema does not match model")
+
+// Kinds of SchemaDifference.
+const (
+	DriftKeySchema     = "KeySchema"
+	DriftAttributeType = "AttributeType"
+	DriftMissingIndex  = "MissingIndex"
+	DriftIndexKey      = "IndexKeySchema"
+)
+
+// SchemaDifference is a single mismatch between a model and its table.
+type SchemaDifference struct {
+	// Kind is one of the Drift* constants.
+	Kind string
+	// Name is the key attribute, attribute or index the difference is about.
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (d SchemaDifference) String() string {
+	return fmt.Sprintf("%s %s: expected %s, got %s", d.Kind, d.Name, d.Expected, d.Actual)
+}
+
+// SchemaDriftError is returned by VerifySchema when the live table does not
+// match the model.
+type SchemaDriftError struct {
+	TableName   string
+	Differences []SchemaDifference
+}
+
+// Error implements the error interface
+func (e *SchemaDriftError) Error() string {
+	parts := make([]string, len(e.Differences))
+	for i, d := range e.Differences {
+		parts[i] = d.String()
+	}
+	return fmt.Sprintf("schema drift in table %s: %s", e.TableName, strings.Join(parts, "; "))
+}
+
+// Unwrap returns ErrSchemaDrift
+func (e *SchemaDriftError) Unwrap() error {
+	return ErrSchemaDrift
+}
+
+// VerifySchema describes the table of model and compares it with the schema
+// CreateTable would create: the table's key schema, the types of its key
+// attributes and every `index=` declaration. It returns a *SchemaDriftError
+// listing all differences, or nil if there are none. Indexes that exist in the
+// table but not in the model are ignored.
+//
+// A missing index otherwise only shows up at runtime, when FindByParameter
+// queries it and DynamoDB rejects the request.
+func (r *Repository) VerifySchema(ctx context.Context, model interface{}) error {
+	manager, err := r.tableManager()
+	if err != nil {
+		return err
+	}
+	expected, err := r.CreateTableInput(model)
+	if err != nil {
+		return err
+	}
+	result, err := manager.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: expected.TableName})
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", aws.ToString(expected.TableName), err)
+	}
+	differences := compareSchema(expected, result.Table)
+	if len(differences) > 0 {
+		return &SchemaDriftError{TableName: aws.ToString(expected.TableName), Differences: differences}
+	}
+	return nil
+}
+
+// compareSchema lists the differences between the expected table and the
+// description of the live table.
+func compareSchema(expected *dynamodb.CreateTableInput, actual *types.TableDescription) []SchemaDifference {
+	var differences []SchemaDifference
+	differences = append(differences, compareKeySchema(DriftKeySchema, "table", expected.KeySchema, actual.KeySchema)...)
+
+	actualTypes := make(map[string]types.ScalarAttributeType, len(actual.AttributeDefinitions))
+	for _, def := range actual.AttributeDefinitions {
+		actualTypes[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+	for _, def := range expected.AttributeDefinitions {
+		name := aws.ToString(def.AttributeName)
+		// Attributes that are not part of any key in the table are not defined
+		// there; the missing key or index is reported instead.
+		if actualType, ok := actualTypes[name]; ok && actualType != def.AttributeType {
+			differences = append(differences, SchemaDifference{
+				Kind:     DriftAttributeType,
+				Name:     name,
+				Expected: string(def.AttributeType),
+				Actual:   string(actualType),
+			})
+		}
+	}
+
+	actualIndexes := make(map[string][]types.KeySchemaElement, len(actual.GlobalSecondaryIndexes))
+	for _, index := range actual.GlobalSecondaryIndexes {
+		actualIndexes[aws.ToString(index.IndexName)] = index.KeySchema
+	}
+	for _, index := range expected.GlobalSecondaryIndexes {
+		name := aws.ToString(index.IndexName)
+		keySchema, ok := actualIndexes[name]
+		if !ok {
+			differences = append(differences, SchemaDifference{
+				Kind:     DriftMissingIndex,
+				Name:     name,
+				Expected: formatKeySchema(index.KeySchema),
+				Actual:   "no index",
+			})
+			continue
+		}
+		differences = append(differences, compareKeySchema(DriftIndexKey, name, index.KeySchema, keySchema)...)
+	}
+	return differences
+}
+
+// compareKeySchema reports a difference if the key schemas differ.
+func compareKeySchema(kind, name string, expected, actual []types.KeySchemaElement) []SchemaDifference {
+	if formatKeySchema(expected) == formatKeySchema(actual) {
+		return nil
+	}
+	return []SchemaDifference{{
+		Kind:     kind,
+		Name:     name,
+		Expected: formatKeySchema(expected),
+		Actual:   formatKeySchema(actual),
+	}}
+}
+
+// formatKeySchema renders a key schema as "id HASH, created_at RANGE".
+func formatKeySchema(keySchema []types.KeySchemaElement) string {
+	parts := make([]string, 0, len(keySchema))
+	for _, kt := range []types.KeyType{types.KeyTypeHash, types.KeyTypeRange} {
+		for _, element := range keySchema {
+			if element.KeyType == kt {
+				parts = append(parts, aws.ToString(element.AttributeName)+" "+string(kt))
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return "no key"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,112 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describeClient returns a fixed table description.
+type describeClient struct {
+	DynamoDBClient
+	table *types.TableDescription
+}
+
+func (c *describeClient) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *describeClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if c.table == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	return &dynamodb.DescribeTableOutput{Table: c.table}, nil
+}
+
+type driftItem struct {
+	UserID    string `dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	CreatedAt int64  `dynamodbav:"created_at" dynamo:"created_at,key=range"`
+	Email     string `dynamodbav:"email" dynamo:"email,index=email-index"`
+	Status    string `dynamodbav:"status" dynamo:"status,index=status-index"`
+}
+
+func (d *driftItem) TableName() string {
+	return "Drift"
+}
+
+// liveTable returns the description of the table CreateTable would create.
+func liveTable(t *testing.T) *types.TableDescription {
+	input, err := NewRepository(nil, "Drift").CreateTableInput(&driftItem{})
+	require.NoError(t, err)
+	table := &types.TableDescription{
+		TableName:            input.TableName,
+		KeySchema:            input.KeySchema,
+		AttributeDefinitions: input.AttributeDefinitions,
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName: index.IndexName,
+			KeySchema: index.KeySchema,
+		})
+	}
+	return table
+}
+
+func TestVerifySchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Matching table", func(t *testing.T) {
+		table := liveTable(t)
+		// Indexes that only exist in the table are ignored.
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName: aws.String("legacy-index"),
+			KeySchema: []types.KeySchemaElement{{AttributeName: aws.String("legacy"), KeyType: types.KeyTypeHash}},
+		})
+		repo := NewRepository(&describeClient{table: table}, "Drift")
+		assert.NoError(t, repo.VerifySchema(ctx, &driftItem{}))
+	})
+
+	t.Run("Every difference is reported", func(t *testing.T) {
+		table := liveTable(t)
+		table.KeySchema = table.KeySchema[:1]
+		table.AttributeDefinitions[0].AttributeType = types.ScalarAttributeTypeN
+		table.GlobalSecondaryIndexes = table.GlobalSecondaryIndexes[:1]
+		table.GlobalSecondaryIndexes[0].KeySchema = []types.KeySchemaElement{
+			{AttributeName: aws.String("email"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("created_at"), KeyType: types.KeyTypeRange},
+		}
+		repo := NewRepository(&describeClient{table: table}, "Drift")
+
+		err := repo.VerifySchema(ctx, &driftItem{})
+		require.ErrorIs(t, err, ErrSchemaDrift)
+		var drift *SchemaDriftError
+		require.ErrorAs(t, err, &drift)
+		assert.Equal(t, "Drift", drift.TableName)
+		assert.Equal(t, []SchemaDifference{
+			{Kind: DriftKeySchema, Name: "table", Expected: "user_id HASH, created_at RANGE", Actual: "user_id HASH"},
+			{Kind: DriftAttributeType, Name: "user_id", Expected: "S", Actual: "N"},
+			{Kind: DriftIndexKey, Name: "email-index", Expected: "email HASH", Actual: "email HASH, created_at RANGE"},
+			{Kind: DriftMissingIndex, Name: "status-index", Expected: "status HASH", Actual: "no index"},
+		}, drift.Differences)
+		assert.Contains(t, err.Error(), "MissingIndex status-index")
+	})
+
+	t.Run("Missing table", func(t *testing.T) {
+		repo := NewRepository(&describeClient{}, "Drift")
+		err := repo.VerifySchema(ctx, &driftItem{})
+		var notFound *types.ResourceNotFoundException
+		assert.ErrorAs(t, err, &notFound)
+		assert.NotErrorIs(t, err, ErrSchemaDrift)
+	})
+
+	t.Run("Client without DescribeTable", func(t *testing.T) {
+		repo := NewRepository(&unprocessedClient{}, "Drift")
+		assert.ErrorContains(t, repo.VerifySchema(ctx, &driftItem{}), "does not implement TableManager")
+	})
+}
@@ -0,0 +1,583 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Default attribute names of a SingleTable.
+const (
+	DefaultPartitionKeyAttribute = "PK"
+	DefaultSortKeyAttribute      = "SK"
+	DefaultEntityAttribute       = "_type"
+)
+
+// SingleTable stores several entity types in one table. Each entity is a
+// struct with a blank marker field whose `dynamo` tag names the entity and
+// the templates of its partition and sort keys:
+//
+//	type User struct {
+//		_    struct{} `dynamo:",entity=User,pk=USER#{ID},sk=PROFILE"`
+//		ID   string   `dynamodbav:"id" dynamo:"id"`
+//		Name string   `dynamodbav:"name" dynamo:"name"`
+//	}
+//
+//	type Order struct {
+//		_       struct{} `dynamo:",entity=Order,pk=USER#{UserID},sk=ORDER#{OrderID}"`
+//		UserID  string   `dynamodbav:"user_id" dynamo:"user_id"`
+//		OrderID string   `dynamodbav:"order_id" dynamo:"order_id"`
+//	}
+//
+// A template placeholder refers to a field by its Go name. The rendered keys
+// and the entity name are stored in the PK, SK and _type attributes next to
+// the item's own attributes, so a Query over a partition can be decoded back
+// into the right Go types.
+type SingleTable struct {
+	repo            *Repository
+	tableName       string
+	partitionKey    string
+	sortKey         string
+	entityAttribute string
+	entities        map[string]*entityType
+	types           map[reflect.Type]*entityType
+}
+
+// SingleTableOption configures a SingleTable.
+type SingleTableOption func(*SingleTable)
+
+// WithKeyAttributes sets the names of the partition and sort key attributes
+// of the table. The default is PK and SK.
+func WithKeyAttributes(partitionKey, sortKey string) SingleTableOption {
+	return func(t *SingleTable) {
+		t.partitionKey = partitionKey
+		t.sortKey = sortKey
+	}
+}
+
+// WithEntityAttribute sets the name of the attribute holding the entity name.
+// The default is _type.
+func WithEntityAttribute(name string) SingleTableOption {
+	return func(t *SingleTable) {
+		t.entityAttribute = name
+	}
+}
+
+// NewSingleTable returns a SingleTable for tableName that uses repo's client.
+// An empty tableName uses the repository's default table.
+func NewSingleTable(repo *Repository, tableName string, opts ...SingleTableOption) *SingleTable {
+	if tableName == "" {
+		tableName = repo.tableName
+	}
+	t := &SingleTable{
+		repo:            repo,
+		tableName:       tableName,
+		partitionKey:    DefaultPartitionKeyAttribute,
+		sortKey:         DefaultSortKeyAttribute,
+		entityAttribute: DefaultEntityAttribute,
+		entities:        make(map[string]*entityType),
+		types:           make(map[reflect.Type]*entityType),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// TableName returns the name of the table.
+func (t *SingleTable) TableName() string {
+	return t.tableName
+}
+
+// entityType is a registered entity.
+type entityType struct {
+	name         string
+	typ          reflect.Type
+	partitionKey keyTemplate
+	sortKey      keyTemplate
+}
+
+// keyTemplate is a parsed key template such as ORDER#{OrderID}.
+type keyTemplate struct {
+	source string
+	// literals holds the text around the placeholders, so it always has one
+	// element more than fields, the index paths of the referenced fields.
+	literals []string
+	fields   [][]int
+	names    []string
+}
+
+// Register adds entity types to the table. Each model must be a struct, or a
+// pointer to one, with a marker field declaring `pk=` and `sk=` templates.
+// The entity name defaults to the type name. A model implementing TableNamer
+// must name this table.
+func (t *SingleTable) Register(models ...interface{}) error {
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		if typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return fmt.Errorf("model must be a struct")
+		}
+		entity, err := parseEntityType(typ)
+		if err != nil {
+			return fmt.Errorf("invalid entity %s: %w", typ, err)
+		}
+		if namer, ok := reflect.New(typ).Interface().(TableNamer); ok && namer.TableName() != t.tableName {
+			return fmt.Errorf("invalid entity %s: it belongs to table %s, not %s", typ, namer.TableName(), t.tableName)
+		}
+		if other, ok := t.entities[entity.name]; ok && other.typ != typ {
+			return fmt.Errorf("entity name %s is used by both %s and %s", entity.name, other.typ, typ)
+		}
+		t.entities[entity.name] = entity
+		t.types[typ] = entity
+	}
+	return nil
+}
+
+// parseEntityType reads the marker field of typ.
+func parseEntityType(typ reflect.Type) (*entityType, error) {
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	// meta.encrypted includes the fields of embedded structs.
+	if len(meta.encrypted) > 0 {
+		return nil, fmt.Errorf("field %s: encrypted fields are not supported in a single table", meta.encrypted[0].name)
+	}
+	entity := &entityType{name: typ.Name(), typ: typ}
+	found := false
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("dynamo")
+		if !ok {
+			continue
+		}
+		parser := ParseDynamoTag(tag)
+		if parser.PartitionKey == "" && parser.SortKey == "" && parser.Entity == "" {
+			continue
+		}
+		if found {
+			return nil, fmt.Errorf("more than one field declares entity keys")
+		}
+		found = true
+		if parser.Entity != "" {
+			entity.name = parser.Entity
+		}
+		if parser.PartitionKey == "" || parser.SortKey == "" {
+			return nil, fmt.Errorf("field %s must declare both pk= and sk= templates", field.Name)
+		}
+		var err error
+		if entity.partitionKey, err = parseKeyTemplate(typ, parser.PartitionKey); err != nil {
+			return nil, err
+		}
+		if entity.sortKey, err = parseKeyTemplate(typ, parser.SortKey); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no field declares pk= and sk= templates")
+	}
+	return entity, nil
+}
+
+// parseKeyTemplate parses a template whose placeholders name fields of typ.
+func parseKeyTemplate(typ reflect.Type, source string) (keyTemplate, error) {
+	tmpl := keyTemplate{source: source}
+	rest := source
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return tmpl, fmt.Errorf("template %q has an unmatched }", source)
+			}
+			tmpl.literals = append(tmpl.literals, rest)
+			return tmpl, nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return tmpl, fmt.Errorf("template %q has an unclosed {", source)
+		}
+		name := rest[start+1 : start+end]
+		field, ok := typ.FieldByName(name)
+		if !ok || !field.IsExported() {
+			return tmpl, fmt.Errorf("template %q refers to unknown field %s", source, name)
+		}
+		if !keyTemplateType(field.Type) {
+			return tmpl, fmt.Errorf("template %q: field %s of type %s cannot be used in a key", source, name, field.Type)
+		}
+		tmpl.literals = append(tmpl.literals, rest[:start])
+		tmpl.fields = append(tmpl.fields, field.Index)
+		tmpl.names = append(tmpl.names, name)
+		rest = rest[start+end+1:]
+	}
+}
+
+func keyTemplateType(typ reflect.Type) bool {
+	if typ == timeType {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// keyTimeLayout renders times with a fixed width so that keys sort by time.
+const keyTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// keyIntWidth is the number of digits of the largest uint64. Integers are
+// zero-padded to it so that keys sort numerically.
+const keyIntWidth = 20
+
+func writeKeyInt(b *strings.Builder, n uint64) {
+	digits := strconv.FormatUint(n, 10)
+	b.WriteString(strings.Repeat("0", keyIntWidth-len(digits)))
+	b.WriteString(digits)
+}
+
+// render fills the template with the fields of val so that keys sort like
+// the values. Strings must not be empty, integers must not be negative and
+// are zero-padded to 20 digits, and times are written in UTC with
+// nanoseconds, e.g. 2024-05-01T03:00:00.000000000Z.
+func (k keyTemplate) render(val reflect.Value) (string, error) {
+	var b strings.Builder
+	for i, literal := range k.literals {
+		b.WriteString(literal)
+		if i == len(k.fields) {
+			break
+		}
+		field, err := val.FieldByIndexErr(k.fields[i])
+		if err != nil {
+			return "", fmt.Errorf("field %s used in key %q is empty", k.names[i], k.source)
+		}
+		switch {
+		case field.Type() == timeType:
+			b.WriteString(field.Interface().(time.Time).UTC().Format(keyTimeLayout))
+		case field.Kind() == reflect.String:
+			if field.String() == "" {
+				return "", fmt.Errorf("field %s used in key %q is empty", k.names[i], k.source)
+			}
+			b.WriteString(field.String())
+		case field.CanInt():
+			if field.Int() < 0 {
+				return "", fmt.Errorf("field %s used in key %q is negative", k.names[i], k.source)
+			}
+			writeKeyInt(&b, uint64(field.Int()))
+		default:
+			writeKeyInt(&b, field.Uint())
+		}
+	}
+	return b.String(), nil
+}
+
+// entityOf returns the registered entity of item and its struct value.
+func (t *SingleTable) entityOf(item interface{}) (*entityType, reflect.Value, error) {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, val, fmt.Errorf("item must be a struct")
+	}
+	entity, ok := t.types[val.Type()]
+	if !ok {
+		return nil, val, fmt.Errorf("type %s is not registered with the table", val.Type())
+	}
+	return entity, val, nil
+}
+
+// Key returns the partition and sort keys of item.
+func (t *SingleTable) Key(item interface{}) (partitionKey, sortKey string, err error) {
+	entity, val, err := t.entityOf(item)
+	if err != nil {
+		return "", "", err
+	}
+	if partitionKey, err = entity.partitionKey.render(val); err != nil {
+		return "", "", err
+	}
+	if sortKey, err = entity.sortKey.render(val); err != nil {
+		return "", "", err
+	}
+	return partitionKey, sortKey, nil
+}
+
+// key returns the primary key attributes of item.
+func (t *SingleTable) key(item interface{}) (map[string]types.AttributeValue, error) {
+	partitionKey, sortKey, err := t.Key(item)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		t.partitionKey: &types.AttributeValueMemberS{Value: partitionKey},
+		t.sortKey:      &types.AttributeValueMemberS{Value: sortKey},
+	}, nil
+}
+
+// putInput builds the PutItem request for item.
+func (t *SingleTable) putInput(item interface{}) (*dynamodb.PutItemInput, error) {
+	entity, _, err := t.entityOf(item)
+	if err != nil {
+		return nil, err
+	}
+	if item, err = t.repo.setTimestamps(item, true); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(item); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	key, err := t.key(item)
+	if err != nil {
+		return nil, err
+	}
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item: %w", err)
+	}
+	for name, value := range key {
+		av[name] = value
+	}
+	av[t.entityAttribute] = &types.AttributeValueMemberS{Value: entity.name}
+	return &dynamodb.PutItemInput{
+		TableName: aws.String(t.tableName),
+		Item:      av,
+	}, nil
+}
+
+// Create stores a new item. It returns ErrDuplicateKey if an item with the
+// same keys already exists. The BeforeCreate hook of item is called first.
+func (t *SingleTable) Create(ctx context.Context, item interface{}) error {
+	item, err := beforeCreate(ctx, item)
+	if err != nil {
+		return err
+	}
+	input, err := t.putInput(item)
+	if err != nil {
+		return err
+	}
+	b := newExpressionBuilder()
+	input.ConditionExpression = aws.String(fmt.Sprintf("attribute_not_exists(%s)", b.name(t.partitionKey)))
+	input.ExpressionAttributeNames = b.attributeNames()
+	if _, err := t.repo.client.PutItem(ctx, input); err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrDuplicateKey
+		}
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+// Put stores item, replacing any item with the same keys. Like Create, it
+// calls the BeforeCreate hook of item first.
+func (t *SingleTable) Put(ctx context.Context, item interface{}) error {
+	item, err := beforeCreate(ctx, item)
+	if err != nil {
+		return err
+	}
+	input, err := t.putInput(item)
+	if err != nil {
+		return err
+	}
+	if _, err := t.repo.client.PutItem(ctx, input); err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+// Get loads the item with the keys of item into item, which must be a
+// pointer. It returns ErrNotFound if there is no such item or if it belongs
+// to another entity.
+func (t *SingleTable) Get(ctx context.Context, item interface{}) error {
+	entity, _, err := t.entityOf(item)
+	if err != nil {
+		return err
+	}
+	if reflect.ValueOf(item).Kind() != reflect.Ptr {
+		return fmt.Errorf("item must be a pointer")
+	}
+	key, err := t.key(item)
+	if err != nil {
+		return err
+	}
+	result, err := t.repo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(t.tableName),
+		Key:       key,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get item: %w", err)
+	}
+	if result.Item == nil || t.entityName(result.Item) != entity.name {
+		return ErrNotFound
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, item); err != nil {
+		return fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return callAfterFind(ctx, item)
+}
+
+// Delete deletes the item with the keys of item. It returns ErrNotFound if
+// the item does not exist. The BeforeDelete hook of item is called first.
+func (t *SingleTable) Delete(ctx context.Context, item interface{}) error {
+	if err := beforeDelete(ctx, item); err != nil {
+		return err
+	}
+	key, err := t.key(item)
+	if err != nil {
+		return err
+	}
+	b := newExpressionBuilder()
+	_, err = t.repo.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                aws.String(t.tableName),
+		Key:                      key,
+		ConditionExpression:      aws.String(fmt.Sprintf("attribute_exists(%s)", b.name(t.partitionKey))),
+		ExpressionAttributeNames: b.attributeNames(),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+// Query returns the items of a partition whose sort key begins with
+// sortKeyPrefix, or every item of the partition if it is empty, in sort key
+// order. Each item is decoded into a new pointer to its registered type, so
+// a mixed result can be handled with a type switch. Items of entities that
+// are not registered are skipped.
+func (t *SingleTable) Query(ctx context.Context, partitionKey, sortKeyPrefix string) ([]interface{}, error) {
+	conditions := []Condition{Equal(t.partitionKey, partitionKey)}
+	if sortKeyPrefix != "" {
+		conditions = append(conditions, BeginsWith(t.sortKey, sortKeyPrefix))
+	}
+	b := newExpressionBuilder()
+	keyCondition, err := buildConditions(b, conditions)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(t.tableName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+	}
+	var items []interface{}
+	for {
+		result, err := t.repo.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query items: %w", err)
+		}
+		for _, av := range result.Items {
+			entity, ok := t.entities[t.entityName(av)]
+			if !ok {
+				continue
+			}
+			item := reflect.New(entity.typ).Interface()
+			if err := attributevalue.UnmarshalMap(av, item); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %w", entity.name, err)
+			}
+			if err := callAfterFind(ctx, item); err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// Collection loads the partition of key, a registered entity whose
+// partition key fields are set, into outs. Each out is a pointer to a struct,
+// which receives the item of that entity, or a pointer to a slice of structs
+// or struct pointers, which receives every item of that entity:
+//
+//	var user User
+//	var orders []Order
+//	err := table.Collection(ctx, &User{ID: "u1"}, &user, &orders)
+//
+// Items without a matching out are ignored. Collection returns ErrNotFound
+// if the partition is empty.
+func (t *SingleTable) Collection(ctx context.Context, key interface{}, outs ...interface{}) error {
+	entity, val, err := t.entityOf(key)
+	if err != nil {
+		return err
+	}
+	partitionKey, err := entity.partitionKey.render(val)
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[reflect.Type]reflect.Value, len(outs))
+	for _, out := range outs {
+		ptr := reflect.ValueOf(out)
+		if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+			return fmt.Errorf("out must be a non-nil pointer, got %T", out)
+		}
+		elemType := ptr.Elem().Type()
+		if elemType.Kind() == reflect.Slice {
+			ptr.Elem().SetLen(0)
+			elemType = elemType.Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemType = elemType.Elem()
+			}
+		}
+		if _, ok := t.types[elemType]; !ok {
+			return fmt.Errorf("type %s is not registered with the table", elemType)
+		}
+		if _, ok := targets[elemType]; ok {
+			return fmt.Errorf("more than one out for type %s", elemType)
+		}
+		targets[elemType] = ptr.Elem()
+	}
+
+	items, err := t.Query(ctx, partitionKey, "")
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return ErrNotFound
+	}
+	for _, item := range items {
+		itemVal := reflect.ValueOf(item)
+		target, ok := targets[itemVal.Elem().Type()]
+		if !ok {
+			continue
+		}
+		if target.Kind() != reflect.Slice {
+			target.Set(itemVal.Elem())
+			continue
+		}
+		if target.Type().Elem().Kind() == reflect.Ptr {
+			target.Set(reflect.Append(target, itemVal))
+		} else {
+			target.Set(reflect.Append(target, itemVal.Elem()))
+		}
+	}
+	return nil
+}
+
+// entityName returns the entity attribute of a stored item.
+func (t *SingleTable) entityName(av map[string]types.AttributeValue) string {
+	if s, ok := av[t.entityAttribute].(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package dynamodb
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stOrder struct {
+	_         struct{}  `dynamo:",entity=Order,pk=USER#{UserID},sk=ORDER#{PlacedAt}#{Seq}"`
+	UserID    string    `dynamodbav:"user_id" dynamo:"user_id"`
+	PlacedAt  time.Time `dynamodbav:"placed_at" dynamo:"placed_at"`
+	Seq       uint16    `dynamodbav:"seq" dynamo:"seq"`
+	CreatedAt int64     `dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+}
+
+func TestParseDynamoTag_Entity(t *testing.T) {
+	parser := ParseDynamoTag(",entity=Order,pk=USER#{UserID},sk=ORDER#{OrderID}")
+	assert.Equal(t, "", parser.AttributeName)
+	assert.Equal(t, "Order", parser.Entity)
+	assert.Equal(t, "USER#{UserID}", parser.PartitionKey)
+	assert.Equal(t, "ORDER#{OrderID}", parser.SortKey)
+}
+
+func TestSingleTable_Key(t *testing.T) {
+	table := NewSingleTable(NewRepository(nil, "App"), "")
+	require.NoError(t, table.Register(&stOrder{}))
+	assert.Equal(t, "App", table.TableName())
+
+	placedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	pk, sk, err := table.Key(stOrder{UserID: "u1", PlacedAt: placedAt, Seq: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "USER#u1", pk)
+	assert.Equal(t, "ORDER#2024-05-01T03:00:00.000000000Z#00000000000000000007", sk)
+
+	_, _, err = table.Key(&stOrder{PlacedAt: placedAt})
+	assert.ErrorContains(t, err, "field UserID used in key \"USER#{UserID}\" is empty")
+
+	_, _, err = table.Key(&batchItem{ID: "x"})
+	assert.ErrorContains(t, err, "not registered")
+}
+
+func TestSingleTable_KeySortOrder(t *testing.T) {
+	table := NewSingleTable(NewRepository(nil, "App"), "")
+	require.NoError(t, table.Register(&stOrder{}))
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	orders := []stOrder{
+		{UserID: "u1", PlacedAt: base, Seq: 9},
+		{UserID: "u1", PlacedAt: base, Seq: 10},
+		{UserID: "u1", PlacedAt: base.Add(500 * time.Millisecond), Seq: 1},
+		{UserID: "u1", PlacedAt: base.Add(time.Second), Seq: 1},
+	}
+	var keys []string
+	for _, order := range orders {
+		_, sk, err := table.Key(order)
+		require.NoError(t, err)
+		keys = append(keys, sk)
+	}
+	assert.True(t, sort.StringsAreSorted(keys), "%v", keys)
+
+	type stScore struct {
+		_     struct{} `dynamo:",entity=Score,pk=GAME,sk=SCORE#{Score}"`
+		Score int      `dynamodbav:"score" dynamo:"score"`
+	}
+	require.NoError(t, table.Register(&stScore{}))
+	_, _, err := table.Key(stScore{Score: -1})
+	assert.ErrorContains(t, err, "field Score used in key \"SCORE#{Score}\" is negative")
+}
+
+func TestSingleTable_RegisterErrors(t *testing.T) {
+	type noMarker struct {
+		ID string `dynamo:"id"`
+	}
+	type missingSortKey struct {
+		_  struct{} `dynamo:",pk=A#{ID}"`
+		ID string   `dynamo:"id"`
+	}
+	type unknownField struct {
+		_ struct{} `dynamo:",pk=A#{Missing},sk=B"`
+	}
+	type unclosed struct {
+		_  struct{} `dynamo:",pk=A#{ID,sk=B"`
+		ID string
+	}
+	type badType struct {
+		_    struct{} `dynamo:",pk=A#{Tags},sk=B"`
+		Tags []string
+	}
+	type otherOrder struct {
+		_  struct{} `dynamo:",entity=Order,pk=A#{ID},sk=B"`
+		ID string
+	}
+	type secret struct {
+		SSN string `dynamodbav:"ssn" dynamo:"ssn,encrypt"`
+	}
+	type embeddedSecret struct {
+		_  struct{} `dynamo:",pk=A#{ID},sk=B"`
+		ID string   `dynamodbav:"id" dynamo:"id"`
+		secret
+	}
+
+	table := NewSingleTable(NewRepository(nil, "App"), "App")
+	require.NoError(t, table.Register(&stOrder{}))
+	for _, tc := range []struct {
+		model    interface{}
+		expected string
+	}{
+		{noMarker{}, "no field declares pk= and sk= templates"},
+		{missingSortKey{}, "must declare both pk= and sk= templates"},
+		{unknownField{}, "refers to unknown field Missing"},
+		{unclosed{}, "unclosed {"},
+		{badType{}, "cannot be used in a key"},
+		{otherOrder{}, "entity name Order is used by both"},
+		{embeddedSecret{}, "field SSN: encrypted fields are not supported in a single table"},
+		{"App", "model must be a struct"},
+	} {
+		err := table.Register(tc.model)
+		assert.ErrorContains(t, err, tc.expected, "%T", tc.model)
+	}
+}
+
+// stUser is used to check that Register requires TableNamer to match.
+type stUser struct {
+	_  struct{} `dynamo:",pk=USER#{ID},sk=PROFILE"`
+	ID string   `dynamodbav:"id"`
+}
+
+func (u *stUser) TableName() string {
+	return "Users"
+}
+
+func TestSingleTable_RegisterChecksTableName(t *testing.T) {
+	table := NewSingleTable(NewRepository(nil, "App"), "App")
+	assert.ErrorContains(t, table.Register(&stUser{}), "belongs to table Users, not App")
+	assert.NoError(t, NewSingleTable(NewRepository(nil, "Users"), "").Register(stUser{}))
+}
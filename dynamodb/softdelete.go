@@ -0,0 +1,199 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Purge permanently deletes an item, even when its model declares a
+// `softDelete` field. item is resolved the same way as the argument of
+// Delete, and Purge returns ErrNotFound if the item does not exist. The
+// BeforeDelete hook of a struct item is called first.
+func (r *Repository) Purge(ctx context.Context, item interface{}) error {
+	if err := beforeDelete(ctx, item); err != nil {
+		return err
+	}
+	return r.deleteItem(ctx, item)
+}
+
+// softDelete marks item as deleted with an UpdateItem built by softDeleteInput.
+func (r *Repository) softDelete(ctx context.Context, item interface{}, input *dynamodb.UpdateItemInput, now time.Time) error {
+	_, err := r.client.UpdateItem(ctx, input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if err := r.tenantError(item, ccf.Item); err != nil {
+				return err
+			}
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to soft delete item: %w", err)
+	}
+	return markDeleted(item, now)
+}
+
+// deletionStamp is the value a soft delete writes to a field.
+type deletionStamp struct {
+	field *fieldMeta
+	value reflect.Value
+}
+
+// deletionStamps returns the values of the `softDelete` field and of the
+// `ttl=` field, if any, for an item deleted at now.
+func deletionStamps(meta *modelMeta, now time.Time) []deletionStamp {
+	deletedAt := reflect.New(meta.softDelete.typ).Elem()
+	if deletedAt.Kind() == reflect.Ptr {
+		deletedAt.Set(reflect.New(deletedAt.Type().Elem()))
+		setTimestamp(deletedAt.Elem(), meta.softDelete.tag.TimeUnit, now)
+	} else {
+		setTimestamp(deletedAt, meta.softDelete.tag.TimeUnit, now)
+	}
+	stamps := []deletionStamp{{field: meta.softDelete, value: deletedAt}}
+	if meta.ttl != nil {
+		// DynamoDB TTL expects unix seconds.
+		expiresAt := reflect.New(meta.ttl.typ).Elem()
+		expiresAt.SetInt(now.Add(meta.ttlRetention).Unix())
+		stamps = append(stamps, deletionStamp{field: meta.ttl, value: expiresAt})
+	}
+	return stamps
+}
+
+// softDeleteInput builds the UpdateItem request that soft deletes item at
+// now. It returns nil if item is not a struct whose model declares a
+// `softDelete` field. The request fails its condition if the item does not
+// exist or is already deleted.
+func (r *Repository) softDeleteInput(item interface{}, now time.Time) (*dynamodb.UpdateItemInput, error) {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	if meta.softDelete == nil {
+		return nil, nil
+	}
+	scoped, err := r.scopeItem(item)
+	if err != nil {
+		return nil, err
+	}
+	key, keyAttr, err := primaryKey(reflect.Indirect(reflect.ValueOf(scoped)))
+	if err != nil {
+		return nil, err
+	}
+	b := newExpressionBuilder()
+	var sets []string
+	for _, stamp := range deletionStamps(meta, now) {
+		value, err := b.value(stamp.value.Interface())
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", b.name(stamp.field.tag.AttributeName), value))
+	}
+	condition := fmt.Sprintf("attribute_exists(%s) AND attribute_not_exists(%s)", b.name(keyAttr), b.name(meta.softDelete.tag.AttributeName))
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.getTableName(item)),
+		Key:              key,
+		UpdateExpression: aws.String("SET " + strings.Join(sets, ", ")),
+	}
+	tenantCondition, err := r.tenantCondition(b, meta)
+	if err != nil {
+		return nil, err
+	}
+	if tenantCondition != "" {
+		condition += " AND " + tenantCondition
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	input.ConditionExpression = aws.String(condition)
+	input.ExpressionAttributeNames = b.attributeNames()
+	input.ExpressionAttributeValues = b.attributeValues()
+	return input, nil
+}
+
+// markDeleted stores the deletion stamps in item when it is a pointer.
+func markDeleted(item interface{}, now time.Time) error {
+	val := reflect.ValueOf(item)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	val = val.Elem()
+	meta, err := metadataOf(val.Type())
+	if err != nil || meta.softDelete == nil {
+		return err
+	}
+	for _, stamp := range deletionStamps(meta, now) {
+		stamp.field.settable(val).Set(stamp.value)
+	}
+	return nil
+}
+
+// omitUndeleted removes the `softDelete` and `ttl=` attributes of a new item
+// from av while they are zero, so that reads can tell live items by the
+// absence of the softDelete attribute.
+func omitUndeleted(item interface{}, av map[string]types.AttributeValue) error {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	meta, err := metadataOf(val.Type())
+	if err != nil || meta.softDelete == nil {
+		return err
+	}
+	for _, field := range []*fieldMeta{meta.softDelete, meta.ttl} {
+		if field != nil && field.get(val).IsZero() {
+			delete(av, field.tag.AttributeName)
+		}
+	}
+	return nil
+}
+
+// softDeleted reports whether item is a struct whose model declares a
+// `softDelete` field.
+func softDeleted(item interface{}) bool {
+	typ := reflect.TypeOf(item)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return false
+	}
+	meta, err := metadataOf(typ)
+	return err == nil && meta.softDelete != nil
+}
+
+// deletedItem reports whether av, the stored version of item returned by a
+// failed condition check, has been soft deleted.
+func deletedItem(item interface{}, av map[string]types.AttributeValue) bool {
+	typ := reflect.TypeOf(item)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	meta, err := metadataOf(typ)
+	return err == nil && isDeleted(meta, av)
+}
+
+// isDeleted reports whether the stored item av of a model with a
+// `softDelete` field has been soft deleted.
+func isDeleted(meta *modelMeta, av map[string]types.AttributeValue) bool {
+	if meta.softDelete == nil {
+		return false
+	}
+	value, ok := av[meta.softDelete.tag.AttributeName]
+	if !ok {
+		return false
+	}
+	_, null := value.(*types.AttributeValueMemberNULL)
+	return !null
+}
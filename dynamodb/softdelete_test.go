@@ -0,0 +1,134 @@
+package dynamodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type softDeletedItem struct {
+	ID        string     `dynamodbav:"id" dynamo:"id,key=hash"`
+	Name      string     `dynamodbav:"name" dynamo:"name"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at" dynamo:"deleted_at,softDelete"`
+	ExpiresAt int64      `dynamodbav:"expires_at" dynamo:"expires_at,ttl=720h"`
+}
+
+func TestParseDynamoTag_SoftDelete(t *testing.T) {
+	parser := ParseDynamoTag("deleted_at,softDelete=milli")
+	assert.True(t, parser.SoftDelete)
+	assert.Equal(t, TimeUnitMilli, parser.TimeUnit)
+
+	parser = ParseDynamoTag("expires_at,ttl=720h")
+	assert.Equal(t, "720h", parser.TTL)
+}
+
+func TestMetadataOf_SoftDelete(t *testing.T) {
+	meta, err := metadataOf(reflect.TypeOf(softDeletedItem{}))
+	require.NoError(t, err)
+	assert.Equal(t, "DeletedAt", meta.softDelete.name)
+	assert.Equal(t, "ExpiresAt", meta.ttl.name)
+	assert.Equal(t, 30*24*time.Hour, meta.ttlRetention)
+
+	type twoSoftDeletes struct {
+		ID string `dynamo:"id,key=hash"`
+		A  int64  `dynamo:"a,softDelete"`
+		B  int64  `dynamo:"b,softDelete"`
+	}
+	type stringSoftDelete struct {
+		ID        string `dynamo:"id,key=hash"`
+		DeletedAt string `dynamo:"deleted_at,softDelete"`
+	}
+	type ttlWithoutSoftDelete struct {
+		ID        string `dynamo:"id,key=hash"`
+		ExpiresAt int64  `dynamo:"expires_at,ttl=24h"`
+	}
+	type badTTL struct {
+		ID        string `dynamo:"id,key=hash"`
+		DeletedAt int64  `dynamo:"deleted_at,softDelete"`
+		ExpiresAt int64  `dynamo:"expires_at,ttl=30d"`
+	}
+	for _, tc := range []struct {
+		model    interface{}
+		expected string
+	}{
+		{twoSoftDeletes{}, "only one softDelete field is allowed, found A and B"},
+		{stringSoftDelete{}, "softDelete field DeletedAt must be time.Time, *time.Time or an integer, got string"},
+		{ttlWithoutSoftDelete{}, "ttl field ExpiresAt requires a softDelete field"},
+		{badTTL{}, `field ExpiresAt has invalid ttl "30d"`},
+	} {
+		_, err := metadataOf(reflect.TypeOf(tc.model))
+		assert.ErrorContains(t, err, tc.expected, "%T", tc.model)
+	}
+}
+
+func TestSoftDeleteInput(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewRepository(nil, "Items", fixedClock(now))
+
+	item := &softDeletedItem{ID: "a"}
+	input, err := repo.softDeleteInput(item, now)
+	require.NoError(t, err)
+	assert.Equal(t, "SET #n0 = :v0, #n1 = :v1", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(#n2) AND attribute_not_exists(#n0)", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, map[string]string{"#n0": "deleted_at", "#n1": "expires_at", "#n2": "id"}, input.ExpressionAttributeNames)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "2024-05-01T12:00:00Z"}, input.ExpressionAttributeValues[":v0"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1717156800"}, input.ExpressionAttributeValues[":v1"])
+
+	require.NoError(t, markDeleted(item, now))
+	require.NotNil(t, item.DeletedAt)
+	assert.Equal(t, now, *item.DeletedAt)
+	assert.Equal(t, now.Add(30*24*time.Hour).Unix(), item.ExpiresAt)
+
+	// Models without a softDelete field are deleted as before.
+	input, err = repo.softDeleteInput(&timestampedItem{ID: "a"}, now)
+	require.NoError(t, err)
+	assert.Nil(t, input)
+	input, err = repo.softDeleteInput("a", now)
+	require.NoError(t, err)
+	assert.Nil(t, input)
+}
+
+func TestCreateInput_OmitsSoftDelete(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+	input, err := repo.createInput(&softDeletedItem{ID: "a", Name: "n"})
+	require.NoError(t, err)
+	assert.NotContains(t, input.Item, "deleted_at")
+	assert.NotContains(t, input.Item, "expires_at")
+
+	// Update never writes the soft delete fields and skips deleted items.
+	update, err := repo.updateInput(&softDeletedItem{ID: "a", Name: "n"})
+	require.NoError(t, err)
+	assert.Equal(t, "SET #n0 = :v0", aws.ToString(update.UpdateExpression))
+	assert.Equal(t, "attribute_exists(#n1) AND attribute_not_exists(#n2)", aws.ToString(update.ConditionExpression))
+	assert.Equal(t, "deleted_at", update.ExpressionAttributeNames["#n2"])
+}
+
+func TestRepository_ReadOptions_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingClient{}
+	repo := NewRepository(client, "Items")
+
+	var items []softDeletedItem
+	require.NoError(t, repo.GetAll(ctx, &items))
+	assert.Equal(t, "attribute_not_exists(#n0)", aws.ToString(client.scan.FilterExpression))
+	require.NoError(t, repo.GetAll(ctx, &items, WithDeleted()))
+	assert.Nil(t, client.scan.FilterExpression)
+
+	require.NoError(t, repo.FindByParameter(ctx, "name", "n", &items))
+	assert.Equal(t, "#n0 = :v0 AND attribute_not_exists(#n1)", aws.ToString(client.scan.FilterExpression))
+
+	require.NoError(t, repo.Query("id", "a").Filter(Equal("name", "n")).All(ctx, &items))
+	assert.Equal(t, "(#n1 = :v1) AND attribute_not_exists(#n2)", aws.ToString(client.query.FilterExpression))
+
+	// The softDelete attribute is projected so deleted items can be skipped.
+	var item softDeletedItem
+	require.NoError(t, repo.FindByID(ctx, "a", &item, WithProjection("Name")))
+	assert.Equal(t, "#n0, #n1", aws.ToString(client.get.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#n0": "name", "#n1": "deleted_at"}, client.get.ExpressionAttributeNames)
+}
@@ -0,0 +1,288 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ChangeType classifies a stream record.
+type ChangeType string
+
+// Change types, matching the eventName of a stream record.
+const (
+	ChangeInsert ChangeType = "INSERT"
+	ChangeModify ChangeType = "MODIFY"
+	ChangeRemove ChangeType = "REMOVE"
+)
+
+// Change is a stream record decoded into items of type T.
+type Change[T any] struct {
+	Type ChangeType
+	// Keys holds only the key attributes of the changed item.
+	Keys T
+	// Old is the item before the change. It is nil for inserts and when the
+	// stream does not include old images.
+	Old *T
+	// New is the item after the change. It is nil for removals and when the
+	// stream does not include new images.
+	New *T
+	// Record is the original stream record.
+	Record events.DynamoDBEventRecord
+}
+
+// streamHandler decodes and handles the records of one table.
+type streamHandler func(ctx context.Context, record events.DynamoDBEventRecord) error
+
+// StreamRouter dispatches the records of a DynamoDB Streams event to handlers
+// registered per table with OnChange:
+//
+//	router := db.NewStreamRouter()
+//	err := db.OnChange(router, func(ctx context.Context, change db.Change[User]) error {
+//		...
+//	})
+//	lambda.Start(router.Handle)
+//
+// Models with `encrypt` fields can only be routed when the router has a
+// repository, set with WithStreamRepository, to decrypt their images.
+type StreamRouter struct {
+	handlers map[string]streamHandler
+	repo     *Repository
+}
+
+// StreamRouterOption configures a StreamRouter.
+type StreamRouterOption func(*StreamRouter)
+
+// WithStreamRepository decrypts the `encrypt` fields of stream images with
+// the KeyProvider of repo before they are decoded.
+func WithStreamRepository(repo *Repository) StreamRouterOption {
+	return func(r *StreamRouter) {
+		r.repo = repo
+	}
+}
+
+// NewStreamRouter returns a StreamRouter without handlers.
+func NewStreamRouter(opts ...StreamRouterOption) *StreamRouter {
+	router := &StreamRouter{handlers: make(map[string]streamHandler)}
+	for _, opt := range opts {
+		opt(router)
+	}
+	return router
+}
+
+// OnChange registers fn for the records of the table of T. T must be a struct
+// tagged with `dynamo` that implements TableNamer, on either T or *T; records
+// of that table are decoded into T before fn is called. When T has `encrypt`
+// fields, the router must have been created with WithStreamRepository.
+func OnChange[T any](router *StreamRouter, fn func(ctx context.Context, change Change[T]) error) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if err := validateModelType(typ); err != nil {
+		return fmt.Errorf("invalid model %s: %w", typ, err)
+	}
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return fmt.Errorf("invalid model %s: %w", typ, err)
+	}
+	if len(meta.encrypted) > 0 && router.repo == nil {
+		return fmt.Errorf("invalid model %s: it has encrypted fields: create the router with WithStreamRepository", typ)
+	}
+	namer, ok := interface{}(new(T)).(TableNamer)
+	if !ok {
+		return fmt.Errorf("invalid model %s: it must implement TableNamer", typ)
+	}
+	tableName := namer.TableName()
+	if _, ok := router.handlers[tableName]; ok {
+		return fmt.Errorf("a handler for table %s is already registered", tableName)
+	}
+	router.handlers[tableName] = func(ctx context.Context, record events.DynamoDBEventRecord) error {
+		change, err := decodeChange(record, func(image map[string]events.DynamoDBAttributeValue, out *T) error {
+			return router.unmarshalImage(ctx, meta, tableName, image, out)
+		})
+		if err != nil {
+			return err
+		}
+		return fn(ctx, change)
+	}
+	return nil
+}
+
+// Handle processes the records of event in order. It can be passed to
+// lambda.Start directly; the function's event source mapping must enable
+// ReportBatchItemFailures.
+//
+// When a record fails to decode or its handler returns an error, processing
+// stops and the record is reported in BatchItemFailures, so Lambda retries
+// the batch from that record on. Records of tables without a handler are
+// skipped.
+func (r *StreamRouter) Handle(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	response := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}
+	for _, record := range event.Records {
+		if err := ctx.Err(); err != nil {
+			return withFailure(response, record), nil
+		}
+		handler, ok := r.handlers[streamTableName(record.EventSourceArn)]
+		if !ok {
+			continue
+		}
+		if err := handler(ctx, record); err != nil {
+			return withFailure(response, record), nil
+		}
+	}
+	return response, nil
+}
+
+// withFailure reports record as the first failed record of the batch.
+func withFailure(response events.DynamoDBEventResponse, record events.DynamoDBEventRecord) events.DynamoDBEventResponse {
+	response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+		ItemIdentifier: record.Change.SequenceNumber,
+	})
+	return response
+}
+
+// streamTableName extracts the table name from a stream ARN such as
+// arn:aws:dynamodb:us-east-1:123456789012:table/Users/stream/2024-01-01T00:00:00.000.
+func streamTableName(arn string) string {
+	_, resource, ok := strings.Cut(arn, ":table/")
+	if !ok {
+		return ""
+	}
+	tableName, _, _ := strings.Cut(resource, "/")
+	return tableName
+}
+
+// unmarshalImage decrypts the `encrypt` attributes of image, an item of the
+// model described by meta read from tableName, and unmarshals it into out.
+func (r *StreamRouter) unmarshalImage(ctx context.Context, meta *modelMeta, tableName string, image map[string]events.DynamoDBAttributeValue, out interface{}) error {
+	item, err := FromStreamImage(image)
+	if err != nil {
+		return err
+	}
+	if r.repo != nil {
+		if err := r.repo.openItems(ctx, meta, tableName, []map[string]types.AttributeValue{item}); err != nil {
+			return err
+		}
+	}
+	if err := attributevalue.UnmarshalMap(item, out); err != nil {
+		return fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return nil
+}
+
+// decodeChange decodes the keys and images of record into T, unmarshaling
+// the old and new images with unmarshal.
+func decodeChange[T any](record events.DynamoDBEventRecord, unmarshal func(image map[string]events.DynamoDBAttributeValue, out *T) error) (Change[T], error) {
+	change := Change[T]{Type: ChangeType(record.EventName), Record: record}
+	switch change.Type {
+	case ChangeInsert, ChangeModify, ChangeRemove:
+	default:
+		return change, fmt.Errorf("record %s: unknown event name %q", record.EventID, record.EventName)
+	}
+	if err := UnmarshalStreamImage(record.Change.Keys, &change.Keys); err != nil {
+		return change, fmt.Errorf("record %s: keys: %w", record.EventID, err)
+	}
+	if len(record.Change.OldImage) > 0 {
+		change.Old = new(T)
+		if err := unmarshal(record.Change.OldImage, change.Old); err != nil {
+			return change, fmt.Errorf("record %s: old image: %w", record.EventID, err)
+		}
+	}
+	if len(record.Change.NewImage) > 0 {
+		change.New = new(T)
+		if err := unmarshal(record.Change.NewImage, change.New); err != nil {
+			return change, fmt.Errorf("record %s: new image: %w", record.EventID, err)
+		}
+	}
+	return change, nil
+}
+
+// UnmarshalStreamImage unmarshals a stream image, as found in the Keys,
+// OldImage and NewImage of a stream record, into out using the same rules as
+// the repository.
+func UnmarshalStreamImage(image map[string]events.DynamoDBAttributeValue, out interface{}) error {
+	item, err := FromStreamImage(image)
+	if err != nil {
+		return err
+	}
+	if err := attributevalue.UnmarshalMap(item, out); err != nil {
+		return fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return nil
+}
+
+// FromStreamImage converts a stream image into the attribute values used by
+// the AWS SDK.
+func FromStreamImage(image map[string]events.DynamoDBAttributeValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		av, err := fromStreamAttribute(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = av
+	}
+	return item, nil
+}
+
+func fromStreamAttribute(value events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}, nil
+	case events.DataTypeNumber:
+		if err := checkNumber(value.Number()); err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberN{Value: value.Number()}, nil
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}, nil
+	case events.DataTypeNull:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}, nil
+	case events.DataTypeNumberSet:
+		for _, n := range value.NumberSet() {
+			if err := checkNumber(n); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}, nil
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := value.List()
+		values := make([]types.AttributeValue, len(list))
+		for i, elem := range list {
+			av, err := fromStreamAttribute(elem)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			values[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: values}, nil
+	case events.DataTypeMap:
+		m, err := FromStreamImage(value.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	}
+	return nil, fmt.Errorf("unsupported data type %d", value.DataType())
+}
+
+// checkNumber rejects a number attribute that is not a finite decimal
+// number, which attributevalue would otherwise decode into a string field.
+func checkNumber(n string) error {
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("invalid number %q", n)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamUser struct {
+	ID      string            `dynamodbav:"id" dynamo:"id,key=hash"`
+	Name    string            `dynamodbav:"name" dynamo:"name"`
+	Age     int               `dynamodbav:"age" dynamo:"age"`
+	Active  bool              `dynamodbav:"active" dynamo:"active"`
+	Tags    []string          `dynamodbav:"tags,stringset" dynamo:"tags"`
+	Scores  []int             `dynamodbav:"scores" dynamo:"scores"`
+	Profile map[string]string `dynamodbav:"profile" dynamo:"profile"`
+	Avatar  []byte            `dynamodbav:"avatar" dynamo:"avatar"`
+	Note    *string           `dynamodbav:"note" dynamo:"note"`
+}
+
+func (u *streamUser) TableName() string {
+	return "StreamUsers"
+}
+
+type streamOrder struct {
+	UserID  string `dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID string `dynamodbav:"order_id" dynamo:"order_id,key=range"`
+}
+
+func (o streamOrder) TableName() string {
+	return "StreamOrders"
+}
+
+func streamRecord(table, eventName, sequence string, keys, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:        "event-" + sequence,
+		EventName:      eventName,
+		EventSourceArn: "arn:aws:dynamodb:us-east-1:123456789012:table/" + table + "/stream/2024-01-01T00:00:00.000",
+		Change: events.DynamoDBStreamRecord{
+			Keys:           keys,
+			OldImage:       oldImage,
+			NewImage:       newImage,
+			SequenceNumber: sequence,
+		},
+	}
+}
+
+func userImage(id, name string) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"id":     events.NewStringAttribute(id),
+		"name":   events.NewStringAttribute(name),
+		"age":    events.NewNumberAttribute("42"),
+		"active": events.NewBooleanAttribute(true),
+		"tags":   events.NewStringSetAttribute([]string{"admin"}),
+		"scores": events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewNumberAttribute("1"), events.NewNumberAttribute("2")}),
+		"profile": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"city": events.NewStringAttribute("Tokyo"),
+		}),
+		"avatar": events.NewBinaryAttribute([]byte{1, 2}),
+		"note":   events.NewNullAttribute(),
+	}
+}
+
+func userKeys(id string) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute(id)}
+}
+
+func TestStreamRouter_DecodesChanges(t *testing.T) {
+	router := NewStreamRouter()
+	var changes []Change[streamUser]
+	require.NoError(t, OnChange(router, func(ctx context.Context, change Change[streamUser]) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	var orders []Change[streamOrder]
+	require.NoError(t, OnChange(router, func(ctx context.Context, change Change[streamOrder]) error {
+		orders = append(orders, change)
+		return nil
+	}))
+
+	event := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("StreamUsers", "INSERT", "1", userKeys("u1"), nil, userImage("u1", "Alice")),
+		streamRecord("StreamUsers", "MODIFY", "2", userKeys("u1"), userImage("u1", "Alice"), userImage("u1", "Bob")),
+		streamRecord("StreamOrders", "INSERT", "3", map[string]events.DynamoDBAttributeValue{
+			"user_id":  events.NewStringAttribute("u1"),
+			"order_id": events.NewStringAttribute("o1"),
+		}, nil, nil),
+		streamRecord("Unrelated", "INSERT", "4", userKeys("x"), nil, nil),
+		streamRecord("StreamUsers", "REMOVE", "5", userKeys("u1"), userImage("u1", "Bob"), nil),
+	}}
+	response, err := router.Handle(context.Background(), event)
+	require.NoError(t, err)
+	assert.Empty(t, response.BatchItemFailures)
+
+	require.Len(t, changes, 3)
+	insert := changes[0]
+	assert.Equal(t, ChangeInsert, insert.Type)
+	assert.Equal(t, "u1", insert.Keys.ID)
+	assert.Nil(t, insert.Old)
+	require.NotNil(t, insert.New)
+	assert.Equal(t, streamUser{
+		ID:      "u1",
+		Name:    "Alice",
+		Age:     42,
+		Active:  true,
+		Tags:    []string{"admin"},
+		Scores:  []int{1, 2},
+		Profile: map[string]string{"city": "Tokyo"},
+		Avatar:  []byte{1, 2},
+	}, *insert.New)
+	assert.Equal(t, "1", insert.Record.Change.SequenceNumber)
+
+	assert.Equal(t, ChangeModify, changes[1].Type)
+	assert.Equal(t, "Alice", changes[1].Old.Name)
+	assert.Equal(t, "Bob", changes[1].New.Name)
+
+	assert.Equal(t, ChangeRemove, changes[2].Type)
+	assert.Equal(t, "Bob", changes[2].Old.Name)
+	assert.Nil(t, changes[2].New)
+
+	// KEYS_ONLY streams only carry the keys.
+	require.Len(t, orders, 1)
+	assert.Equal(t, streamOrder{UserID: "u1", OrderID: "o1"}, orders[0].Keys)
+	assert.Nil(t, orders[0].New)
+}
+
+func TestStreamRouter_ReportsFirstFailure(t *testing.T) {
+	router := NewStreamRouter()
+	var handled []string
+	require.NoError(t, OnChange(router, func(ctx context.Context, change Change[streamUser]) error {
+		if change.Keys.ID == "bad" {
+			return errors.New("boom")
+		}
+		handled = append(handled, change.Keys.ID)
+		return nil
+	}))
+
+	event := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("StreamUsers", "INSERT", "1", userKeys("u1"), nil, nil),
+		streamRecord("StreamUsers", "INSERT", "2", userKeys("bad"), nil, nil),
+		streamRecord("StreamUsers", "INSERT", "3", userKeys("u3"), nil, nil),
+	}}
+	response, err := router.Handle(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "2"}}, response.BatchItemFailures)
+	assert.Equal(t, []string{"u1"}, handled)
+
+	// A record that cannot be decoded fails as well.
+	event = events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("StreamUsers", "INSERT", "7", map[string]events.DynamoDBAttributeValue{
+			"id": events.NewNumberAttribute("not-a-number"),
+		}, nil, nil),
+	}}
+	response, err = router.Handle(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "7"}}, response.BatchItemFailures)
+	_, err = FromStreamImage(map[string]events.DynamoDBAttributeValue{
+		"scores": events.NewNumberSetAttribute([]string{"1", "NaN"}),
+	})
+	assert.EqualError(t, err, `attribute scores: invalid number "NaN"`)
+}
+
+func TestOnChange_Errors(t *testing.T) {
+	router := NewStreamRouter()
+	noop := func(ctx context.Context, change Change[streamUser]) error { return nil }
+	require.NoError(t, OnChange(router, noop))
+	assert.ErrorContains(t, OnChange(router, noop), "already registered")
+
+	err := OnChange(router, func(ctx context.Context, change Change[streamWithoutTable]) error { return nil })
+	assert.ErrorContains(t, err, "must implement TableNamer")
+
+	err = OnChange(router, func(ctx context.Context, change Change[streamNoKey]) error { return nil })
+	assert.ErrorContains(t, err, "hash key")
+}
+
+type streamSecret struct {
+	ID  string `dynamodbav:"id" dynamo:"id,key=hash"`
+	SSN string `dynamodbav:"ssn" dynamo:"ssn,encrypt"`
+}
+
+func (streamSecret) TableName() string {
+	return "StreamSecrets"
+}
+
+func TestStreamRouter_Decrypts(t *testing.T) {
+	ctx := context.Background()
+	noop := func(ctx context.Context, change Change[streamSecret]) error { return nil }
+	err := OnChange(NewStreamRouter(), noop)
+	assert.ErrorContains(t, err, "WithStreamRepository")
+
+	repo := NewRepository(nil, "Items", WithKeyProvider(newStaticKeyProvider(t)))
+	item := map[string]types.AttributeValue{
+		"id":  &types.AttributeValueMemberS{Value: "u1"},
+		"ssn": &types.AttributeValueMemberS{Value: "123-45-6789"},
+	}
+	require.NoError(t, repo.sealItem(ctx, &streamSecret{}, "StreamSecrets", item))
+	image := map[string]events.DynamoDBAttributeValue{
+		"id":  events.NewStringAttribute("u1"),
+		"ssn": events.NewBinaryAttribute(item["ssn"].(*types.AttributeValueMemberB).Value),
+	}
+
+	router := NewStreamRouter(WithStreamRepository(repo))
+	var changes []Change[streamSecret]
+	require.NoError(t, OnChange(router, func(ctx context.Context, change Change[streamSecret]) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	response, err := router.Handle(ctx, events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("StreamSecrets", "MODIFY", "1", userKeys("u1"), image, image),
+	}})
+	require.NoError(t, err)
+	assert.Empty(t, response.BatchItemFailures)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "123-45-6789", changes[0].Old.SSN)
+	assert.Equal(t, "123-45-6789", changes[0].New.SSN)
+
+	// A ciphertext moved to another item does not decrypt.
+	moved := map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute("u2"), "ssn": image["ssn"]}
+	response, err = router.Handle(ctx, events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("StreamSecrets", "INSERT", "2", userKeys("u2"), nil, moved),
+	}})
+	require.NoError(t, err)
+	assert.Len(t, response.BatchItemFailures, 1)
+}
+
+type streamWithoutTable struct {
+	ID string `dynamodbav:"id" dynamo:"id,key=hash"`
+}
+
+type streamNoKey struct {
+	ID string `dynamodbav:"id" dynamo:"id"`
+}
+
+func (streamNoKey) TableName() string {
+	return "NoKey"
+}
+
+func TestStreamTableName(t *testing.T) {
+	assert.Equal(t, "Users", streamTableName("arn:aws:dynamodb:us-east-1:123456789012:table/Users/stream/2024-01-01T00:00:00.000"))
+	assert.Equal(t, "", streamTableName("arn:aws:sqs:us-east-1:123456789012:queue"))
+}
@@ -0,0 +1,268 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TableManager is implemented by clients that can create and describe
+// tables, such as *dynamodb.Client. CreateTable and EnsureTable require the
+// repository's client to implement it.
+type TableManager interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+const defaultTableWaitTimeout = 2 * time.Minute
+
+// tableOptions holds the settings used to create a table.
+type tableOptions struct {
+	readCapacity  int64
+	writeCapacity int64
+	waitTimeout   time.Duration
+}
+
+// TableOption configures CreateTable and EnsureTable.
+type TableOption func(*tableOptions)
+
+// WithProvisionedThroughput creates the table and its indexes in provisioned
+// mode with the given capacity. Tables are on-demand (PAY_PER_REQUEST) by default.
+func WithProvisionedThroughput(readCapacity, writeCapacity int64) TableOption {
+	return func(o *tableOptions) {
+		o.readCapacity = readCapacity
+		o.writeCapacity = writeCapacity
+	}
+}
+
+// WithTableWaitTimeout sets how long to wait for a table to become ACTIVE.
+// The default is 2 minutes.
+func WithTableWaitTimeout(timeout time.Duration) TableOption {
+	return func(o *tableOptions) {
+		o.waitTimeout = timeout
+	}
+}
+
+func newTableOptions(opts []TableOption) tableOptions {
+	options := tableOptions{waitTimeout: defaultTableWaitTimeout}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// tableSchema is the key layout of a table derived from a tagged struct.
+type tableSchema struct {
+	hashKey  string
+	rangeKey string
+	// attributes lists the key attributes in field order.
+	attributes     []string
+	attributeTypes map[string]types.ScalarAttributeType
+	indexes        []indexSchema
+}
+
+// indexSchema is a global secondary index declared with `index=`.
+type indexSchema struct {
+	name    string
+	hashKey string
+}
+
+// schemaOf derives the table schema from the `dynamo` tags of typ, including
+// those of embedded structs. Only attributes used as a table or index key get
+// an attribute definition.
+func schemaOf(typ reflect.Type) (*tableSchema, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct, got %s", typ.Kind())
+	}
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	schema := &tableSchema{
+		hashKey:        meta.hashKey,
+		rangeKey:       meta.rangeKey,
+		attributeTypes: make(map[string]types.ScalarAttributeType),
+	}
+	for _, field := range meta.fields {
+		parser := field.tag
+		if parser.KeyType == "" && parser.Index == "" {
+			continue
+		}
+		attributeType, err := scalarAttributeType(field.typ)
+		if err != nil {
+			return nil, fmt.Errorf("key field %s: %w", field.name, err)
+		}
+		schema.attributes = append(schema.attributes, parser.AttributeName)
+		schema.attributeTypes[parser.AttributeName] = attributeType
+		if parser.Index != "" {
+			for _, index := range schema.indexes {
+				if index.name == parser.Index {
+					return nil, fmt.Errorf("index %s is declared on both %s and %s", parser.Index, index.hashKey, parser.AttributeName)
+				}
+			}
+			schema.indexes = append(schema.indexes, indexSchema{name: parser.Index, hashKey: parser.AttributeName})
+		}
+	}
+	if schema.hashKey == "" {
+		return nil, fmt.Errorf("model must declare exactly one hash key")
+	}
+	return schema, nil
+}
+
+var byteSliceType = reflect.TypeOf([]byte(nil))
+
+// scalarAttributeType maps a Go type to the DynamoDB type it is marshaled to.
+func scalarAttributeType(typ reflect.Type) (types.ScalarAttributeType, error) {
+	switch {
+	case typ == timeType:
+		return types.ScalarAttributeTypeS, nil
+	case typ == byteSliceType:
+		return types.ScalarAttributeTypeB, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return types.ScalarAttributeTypeS, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return types.ScalarAttributeTypeN, nil
+	}
+	return "", fmt.Errorf("type %s cannot be used as a key", typ)
+}
+
+// CreateTableInput returns the CreateTable request for the table of model: its
+// name is resolved like for Create, and its key schema, attribute definitions
+// and global secondary indexes come from the `key=` and `index=` options.
+func (r *Repository) CreateTableInput(model interface{}, opts ...TableOption) (*dynamodb.CreateTableInput, error) {
+	options := newTableOptions(opts)
+	typ := reflect.TypeOf(model)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return nil, fmt.Errorf("model must be a struct")
+	}
+	schema, err := schemaOf(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(r.getTableName(model)),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(schema.hashKey), KeyType: types.KeyTypeHash},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	if schema.rangeKey != "" {
+		input.KeySchema = append(input.KeySchema, types.KeySchemaElement{
+			AttributeName: aws.String(schema.rangeKey),
+			KeyType:       types.KeyTypeRange,
+		})
+	}
+	var throughput *types.ProvisionedThroughput
+	if options.readCapacity > 0 || options.writeCapacity > 0 {
+		throughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(options.readCapacity),
+			WriteCapacityUnits: aws.Int64(options.writeCapacity),
+		}
+		input.BillingMode = types.BillingModeProvisioned
+		input.ProvisionedThroughput = throughput
+	}
+	// Attribute definitions are emitted in field order so the request is stable.
+	for _, attribute := range schema.attributes {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(attribute),
+			AttributeType: schema.attributeTypes[attribute],
+		})
+	}
+	for _, index := range schema.indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName: aws.String(index.name),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String(index.hashKey), KeyType: types.KeyTypeHash},
+			},
+			Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			ProvisionedThroughput: throughput,
+		})
+	}
+	return input, nil
+}
+
+// CreateTable creates the table of model (see CreateTableInput) and waits
+// until it is ACTIVE. It fails if the table already exists.
+func (r *Repository) CreateTable(ctx context.Context, model interface{}, opts ...TableOption) error {
+	manager, err := r.tableManager()
+	if err != nil {
+		return err
+	}
+	input, err := r.CreateTableInput(model, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := manager.CreateTable(ctx, input); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", aws.ToString(input.TableName), err)
+	}
+	return waitForTable(ctx, manager, aws.ToString(input.TableName), opts)
+}
+
+// EnsureTable creates the table of model if it does not exist yet and waits
+// until it is ACTIVE. An existing table is left unchanged.
+func (r *Repository) EnsureTable(ctx context.Context, model interface{}, opts ...TableOption) error {
+	manager, err := r.tableManager()
+	if err != nil {
+		return err
+	}
+	input, err := r.CreateTableInput(model, opts...)
+	if err != nil {
+		return err
+	}
+	tableName := aws.ToString(input.TableName)
+	_, err = manager.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: input.TableName})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case err == nil:
+	case errors.As(err, &notFound):
+		if _, err := manager.CreateTable(ctx, input); err != nil {
+			// Another process may have created the table in the meantime.
+			var inUse *types.ResourceInUseException
+			if !errors.As(err, &inUse) {
+				return fmt.Errorf("failed to create table %s: %w", tableName, err)
+			}
+		}
+	default:
+		return fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+	return waitForTable(ctx, manager, tableName, opts)
+}
+
+func (r *Repository) tableManager() (TableManager, error) {
+	client := r.client
+	if retrying, ok := client.(*retryClient); ok {
+		client = retrying.DynamoDBClient
+	}
+	manager, ok := client.(TableManager)
+	if !ok {
+		return nil, fmt.Errorf("client %T does not implement TableManager", client)
+	}
+	return manager, nil
+}
+
+// waitForTable polls DescribeTable until the table is ACTIVE.
+func waitForTable(ctx context.Context, manager TableManager, tableName string, opts []TableOption) error {
+	options := newTableOptions(opts)
+	waiter := dynamodb.NewTableExistsWaiter(manager, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = time.Second
+		o.MaxDelay = 10 * time.Second
+	})
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}, options.waitTimeout); err != nil {
+		return fmt.Errorf("table %s did not become active: %w", tableName, err)
+	}
+	return nil
+}
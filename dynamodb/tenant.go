@@ -0,0 +1,382 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrTenantRequired is returned by a TenantRepository when no tenant can
+	// be resolved from the context.
+	ErrTenantRequired = errors.New("no tenant in context")
+	// ErrCrossTenant is matched by a CrossTenantError.
+	ErrCrossTenant = errors.New("item belongs to another tenant")
+)
+
+// CrossTenantError is returned by a TenantRepository when an item belongs,
+// or would be written, to a tenant other than the one of the context. It
+// does not name the other tenant.
+type CrossTenantError struct {
+	// Tenant is the tenant of the context.
+	Tenant string
+}
+
+// Error implements the error interface
+func (e *CrossTenantError) Error() string {
+	return fmt.Sprintf("tenant %s cannot access an item of another tenant", e.Tenant)
+}
+
+// Unwrap returns ErrCrossTenant.
+func (e *CrossTenantError) Unwrap() error {
+	return ErrCrossTenant
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx carrying tenant, for example the
+// `custom:tenant_id` claim of a Cognito ID token.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored by WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantResolver returns the tenant of a request.
+type TenantResolver func(ctx context.Context) (string, error)
+
+// TenantOption configures a TenantRepository.
+type TenantOption func(*TenantRepository)
+
+// WithTenantResolver sets the function resolving the tenant of each call.
+// It defaults to TenantFromContext.
+func WithTenantResolver(resolve TenantResolver) TenantOption {
+	return func(t *TenantRepository) {
+		t.resolve = resolve
+	}
+}
+
+// TenantRepository scopes a Repository to the tenant of each call's context.
+// Models must declare their hash key as a string field tagged `tenant`, so
+// that every tenant has its own key space:
+//
+//	type Invoice struct {
+//		TenantID string `dynamodbav:"tenant_id" dynamo:"tenant_id,key=hash,tenant"`
+//		ID       string `dynamodbav:"id" dynamo:"id,key=range"`
+//	}
+//
+// Writes fill the tenant field and are conditioned on the stored item
+// belonging to the tenant. Reads, queries and scans filter on the tenant.
+// An item of another tenant is refused with a *CrossTenantError, and a call
+// whose context carries no tenant fails with ErrTenantRequired.
+type TenantRepository struct {
+	repo    *Repository
+	resolve TenantResolver
+}
+
+// NewTenantRepository returns a TenantRepository backed by repo.
+func NewTenantRepository(repo *Repository, opts ...TenantOption) *TenantRepository {
+	t := &TenantRepository{
+		repo: repo,
+		resolve: func(ctx context.Context) (string, error) {
+			tenant, ok := TenantFromContext(ctx)
+			if !ok {
+				return "", ErrTenantRequired
+			}
+			return tenant, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// scoped returns a copy of the repository scoped to the tenant of ctx.
+func (t *TenantRepository) scoped(ctx context.Context) (*Repository, error) {
+	tenant, err := t.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenant == "" {
+		return nil, ErrTenantRequired
+	}
+	r := *t.repo
+	r.tenant = tenant
+	return &r, nil
+}
+
+// Create stores an item of the tenant. See Repository.Create.
+func (t *TenantRepository) Create(ctx context.Context, item interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Create(ctx, item)
+}
+
+// BatchCreate stores items of the tenant. See Repository.BatchCreate.
+func (t *TenantRepository) BatchCreate(ctx context.Context, items interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.BatchCreate(ctx, items)
+}
+
+// Update updates an item of the tenant. See Repository.Update.
+func (t *TenantRepository) Update(ctx context.Context, item interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Update(ctx, item)
+}
+
+// Patch applies update to an item of the tenant. The tenant attribute
+// cannot be updated. See Repository.Patch.
+func (t *TenantRepository) Patch(ctx context.Context, item interface{}, update *UpdateBuilder) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Patch(ctx, item, update)
+}
+
+// Delete deletes an item of the tenant, which must be a struct. See
+// Repository.Delete.
+func (t *TenantRepository) Delete(ctx context.Context, item interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Delete(ctx, item)
+}
+
+// Purge permanently deletes an item of the tenant, which must be a struct.
+// See Repository.Purge.
+func (t *TenantRepository) Purge(ctx context.Context, item interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.Purge(ctx, item)
+}
+
+// BatchDelete deletes items of the tenant, which must be structs. Their
+// tenant key is filled like on writes, so only items of the tenant are
+// deleted. See Repository.BatchDelete.
+func (t *TenantRepository) BatchDelete(ctx context.Context, items interface{}) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.BatchDelete(ctx, items)
+}
+
+// FindByID retrieves an item of the tenant by id. The hash key is the
+// tenant, so id is the range key of models that have one.
+func (t *TenantRepository) FindByID(ctx context.Context, id interface{}, out interface{}, opts ...ReadOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.FindByID(ctx, id, out, opts...)
+}
+
+// FindByKey retrieves an item of the tenant by its hash key and range key.
+// An empty hash key is filled with the tenant.
+func (t *TenantRepository) FindByKey(ctx context.Context, hashKey, rangeKey interface{}, out interface{}, opts ...ReadOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.FindByKey(ctx, hashKey, rangeKey, out, opts...)
+}
+
+// FindByParameter retrieves every item of the tenant with the given
+// parameter value. See Repository.FindByParameter.
+func (t *TenantRepository) FindByParameter(ctx context.Context, parameter string, value interface{}, out interface{}, opts ...ReadOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.FindByParameter(ctx, parameter, value, out, opts...)
+}
+
+// FindByParameterPage retrieves one page of the items of the tenant with the
+// given parameter value. See Repository.FindByParameterPage.
+func (t *TenantRepository) FindByParameterPage(ctx context.Context, parameter string, value interface{}, pageSize int32, cursor string, out interface{}, opts ...ReadOption) (string, error) {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.FindByParameterPage(ctx, parameter, value, pageSize, cursor, out, opts...)
+}
+
+// GetAll retrieves every item of the tenant with a filtered Scan. Prefer a
+// Query on the tenant key for large tables.
+func (t *TenantRepository) GetAll(ctx context.Context, out interface{}, opts ...ReadOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.GetAll(ctx, out, opts...)
+}
+
+// GetPage retrieves one page of the items of the tenant. See
+// Repository.GetPage.
+func (t *TenantRepository) GetPage(ctx context.Context, pageSize int32, cursor string, out interface{}, opts ...ReadOption) (string, error) {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return "", err
+	}
+	return r.GetPage(ctx, pageSize, cursor, out, opts...)
+}
+
+// BatchFindByIDs retrieves items of the tenant by id, which is the range key
+// of models that have one. It fails with a *CrossTenantError if any of them
+// belongs to another tenant. See Repository.BatchFindByIDs.
+func (t *TenantRepository) BatchFindByIDs(ctx context.Context, ids interface{}, out interface{}, opts ...ReadOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.BatchFindByIDs(ctx, ids, out, opts...)
+}
+
+// Query starts a query restricted to the items of the tenant. Querying the
+// tenant attribute itself with another tenant fails with a
+// *CrossTenantError.
+func (t *TenantRepository) Query(ctx context.Context, attribute string, value interface{}) *QueryBuilder {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return &QueryBuilder{repo: t.repo, err: err}
+	}
+	return r.Query(attribute, value)
+}
+
+// ParallelScan calls fn for every item of the tenant. See
+// Repository.ParallelScan.
+func (t *TenantRepository) ParallelScan(ctx context.Context, model interface{}, fn func(item interface{}) error, opts ...ScanOption) error {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	return r.ParallelScan(ctx, model, fn, opts...)
+}
+
+// Transaction starts a transaction whose operations are scoped to the
+// tenant. A failed operation on an item of another tenant is reported with
+// a *CrossTenantError in its TransactionFailure.
+func (t *TenantRepository) Transaction(ctx context.Context) *Transaction {
+	r, err := t.scoped(ctx)
+	if err != nil {
+		return &Transaction{repo: t.repo, err: err}
+	}
+	return r.Transaction()
+}
+
+// tenantField returns the tenant field of meta, or nil if r is not scoped to
+// a tenant. Scoped repositories refuse models without a tenant field.
+func (r *Repository) tenantField(meta *modelMeta) (*fieldMeta, error) {
+	if r.tenant == "" {
+		return nil, nil
+	}
+	if meta.tenant == nil {
+		return nil, noTenantField(meta)
+	}
+	return meta.tenant, nil
+}
+
+// noTenantField is the error of a scoped repository given a model without a
+// tenant field.
+func noTenantField(meta *modelMeta) error {
+	return fmt.Errorf("model %s has no field tagged tenant", meta.typ)
+}
+
+// scopeItem fills the tenant field of item, a struct, with the tenant of r
+// and returns item like setTimestamps does. It fails with a
+// *CrossTenantError if the field already holds another tenant. Repositories
+// that are not scoped return item unchanged.
+func (r *Repository) scopeItem(item interface{}) (interface{}, error) {
+	if r.tenant == "" {
+		return item, nil
+	}
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("a tenant-scoped item must be a struct, got %T", item)
+	}
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	field, err := r.tenantField(meta)
+	if err != nil {
+		return nil, err
+	}
+	current := field.get(val).String()
+	if current == r.tenant {
+		return item, nil
+	}
+	if current != "" {
+		return nil, &CrossTenantError{Tenant: r.tenant}
+	}
+	if !val.CanAddr() {
+		copied := reflect.New(val.Type())
+		copied.Elem().Set(val)
+		item, val = copied.Interface(), copied.Elem()
+	}
+	field.settable(val).SetString(r.tenant)
+	return item, nil
+}
+
+// tenantCondition returns the condition that the stored item belongs to the
+// tenant of r, or an empty string if r is not scoped to a tenant.
+func (r *Repository) tenantCondition(b *expressionBuilder, meta *modelMeta) (string, error) {
+	field, err := r.tenantField(meta)
+	if err != nil || field == nil {
+		return "", err
+	}
+	value, err := b.value(r.tenant)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s = %s", b.name(field.tag.AttributeName), value), nil
+}
+
+// tenantError returns a *CrossTenantError if stored, the item returned by a
+// failed condition check on item, belongs to another tenant than r.
+func (r *Repository) tenantError(item interface{}, stored map[string]types.AttributeValue) error {
+	if r.tenant == "" || stored == nil {
+		return nil
+	}
+	typ := reflect.TypeOf(item)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	meta, err := metadataOf(typ)
+	if err != nil || meta.tenant == nil {
+		return nil
+	}
+	return checkTenant(r.tenant, meta, stored)
+}
+
+// checkTenant returns a *CrossTenantError unless the stored item av of meta
+// belongs to tenant.
+func checkTenant(tenant string, meta *modelMeta, av map[string]types.AttributeValue) error {
+	if s, ok := av[meta.tenant.tag.AttributeName].(*types.AttributeValueMemberS); !ok || s.Value != tenant {
+		return &CrossTenantError{Tenant: tenant}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package dynamodb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantKeyedItem uses the tenant as its partition key.
+type tenantKeyedItem struct {
+	TenantID string `dynamodbav:"tenant_id" dynamo:"tenant_id,key=hash,tenant"`
+	ID       string `dynamodbav:"id" dynamo:"id,key=range"`
+	Name     string `dynamodbav:"name" dynamo:"name"`
+}
+
+func scopedRepository(client DynamoDBClient, tenant string) *Repository {
+	r := NewRepository(client, "Items")
+	r.tenant = tenant
+	return r
+}
+
+func TestMetadataOf_Tenant(t *testing.T) {
+	assert.True(t, ParseDynamoTag("tenant_id,tenant").Tenant)
+	meta, err := metadataOf(reflect.TypeOf(tenantKeyedItem{}))
+	require.NoError(t, err)
+	assert.Equal(t, "TenantID", meta.tenant.name)
+
+	type intTenant struct {
+		Tenant int    `dynamo:"tenant,key=hash,tenant"`
+		ID     string `dynamo:"id,key=range"`
+	}
+	type attributeTenant struct {
+		ID     string `dynamo:"id,key=hash"`
+		Tenant string `dynamo:"tenant,tenant"`
+	}
+	type rangeTenant struct {
+		ID     string `dynamo:"id,key=hash"`
+		Tenant string `dynamo:"tenant,key=range,tenant"`
+	}
+	for _, tc := range []struct {
+		model    interface{}
+		expected string
+	}{
+		{intTenant{}, "tenant field Tenant must be a string, got int"},
+		{attributeTenant{}, "tenant field Tenant must be the hash key"},
+		{rangeTenant{}, "tenant field Tenant must be the hash key"},
+	} {
+		_, err := metadataOf(reflect.TypeOf(tc.model))
+		assert.ErrorContains(t, err, tc.expected, "%T", tc.model)
+	}
+}
+
+func TestTenantRepository_Writes(t *testing.T) {
+	repo := scopedRepository(nil, "acme")
+
+	// Value items are copied before the tenant is set in the partition key.
+	input, err := repo.createInput(tenantKeyedItem{ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "acme"}, input.Item["tenant_id"])
+
+	// The error does not name the other tenant.
+	_, err = repo.createInput(&tenantKeyedItem{ID: "a", TenantID: "globex"})
+	var crossTenant *CrossTenantError
+	require.ErrorAs(t, err, &crossTenant)
+	assert.Equal(t, CrossTenantError{Tenant: "acme"}, *crossTenant)
+	assert.NotContains(t, err.Error(), "globex")
+
+	update, err := repo.updateInput(&tenantKeyedItem{ID: "a", Name: "n"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.AttributeValue{
+		"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+		"id":        &types.AttributeValueMemberS{Value: "a"},
+	}, update.Key)
+	assert.Contains(t, aws.ToString(update.ConditionExpression), "#n1 = :v1")
+
+	remove, err := repo.deleteInput(&tenantKeyedItem{ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "acme"}, remove.Key["tenant_id"])
+
+	_, err = repo.patchInput(&tenantKeyedItem{ID: "a"}, NewUpdate().Set("name", "n"))
+	require.NoError(t, err)
+	require.NoError(t, repo.BatchCreate(context.Background(), []tenantKeyedItem{}))
+
+	_, err = repo.createInput(&timestampedItem{ID: "a"})
+	assert.ErrorContains(t, err, "has no field tagged tenant")
+}
+
+func TestTenantRepository_Reads(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingClient{}
+	repo := scopedRepository(client, "acme")
+
+	var items []tenantKeyedItem
+	require.NoError(t, repo.GetAll(ctx, &items))
+	assert.Equal(t, "#n0 = :v0", aws.ToString(client.scan.FilterExpression))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "acme"}, client.scan.ExpressionAttributeValues[":v0"])
+
+	// A query on the tenant key is checked instead of filtered.
+	require.NoError(t, repo.Query("tenant_id", "acme").All(ctx, &items))
+	assert.Nil(t, client.query.FilterExpression)
+	err := repo.Query("tenant_id", "globex").All(ctx, &items)
+	assert.ErrorIs(t, err, ErrCrossTenant)
+	assert.NotContains(t, err.Error(), "globex")
+	var item tenantKeyedItem
+	assert.ErrorIs(t, repo.FindByKey(ctx, "globex", "a", &item), ErrCrossTenant)
+
+	// The tenant fills the hash key of a composite key.
+	require.NoError(t, repo.FindByID(ctx, "a", &item))
+	assert.Equal(t, map[string]types.AttributeValue{
+		"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+		"id":        &types.AttributeValueMemberS{Value: "a"},
+	}, client.get.Key)
+
+	// The tenant attribute is projected so the item can be checked.
+	require.NoError(t, repo.FindByKey(ctx, "acme", "a", &item, WithProjection("Name")))
+	assert.Equal(t, "#n0, #n1", aws.ToString(client.get.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#n0": "name", "#n1": "tenant_id"}, client.get.ExpressionAttributeNames)
+}
+
+func TestTenantRepository_Context(t *testing.T) {
+	ctx := context.Background()
+	_, ok := TenantFromContext(ctx)
+	assert.False(t, ok)
+	tenant, ok := TenantFromContext(WithTenant(ctx, "acme"))
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenant)
+
+	repo := NewRepository(nil, "Items")
+	_, err := NewTenantRepository(repo).scoped(ctx)
+	assert.ErrorIs(t, err, ErrTenantRequired)
+	scoped, err := NewTenantRepository(repo, WithTenantResolver(func(context.Context) (string, error) {
+		return "globex", nil
+	})).scoped(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "globex", scoped.tenant)
+	assert.Empty(t, repo.tenant)
+}
@@ -0,0 +1,68 @@
+package dynamodb
+
+import (
+	"reflect"
+	"time"
+)
+
+// TimeUnitMilli stores an `autoCreateTime` or `autoUpdateTime` integer field
+// as unix milliseconds, e.g. `dynamo:"created_at,autoCreateTime=milli"`.
+// Integer fields without a unit hold unix seconds.
+const TimeUnitMilli = "milli"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setTimestamps fills the timestamp fields of item with the repository clock.
+// When creating, `autoCreateTime` fields are only filled if they are zero so
+// that imported items keep their original creation time.
+//
+// A pointer item is updated in place. Any other struct is copied, and the
+// returned pointer to the copy must be used instead of item. Values that are
+// not structs are returned unchanged.
+func (r *Repository) setTimestamps(item interface{}, creating bool) (interface{}, error) {
+	val := reflect.ValueOf(item)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return item, nil
+	}
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	createFields, updateFields := meta.createFields, meta.updateFields
+	if len(updateFields) == 0 && (!creating || len(createFields) == 0) {
+		return item, nil
+	}
+	if !val.CanAddr() {
+		copied := reflect.New(val.Type())
+		copied.Elem().Set(val)
+		item, val = copied.Interface(), copied.Elem()
+	}
+	now := r.now()
+	if creating {
+		for _, field := range createFields {
+			if value := field.settable(val); value.IsZero() {
+				setTimestamp(value, field.tag.TimeUnit, now)
+			}
+		}
+	}
+	for _, field := range updateFields {
+		setTimestamp(field.settable(val), field.tag.TimeUnit, now)
+	}
+	return item, nil
+}
+
+// setTimestamp stores now in a time.Time or integer field.
+func setTimestamp(field reflect.Value, unit string, now time.Time) {
+	if field.Type() == timeType {
+		field.Set(reflect.ValueOf(now))
+		return
+	}
+	if unit == TimeUnitMilli {
+		field.SetInt(now.UnixMilli())
+		return
+	}
+	field.SetInt(now.Unix())
+}
@@ -0,0 +1,83 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timestampedItem struct {
+	ID        string    `dynamodbav:"id" dynamo:"id,key=hash"`
+	Name      string    `dynamodbav:"name" dynamo:"name"`
+	CreatedAt int64     `dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+	UpdatedAt int64     `dynamodbav:"updated_at" dynamo:"updated_at,autoUpdateTime=milli"`
+	SeenAt    time.Time `dynamodbav:"seen_at" dynamo:"seen_at,autoUpdateTime"`
+}
+
+type invalidTimestampItem struct {
+	ID        string `dynamo:"id,key=hash"`
+	CreatedAt string `dynamo:"created_at,autoCreateTime"`
+}
+
+func fixedClock(t time.Time) RepositoryOption {
+	return WithClock(func() time.Time { return t })
+}
+
+func TestParseDynamoTag_Timestamps(t *testing.T) {
+	parser := ParseDynamoTag("created_at,autoCreateTime")
+	assert.True(t, parser.AutoCreateTime)
+	assert.Empty(t, parser.TimeUnit)
+
+	parser = ParseDynamoTag("updated_at,autoUpdateTime=milli")
+	assert.True(t, parser.AutoUpdateTime)
+	assert.Equal(t, TimeUnitMilli, parser.TimeUnit)
+}
+
+func TestCreateInput_SetsTimestamps(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewRepository(nil, "Items", fixedClock(now))
+
+	item := &timestampedItem{ID: "a"}
+	input, err := repo.createInput(item)
+	require.NoError(t, err)
+	assert.Equal(t, now.Unix(), item.CreatedAt)
+	assert.Equal(t, now.UnixMilli(), item.UpdatedAt)
+	assert.Equal(t, now, item.SeenAt)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1714564800"}, input.Item["created_at"])
+
+	// 値渡しでも保存される値には反映される
+	input, err = repo.createInput(timestampedItem{ID: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1714564800000"}, input.Item["updated_at"])
+
+	// 作成日時が設定済みなら上書きしない
+	imported := &timestampedItem{ID: "c", CreatedAt: 1}
+	_, err = repo.createInput(imported)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), imported.CreatedAt)
+}
+
+func TestUpdateInput_SetsOnlyUpdateTimestamps(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewRepository(nil, "Items", fixedClock(now))
+
+	item := &timestampedItem{ID: "a", Name: "x"}
+	input, err := repo.updateInput(item)
+	require.NoError(t, err)
+	assert.Zero(t, item.CreatedAt)
+	assert.Equal(t, now.UnixMilli(), item.UpdatedAt)
+	assert.NotContains(t, aws.ToString(input.UpdateExpression), "created_at")
+	for _, name := range input.ExpressionAttributeNames {
+		assert.NotEqual(t, "created_at", name)
+	}
+}
+
+func TestTimestampFields_Invalid(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+	_, err := repo.createInput(&invalidTimestampItem{ID: "a"})
+	assert.ErrorContains(t, err, "must be time.Time or an integer")
+}
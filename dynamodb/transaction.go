@@ -0,0 +1,389 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTransactionItems is the DynamoDB limit for a single TransactWriteItems call.
+const maxTransactionItems = 100
+
+// ErrConditionFailed is matched by a TransactionError when a ConditionCheck
+// operation did not hold.
+var ErrConditionFailed = errors.New("condition check failed")
+
+// Transaction operation names reported in TransactionFailure.
+const (
+	OperationCreate         = "Create"
+	OperationUpdate         = "Update"
+	OperationDelete         = "Delete"
+	OperationConditionCheck = "ConditionCheck"
+)
+
+// Transaction collects writes to several items, possibly in different tables,
+// and commits them atomically with TransactWriteItems. Create one with
+// Repository.Transaction:
+//
+//	err := repo.Transaction().
+//		Create(order).
+//		Create(lineItem).
+//		Update(stock).
+//		Commit(ctx)
+//
+// Each operation behaves like the Repository method of the same name:
+// Create fails if the key exists, Update and Delete fail if it does not.
+type Transaction struct {
+	repo *Repository
+	// ops build the operations in the order they were added once Commit has
+	// a context to call the hooks and the KeyProvider with.
+	ops        []func(ctx context.Context) error
+	items      []types.TransactWriteItem
+	operations []string
+	tables     []string
+	// values holds the item passed to each operation.
+	values      []interface{}
+	afterCommit []func() error
+	err         error
+}
+
+// Transaction starts an empty transaction.
+func (r *Repository) Transaction() *Transaction {
+	return &Transaction{repo: r}
+}
+
+// queue adds an operation that op builds when the transaction is committed.
+func (t *Transaction) queue(op func(ctx context.Context) error) *Transaction {
+	t.ops = append(t.ops, op)
+	return t
+}
+
+// Create adds a put that fails if an item with the same key already exists.
+// The BeforeCreate hook of item is called by Commit.
+func (t *Transaction) Create(item interface{}) *Transaction {
+	return t.queue(func(ctx context.Context) error {
+		item, err := beforeCreate(ctx, item)
+		if err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		input, err := t.repo.createInput(item)
+		if err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		if err := t.repo.sealItem(ctx, item, aws.ToString(input.TableName), input.Item); err != nil {
+			return err
+		}
+		t.add(OperationCreate, aws.ToString(input.TableName), item, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                input.TableName,
+				Item:                     input.Item,
+				ConditionExpression:      input.ConditionExpression,
+				ExpressionAttributeNames: input.ExpressionAttributeNames,
+			},
+		})
+		return nil
+	})
+}
+
+// Update adds an update of every non-key field that fails if the item does
+// not exist or, for versioned items, if its version has changed. The version
+// field of item is incremented once the transaction is committed. The
+// BeforeUpdate hook of item is called by Commit.
+func (t *Transaction) Update(item interface{}) *Transaction {
+	return t.queue(func(ctx context.Context) error {
+		item, err := beforeUpdate(ctx, item)
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		input, err := t.repo.updateInput(item)
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		if err := t.repo.sealUpdate(ctx, item, aws.ToString(input.TableName), input.Key, input.ExpressionAttributeValues); err != nil {
+			return err
+		}
+		t.add(OperationUpdate, aws.ToString(input.TableName), item, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                           input.TableName,
+				Key:                                 input.Key,
+				UpdateExpression:                    input.UpdateExpression,
+				ConditionExpression:                 input.ConditionExpression,
+				ExpressionAttributeNames:            input.ExpressionAttributeNames,
+				ExpressionAttributeValues:           input.ExpressionAttributeValues,
+				ReturnValuesOnConditionCheckFailure: input.ReturnValuesOnConditionCheckFailure,
+			},
+		})
+		t.afterCommit = append(t.afterCommit, func() error {
+			return incrementVersion(item)
+		})
+		return nil
+	})
+}
+
+// Delete adds a delete that fails if the item does not exist. item is
+// resolved the same way as the argument of Repository.Delete; models with a
+// `softDelete` field are soft deleted with an update. The BeforeDelete hook
+// of item is called by Commit.
+func (t *Transaction) Delete(item interface{}) *Transaction {
+	return t.queue(func(ctx context.Context) error {
+		if err := beforeDelete(ctx, item); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		now := t.repo.now()
+		update, err := t.repo.softDeleteInput(item, now)
+		if err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		if update == nil {
+			return t.purge(item)
+		}
+		t.add(OperationDelete, aws.ToString(update.TableName), item, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                           update.TableName,
+				Key:                                 update.Key,
+				UpdateExpression:                    update.UpdateExpression,
+				ConditionExpression:                 update.ConditionExpression,
+				ExpressionAttributeNames:            update.ExpressionAttributeNames,
+				ExpressionAttributeValues:           update.ExpressionAttributeValues,
+				ReturnValuesOnConditionCheckFailure: update.ReturnValuesOnConditionCheckFailure,
+			},
+		})
+		t.afterCommit = append(t.afterCommit, func() error {
+			return markDeleted(item, now)
+		})
+		return nil
+	})
+}
+
+// Purge adds a delete that fails if the item does not exist, even when the
+// model of item declares a `softDelete` field. The BeforeDelete hook of item
+// is called by Commit.
+func (t *Transaction) Purge(item interface{}) *Transaction {
+	return t.queue(func(ctx context.Context) error {
+		if err := beforeDelete(ctx, item); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		return t.purge(item)
+	})
+}
+
+func (t *Transaction) purge(item interface{}) error {
+	input, err := t.repo.deleteInput(item)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	t.add(OperationDelete, aws.ToString(input.TableName), item, types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName:                           input.TableName,
+			Key:                                 input.Key,
+			ConditionExpression:                 input.ConditionExpression,
+			ExpressionAttributeNames:            input.ExpressionAttributeNames,
+			ExpressionAttributeValues:           input.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: input.ReturnValuesOnConditionCheckFailure,
+		},
+	})
+	return nil
+}
+
+// ConditionCheck adds a check that conditions hold for the item with the
+// key of item, without writing it. The whole transaction is cancelled if
+// they do not.
+func (t *Transaction) ConditionCheck(item interface{}, conditions ...Condition) *Transaction {
+	return t.queue(func(ctx context.Context) error {
+		if err := t.conditionCheck(item, conditions); err != nil {
+			return fmt.Errorf("condition check: %w", err)
+		}
+		return nil
+	})
+}
+
+func (t *Transaction) conditionCheck(item interface{}, conditions []Condition) error {
+	if len(conditions) == 0 {
+		return fmt.Errorf("no conditions given")
+	}
+	tableName, key, _, err := t.repo.deleteKey(item)
+	if err != nil {
+		return err
+	}
+	if meta, err := itemMeta(item); err == nil {
+		if err := checkEncryptedConditions(meta, conditions); err != nil {
+			return err
+		}
+	}
+	b := newExpressionBuilder()
+	condition, err := buildConditions(b, conditions)
+	if err != nil {
+		return err
+	}
+	check := &types.ConditionCheck{
+		TableName: aws.String(tableName),
+		Key:       key,
+	}
+	if t.repo.tenant != "" {
+		meta, err := metadataOf(reflect.Indirect(reflect.ValueOf(item)).Type())
+		if err != nil {
+			return err
+		}
+		tenantCondition, err := t.repo.tenantCondition(b, meta)
+		if err != nil {
+			return err
+		}
+		condition = tenantCondition + " AND (" + condition + ")"
+		check.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	check.ConditionExpression = aws.String(condition)
+	check.ExpressionAttributeNames = b.attributeNames()
+	check.ExpressionAttributeValues = b.attributeValues()
+	t.add(OperationConditionCheck, tableName, item, types.TransactWriteItem{ConditionCheck: check})
+	return nil
+}
+
+func (t *Transaction) add(operation, tableName string, value interface{}, item types.TransactWriteItem) {
+	t.items = append(t.items, item)
+	t.operations = append(t.operations, operation)
+	t.tables = append(t.tables, tableName)
+	t.values = append(t.values, value)
+}
+
+// Commit executes every collected operation atomically. If an operation
+// could not be added, that error is returned and nothing is written. If
+// DynamoDB cancels the transaction, the error is a *TransactionError.
+func (t *Transaction) Commit(ctx context.Context) error {
+	if t.err != nil {
+		return t.err
+	}
+	if n := len(t.items) + len(t.ops); n == 0 {
+		return fmt.Errorf("transaction has no operations")
+	} else if n > maxTransactionItems {
+		return fmt.Errorf("transaction has %d operations, the maximum is %d", n, maxTransactionItems)
+	}
+	// Building an operation calls hooks and seals its item, and sealing
+	// twice would encrypt the ciphertext, so the operations are built once
+	// and a transaction that failed to build cannot be committed again.
+	ops := t.ops
+	t.ops = nil
+	for _, op := range ops {
+		if err := op(ctx); err != nil {
+			t.err = err
+			return err
+		}
+	}
+	_, err := t.repo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: t.items,
+	})
+	if err != nil {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 {
+			return t.cancellationError(tce)
+		}
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, fn := range t.afterCommit {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancellationError maps the cancellation reasons onto the operations.
+func (t *Transaction) cancellationError(tce *types.TransactionCanceledException) error {
+	txErr := &TransactionError{Err: tce}
+	for i, reason := range tce.CancellationReasons {
+		code := aws.ToString(reason.Code)
+		if code == "" || code == "None" || i >= len(t.operations) {
+			continue
+		}
+		failure := TransactionFailure{
+			Index:     i,
+			Operation: t.operations[i],
+			TableName: t.tables[i],
+			Code:      code,
+			Message:   aws.ToString(reason.Message),
+		}
+		if code == "ConditionalCheckFailed" {
+			if err := t.repo.tenantError(t.values[i], reason.Item); err != nil {
+				failure.Err = err
+				txErr.Failures = append(txErr.Failures, failure)
+				continue
+			}
+			switch failure.Operation {
+			case OperationCreate:
+				failure.Err = ErrDuplicateKey
+			case OperationUpdate:
+				// As in Repository.Update, an existing item that is not soft
+				// deleted only failed the version check.
+				failure.Err = ErrNotFound
+				if reason.Item != nil && !deletedItem(t.values[i], reason.Item) {
+					failure.Err = ErrVersionConflict
+				}
+			case OperationDelete:
+				failure.Err = ErrNotFound
+			case OperationConditionCheck:
+				failure.Err = ErrConditionFailed
+			}
+		}
+		txErr.Failures = append(txErr.Failures, failure)
+	}
+	return txErr
+}
+
+// TransactionFailure describes an operation that caused a transaction to be cancelled.
+type TransactionFailure struct {
+	// Index is the position of the operation in the transaction.
+	Index     int
+	Operation string
+	TableName string
+	// Code is the cancellation reason code reported by DynamoDB,
+	// e.g. ConditionalCheckFailed or TransactionConflict.
+	Code    string
+	Message string
+	// Err is ErrDuplicateKey, ErrNotFound, ErrVersionConflict,
+	// ErrConditionFailed or a *CrossTenantError when the operation failed its
+	// condition, and nil otherwise.
+	Err error
+}
+
+// TransactionError is returned by Transaction.Commit when DynamoDB cancels
+// the transaction. It matches the Err of every failure with errors.Is, so
+// errors.Is(err, ErrDuplicateKey) reports whether a Create hit an existing key.
+type TransactionError struct {
+	Failures []TransactionFailure
+	Err      error
+}
+
+// Error implements the error interface
+func (e *TransactionError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("transaction cancelled: %v", e.Err)
+	}
+	parts := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		reason := f.Code
+		if f.Err != nil {
+			reason = f.Err.Error()
+		}
+		parts[i] = fmt.Sprintf("operation %d (%s on %s): %s", f.Index, f.Operation, f.TableName, reason)
+	}
+	return "transaction cancelled: " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns the mapped errors of the failures and the original error
+func (e *TransactionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures)+1)
+	for _, f := range e.Failures {
+		if f.Err != nil {
+			errs = append(errs, f.Err)
+		}
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
@@ -0,0 +1,145 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancellingClient cancels every transaction with the given reasons.
+type cancellingClient struct {
+	DynamoDBClient
+	input   *dynamodb.TransactWriteItemsInput
+	reasons []types.CancellationReason
+}
+
+func (c *cancellingClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.input = params
+	return nil, &types.TransactionCanceledException{
+		Message:             aws.String("Transaction cancelled"),
+		CancellationReasons: c.reasons,
+	}
+}
+
+type txOrder struct {
+	UserID  string `dynamodbav:"user_id" dynamo:"user_id,key=hash"`
+	OrderID string `dynamodbav:"order_id" dynamo:"order_id,key=range"`
+	Total   int64  `dynamodbav:"total" dynamo:"total"`
+}
+
+func (o *txOrder) TableName() string {
+	return "Orders"
+}
+
+type txLineItem struct {
+	OrderID string `dynamodbav:"order_id" dynamo:"order_id,key=hash"`
+	Line    int    `dynamodbav:"line" dynamo:"line,key=range"`
+}
+
+func (l *txLineItem) TableName() string {
+	return "OrderLines"
+}
+
+func TestTransaction_CancellationReasons(t *testing.T) {
+	client := &cancellingClient{reasons: []types.CancellationReason{
+		{Code: aws.String("None")},
+		{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed")},
+		{Code: aws.String("None")},
+	}}
+	repo := NewRepository(client, "Default")
+
+	err := repo.Transaction().
+		Create(&txOrder{UserID: "u1", OrderID: "o1"}).
+		Create(&txLineItem{OrderID: "o1", Line: 1}).
+		Update(&txOrder{UserID: "u1", OrderID: "o0", Total: 10}).
+		Commit(context.Background())
+
+	require.Len(t, client.input.TransactItems, 3)
+	assert.Equal(t, "OrderLines", aws.ToString(client.input.TransactItems[1].Put.TableName))
+
+	var txErr *TransactionError
+	require.True(t, errors.As(err, &txErr))
+	require.Len(t, txErr.Failures, 1)
+	assert.Equal(t, 1, txErr.Failures[0].Index)
+	assert.Equal(t, OperationCreate, txErr.Failures[0].Operation)
+	assert.Equal(t, "OrderLines", txErr.Failures[0].TableName)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.NotErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "operation 1 (Create on OrderLines)")
+
+	var tce *types.TransactionCanceledException
+	assert.True(t, errors.As(err, &tce))
+}
+
+func TestTransaction_MapsOperations(t *testing.T) {
+	client := &cancellingClient{reasons: []types.CancellationReason{
+		{Code: aws.String("ConditionalCheckFailed")},
+		{Code: aws.String("ConditionalCheckFailed")},
+		{Code: aws.String("TransactionConflict")},
+	}}
+	repo := NewRepository(client, "Default")
+
+	err := repo.Transaction().
+		Delete(&txOrder{UserID: "u1", OrderID: "o1"}).
+		ConditionCheck(&txOrder{UserID: "u1", OrderID: "o2"}, GreaterThan("total", 0)).
+		Update(&txOrder{UserID: "u1", OrderID: "o3"}).
+		Commit(context.Background())
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, ErrConditionFailed)
+
+	var txErr *TransactionError
+	require.True(t, errors.As(err, &txErr))
+	require.Len(t, txErr.Failures, 3)
+	assert.Equal(t, "TransactionConflict", txErr.Failures[2].Code)
+	assert.Nil(t, txErr.Failures[2].Err)
+}
+
+func TestTransaction_UpdateSoftDeleted(t *testing.T) {
+	type versionedMember struct {
+		ID        string     `dynamodbav:"id" dynamo:"id,key=hash"`
+		Name      string     `dynamodbav:"name" dynamo:"name"`
+		Version   int        `dynamodbav:"version" dynamo:"version,version"`
+		DeletedAt *time.Time `dynamodbav:"deleted_at" dynamo:"deleted_at,softDelete"`
+	}
+	stored := map[string]types.AttributeValue{
+		"id":      &types.AttributeValueMemberS{Value: "m1"},
+		"version": &types.AttributeValueMemberN{Value: "2"},
+	}
+	client := &cancellingClient{reasons: []types.CancellationReason{
+		{Code: aws.String("ConditionalCheckFailed"), Item: stored},
+	}}
+	repo := NewRepository(client, "Members")
+
+	err := repo.Transaction().Update(&versionedMember{ID: "m1", Version: 1}).Commit(context.Background())
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// A soft-deleted item is reported as missing, as Repository.Update does.
+	stored["deleted_at"] = &types.AttributeValueMemberS{Value: "2024-05-01T12:00:00Z"}
+	err = repo.Transaction().Update(&versionedMember{ID: "m1", Version: 1}).Commit(context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrVersionConflict)
+}
+
+func TestTransaction_InvalidOperations(t *testing.T) {
+	repo := NewRepository(&cancellingClient{}, "Default")
+
+	err := repo.Transaction().Commit(context.Background())
+	assert.ErrorContains(t, err, "no operations")
+
+	err = repo.Transaction().ConditionCheck(&txOrder{UserID: "u1", OrderID: "o1"}).Commit(context.Background())
+	assert.ErrorContains(t, err, "no conditions")
+
+	tx := repo.Transaction()
+	for i := 0; i <= maxTransactionItems; i++ {
+		tx.Delete(&txLineItem{OrderID: "o1", Line: i})
+	}
+	assert.ErrorContains(t, tx.Commit(context.Background()), "maximum")
+}
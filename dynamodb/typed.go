@@ -0,0 +1,127 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TypedRepository is a type-safe wrapper around Repository for items of type T.
+// T must be a struct type tagged with `dynamo`; its layout is validated once by
+// NewTypedRepository so the methods never fail because of a wrong argument kind.
+type TypedRepository[T any] struct {
+	repo      *Repository
+	tableName string
+	hashKey   string
+	rangeKey  string
+}
+
+// NewTypedRepository returns a TypedRepository for T backed by repo.
+// The table name is resolved from T the same way Repository resolves it,
+// honouring TableNamer on either T or *T.
+func NewTypedRepository[T any](repo *Repository) (*TypedRepository[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if err := validateModelType(typ); err != nil {
+		return nil, fmt.Errorf("invalid model %s: %w", typ, err)
+	}
+	hashKey, rangeKey := keyAttributes(typ)
+	return &TypedRepository[T]{
+		repo:      repo,
+		tableName: repo.getTableName(new(T)),
+		hashKey:   hashKey,
+		rangeKey:  rangeKey,
+	}, nil
+}
+
+// validateModelType checks that typ is a struct whose `dynamo` tags, including
+// those of embedded structs, declare exactly one hash key and at most one
+// range key.
+func validateModelType(typ reflect.Type) error {
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a struct, got %s", typ.Kind())
+	}
+	meta, err := metadataOf(typ)
+	if err != nil {
+		return err
+	}
+	for _, field := range meta.fields {
+		if field.tag.AttributeName == "" {
+			return fmt.Errorf("field %s has no attribute name in its dynamo tag", field.name)
+		}
+	}
+	if meta.hashKey == "" {
+		return fmt.Errorf("model must declare exactly one hash key, found 0")
+	}
+	return nil
+}
+
+// TableName returns the table the repository reads from and writes to.
+func (r *TypedRepository[T]) TableName() string {
+	return r.tableName
+}
+
+// Get retrieves an item by its hash key.
+func (r *TypedRepository[T]) Get(ctx context.Context, key interface{}, opts ...ReadOption) (T, error) {
+	var item T
+	if r.rangeKey != "" {
+		return item, fmt.Errorf("model defines range key %s: use GetByKey", r.rangeKey)
+	}
+	err := r.repo.FindByID(ctx, key, &item, opts...)
+	return item, err
+}
+
+// GetByKey retrieves an item by its hash key and range key.
+func (r *TypedRepository[T]) GetByKey(ctx context.Context, hashKey, rangeKey interface{}, opts ...ReadOption) (T, error) {
+	var item T
+	err := r.repo.FindByKey(ctx, hashKey, rangeKey, &item, opts...)
+	return item, err
+}
+
+// List retrieves all items from the table.
+func (r *TypedRepository[T]) List(ctx context.Context, opts ...ReadOption) ([]T, error) {
+	var items []T
+	if err := r.repo.GetAll(ctx, &items, opts...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ParallelScan calls fn with every item of the table, read by concurrent
+// Scan workers. See Repository.ParallelScan.
+func (r *TypedRepository[T]) ParallelScan(ctx context.Context, fn func(item T) error, opts ...ScanOption) error {
+	return r.repo.ParallelScan(ctx, new(T), func(item interface{}) error {
+		return fn(*item.(*T))
+	}, opts...)
+}
+
+// FindBy retrieves the items whose attribute equals value. See Repository.FindByParameter.
+func (r *TypedRepository[T]) FindBy(ctx context.Context, attribute string, value interface{}, opts ...ReadOption) ([]T, error) {
+	var items []T
+	if err := r.repo.FindByParameter(ctx, attribute, value, &items, opts...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Put stores a new item. It returns ErrDuplicateKey if the key is taken.
+// The version and timestamps that are written are set on item.
+func (r *TypedRepository[T]) Put(ctx context.Context, item *T) error {
+	return r.repo.Create(ctx, item)
+}
+
+// Update updates an existing item. It returns ErrNotFound if the item does not exist.
+// The incremented version and the update time are set on item.
+func (r *TypedRepository[T]) Update(ctx context.Context, item *T) error {
+	return r.repo.Update(ctx, item)
+}
+
+// Delete deletes the item with the same key as item. It returns ErrNotFound if the item does not exist.
+func (r *TypedRepository[T]) Delete(ctx context.Context, item T) error {
+	return r.repo.Delete(ctx, &item)
+}
+
+// Purge permanently deletes the item with the same key as item, even when T
+// declares a `softDelete` field. It returns ErrNotFound if the item does not exist.
+func (r *TypedRepository[T]) Purge(ctx context.Context, item T) error {
+	return r.repo.Purge(ctx, &item)
+}
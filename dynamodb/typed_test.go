@@ -0,0 +1,99 @@
+package dynamodb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	db "github.com/yuki5155/go-aws/dynamodb"
+)
+
+type noKeyModel struct {
+	Name string `dynamo:"name"`
+}
+
+type twoHashKeysModel struct {
+	ID    string `dynamo:"id,key=hash"`
+	Other string `dynamo:"other,key=hash"`
+}
+
+type unknownKeyModel struct {
+	ID string `dynamo:"id,key=partition"`
+}
+
+func TestNewTypedRepository(t *testing.T) {
+	repo := db.NewRepository(nil, "Default")
+
+	t.Run("Resolves table name from TableNamer", func(t *testing.T) {
+		users, err := db.NewTypedRepository[User](repo)
+		require.NoError(t, err)
+		assert.Equal(t, "Users", users.TableName())
+
+		orders, err := db.NewTypedRepository[Order](repo)
+		require.NoError(t, err)
+		assert.Equal(t, "Orders", orders.TableName())
+	})
+
+	t.Run("Rejects invalid layouts", func(t *testing.T) {
+		_, err := db.NewTypedRepository[*User](repo)
+		assert.ErrorContains(t, err, "must be a struct")
+
+		_, err = db.NewTypedRepository[noKeyModel](repo)
+		assert.ErrorContains(t, err, "exactly one hash key")
+
+		_, err = db.NewTypedRepository[twoHashKeysModel](repo)
+		assert.ErrorContains(t, err, "exactly one hash key")
+
+		_, err = db.NewTypedRepository[unknownKeyModel](repo)
+		assert.ErrorContains(t, err, "unknown key type")
+	})
+}
+
+func TestTypedRepository_Integration(t *testing.T) {
+	if err := loadEnv("../.env"); err != nil {
+		t.Fatal(err)
+	}
+
+	client := setupDynamoDBClient(t)
+	users, err := db.NewTypedRepository[User](db.NewRepository(client, "Users"))
+	require.NoError(t, err)
+
+	t.Run("Put, Get and List", func(t *testing.T) {
+		testUser := generateTestUser("typed")
+		require.NoError(t, users.Put(context.Background(), testUser))
+
+		found, err := users.Get(context.Background(), testUser.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, *testUser, found)
+
+		all, err := users.List(context.Background())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, all)
+
+		byEmail, err := users.FindBy(context.Background(), "email", testUser.Email)
+		assert.NoError(t, err)
+		assert.Len(t, byEmail, 1)
+	})
+
+	t.Run("Get missing item", func(t *testing.T) {
+		_, err := users.Get(context.Background(), "non-existing-id")
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+
+	t.Run("Update and Delete", func(t *testing.T) {
+		testUser := generateTestUser("typed-update")
+		require.NoError(t, users.Put(context.Background(), testUser))
+
+		testUser.Name = "Updated User"
+		require.NoError(t, users.Update(context.Background(), testUser))
+
+		found, err := users.Get(context.Background(), testUser.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Updated User", found.Name)
+
+		require.NoError(t, users.Delete(context.Background(), found))
+		_, err = users.Get(context.Background(), testUser.ID)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+}
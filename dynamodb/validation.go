@@ -0,0 +1,392 @@
+package dynamodb
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validation rules accepted in the `dynamo` struct tag.
+const (
+	RuleRequired = "required"
+	RuleMin      = "min"
+	RuleMax      = "max"
+	RuleLen      = "len"
+	RulePattern  = "pattern"
+	RuleOneOf    = "oneof"
+	RuleEmail    = "email"
+)
+
+// ValidationRule is a validation rule declared in the `dynamo` struct tag,
+// such as `min=3` or `email`.
+type ValidationRule struct {
+	Name  string
+	Param string
+}
+
+// FieldError describes a field that failed a validation rule.
+type FieldError struct {
+	// Field is the Go field name and Attribute its attribute name.
+	Field     string
+	Attribute string
+	Rule      string
+	Param     string
+	// Message describes the failure, e.g. "must be at least 3 characters".
+	Message string
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return fmt.Sprintf("field %s %s", e.Field, e.Message)
+}
+
+// ValidationError is returned by Create, Update and the other writes when
+// an item breaks the validation rules of its `dynamo` tags. It lists every
+// failed rule, not only the first one.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		parts[i] = fieldErr.Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// FieldErrors returns the failure messages keyed by attribute name. It lets
+// lambda.NewValidationFailedError report the failed fields in its response.
+func (e *ValidationError) FieldErrors() map[string][]string {
+	fields := make(map[string][]string, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		fields[fieldErr.Attribute] = append(fields[fieldErr.Attribute], fieldErr.Message)
+	}
+	return fields
+}
+
+// validateStruct checks every field of v against the rules of its `dynamo`
+// tag and returns a *ValidationError listing the failures. Rules other than
+// required are skipped for zero values, so optional fields may be left empty.
+// Fields holding structs, pointers to structs, or slices of either are
+// validated recursively; their failures are reported with paths such as
+// Address.City and Items[0].SKU. A rule that cannot be applied to its field
+// is reported as a plain error.
+func validateStruct(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("item must be a struct")
+	}
+	validationErr := &ValidationError{}
+	if err := validateValue(val, "", "", validationErr); err != nil {
+		return err
+	}
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+// emptyValue reports whether the rules other than required skip v: a nil
+// pointer or an empty string, slice or map. Numbers are always checked, so
+// that min=1 rejects 0.
+func emptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// validateValue appends the failures of the struct value val to
+// validationErr. fieldPrefix and attributePrefix are the paths of val in the
+// item being validated, or empty for the item itself.
+func validateValue(val reflect.Value, fieldPrefix, attributePrefix string, validationErr *ValidationError) error {
+	meta, err := metadataOf(val.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range meta.fields {
+		if err := validateField(field, field.get(val), fieldPrefix, attributePrefix, validationErr); err != nil {
+			return err
+		}
+	}
+	for _, nested := range meta.nested {
+		fieldValue, err := val.FieldByIndexErr(nested.index)
+		if err != nil {
+			continue
+		}
+		if err := validateNestedField(nested, fieldValue, fieldPrefix, attributePrefix, validationErr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateField appends the failures of fieldValue, the value of field, to
+// validationErr.
+func validateField(field *fieldMeta, fieldValue reflect.Value, fieldPrefix, attributePrefix string, validationErr *ValidationError) error {
+	parser := field.tag
+	for _, rule := range parser.Rules {
+		value := fieldValue
+		if rule.Name != RuleRequired {
+			for value.Kind() == reflect.Ptr && !value.IsNil() {
+				value = value.Elem()
+			}
+			if emptyValue(value) {
+				continue
+			}
+		}
+		message, err := checkRule(rule, value)
+		if err != nil {
+			return fmt.Errorf("field %s%s: rule %s: %w", fieldPrefix, field.name, rule.Name, err)
+		}
+		if message != "" {
+			validationErr.Errors = append(validationErr.Errors, FieldError{
+				Field:     fieldPrefix + field.name,
+				Attribute: attributePrefix + parser.AttributeName,
+				Rule:      rule.Name,
+				Param:     rule.Param,
+				Message:   message,
+			})
+		}
+	}
+	return nil
+}
+
+// validateNestedField validates fieldValue, the value of a field holding
+// structs, pointers to structs, or a slice or array of either.
+func validateNestedField(nested *nestedField, fieldValue reflect.Value, fieldPrefix, attributePrefix string, validationErr *ValidationError) error {
+	fieldPath, attributePath := fieldPrefix+nested.name, attributePrefix+nested.attribute
+	if fieldValue.Kind() != reflect.Slice && fieldValue.Kind() != reflect.Array {
+		return validateNested(fieldValue, fieldPath, attributePath, validationErr)
+	}
+	for i := 0; i < fieldValue.Len(); i++ {
+		index := fmt.Sprintf("[%d]", i)
+		if err := validateNested(fieldValue.Index(i), fieldPath+index, attributePath+index, validationErr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateUpdate checks the values update sets against the rules of the
+// fields of meta they are written to, including the fields of nested
+// structs, and rejects the removal of required attributes. Attributes that
+// are not fields of meta, such as document paths, are not checked, and
+// neither are Add and Append, whose result depends on the stored item.
+func validateUpdate(meta *modelMeta, update *UpdateBuilder) error {
+	validationErr := &ValidationError{}
+	for _, action := range update.sets {
+		if action.kind != actionSet && action.kind != actionSetIfNotExists {
+			continue
+		}
+		field, ok := meta.byAttribute[action.attribute]
+		if !ok {
+			continue
+		}
+		value := updateValue(field.typ, action.value)
+		if err := validateField(field, value, "", "", validationErr); err != nil {
+			return err
+		}
+		for _, nested := range meta.nested {
+			if nested.attribute != action.attribute || value.Type() != field.typ {
+				continue
+			}
+			if err := validateNestedField(nested, value, "", "", validationErr); err != nil {
+				return err
+			}
+		}
+	}
+	for _, attribute := range update.removes {
+		field, ok := meta.byAttribute[attribute]
+		if !ok {
+			continue
+		}
+		for _, rule := range field.tag.Rules {
+			if rule.Name == RuleRequired {
+				validationErr.Errors = append(validationErr.Errors, FieldError{
+					Field:     field.name,
+					Attribute: attribute,
+					Rule:      RuleRequired,
+					Message:   "is required",
+				})
+			}
+		}
+	}
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+// updateValue returns value as a value of the field type typ when it can be
+// assigned to it or is a number of another width, so that the rules of the
+// field apply to it. A nil value is the zero value of typ.
+func updateValue(typ reflect.Type, value interface{}) reflect.Value {
+	if value == nil {
+		return reflect.Zero(typ)
+	}
+	val := reflect.ValueOf(value)
+	if val.Type().AssignableTo(typ) || numberKind(val.Kind()) && numberKind(typ.Kind()) {
+		return val.Convert(typ)
+	}
+	return val
+}
+
+// numberKind reports whether k is an integer or floating-point kind.
+func numberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validateNested validates a nested struct or pointer to struct. Nil
+// pointers are skipped; use required on the field to reject them.
+func validateNested(val reflect.Value, fieldPath, attributePath string, validationErr *ValidationError) error {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	return validateValue(val, fieldPath+".", attributePath+".", validationErr)
+}
+
+// checkRule applies rule to value and returns a failure message, or an empty
+// string if value satisfies the rule.
+func checkRule(rule ValidationRule, value reflect.Value) (string, error) {
+	switch rule.Name {
+	case RuleRequired:
+		if value.IsZero() {
+			return "is required", nil
+		}
+		return "", nil
+	case RuleMin, RuleMax, RuleLen:
+		return checkBound(rule, value)
+	case RulePattern:
+		if value.Kind() != reflect.String {
+			return "", fmt.Errorf("not supported for %s", value.Type())
+		}
+		pattern, err := compilePattern(rule.Param)
+		if err != nil {
+			return "", err
+		}
+		if !pattern.MatchString(value.String()) {
+			return fmt.Sprintf("must match %s", rule.Param), nil
+		}
+		return "", nil
+	case RuleOneOf:
+		var actual string
+		switch value.Kind() {
+		case reflect.String:
+			actual = value.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = strconv.FormatInt(value.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = strconv.FormatUint(value.Uint(), 10)
+		default:
+			return "", fmt.Errorf("not supported for %s", value.Type())
+		}
+		allowed := strings.Fields(rule.Param)
+		for _, option := range allowed {
+			if actual == option {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", ")), nil
+	case RuleEmail:
+		if value.Kind() != reflect.String {
+			return "", fmt.Errorf("not supported for %s", value.Type())
+		}
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown rule")
+}
+
+// checkBound applies min, max or len. They compare the length of strings (in
+// characters), slices and maps, and the value of numbers.
+func checkBound(rule ValidationRule, value reflect.Value) (string, error) {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		limit, err := strconv.Atoi(rule.Param)
+		if err != nil || limit < 0 {
+			return "", fmt.Errorf("invalid length %q", rule.Param)
+		}
+		length := value.Len()
+		unit := "elements"
+		if value.Kind() == reflect.String {
+			length = utf8.RuneCountInString(value.String())
+			unit = "characters"
+		}
+		switch {
+		case rule.Name == RuleMin && length < limit:
+			return fmt.Sprintf("must be at least %d %s", limit, unit), nil
+		case rule.Name == RuleMax && length > limit:
+			return fmt.Sprintf("must be at most %d %s", limit, unit), nil
+		case rule.Name == RuleLen && length != limit:
+			return fmt.Sprintf("must be exactly %d %s", limit, unit), nil
+		}
+		return "", nil
+	}
+
+	var number float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = value.Float()
+	default:
+		return "", fmt.Errorf("not supported for %s", value.Type())
+	}
+	if rule.Name == RuleLen {
+		return "", fmt.Errorf("not supported for %s", value.Type())
+	}
+	limit, err := strconv.ParseFloat(rule.Param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid number %q", rule.Param)
+	}
+	switch {
+	case rule.Name == RuleMin && number < limit:
+		return fmt.Sprintf("must be at least %s", rule.Param), nil
+	case rule.Name == RuleMax && number > limit:
+		return fmt.Sprintf("must be at most %s", rule.Param), nil
+	}
+	return "", nil
+}
+
+// patterns caches compiled `pattern=` expressions.
+var patterns sync.Map
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	patterns.Store(expr, pattern)
+	return pattern, nil
+}
@@ -0,0 +1,156 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validatedUser struct {
+	ID       string   `dynamo:"id,key=hash"`
+	Name     string   `dynamo:"name,required,min=2,max=5"`
+	Email    string   `dynamo:"email,email"`
+	Code     string   `dynamo:"code,len=3"`
+	Role     string   `dynamo:"role,oneof=admin member"`
+	Level    int      `dynamo:"level,min=1,max=10,oneof=1 5 10"`
+	Score    *float64 `dynamo:"score,max=1.5"`
+	Tags     []string `dynamo:"tags,max=2"`
+	Slug     string   `dynamo:"slug,pattern=^[a-z]{1,3}(,[a-z]{1,3})*$"`
+	Optional string   `dynamo:"optional,min=3"`
+}
+
+func TestParseDynamoTag_Rules(t *testing.T) {
+	parser := ParseDynamoTag("slug,required,min=1,pattern=^[a-z]{1,3}(,[a-z]+)*$")
+	assert.Equal(t, "slug", parser.AttributeName)
+	assert.True(t, parser.Required)
+	assert.Equal(t, []ValidationRule{
+		{Name: RuleRequired},
+		{Name: RuleMin, Param: "1"},
+		{Name: RulePattern, Param: "^[a-z]{1,3}(,[a-z]+)*$"},
+	}, parser.Rules)
+
+	parser = ParseDynamoTag("role,index=role-index,oneof=admin member,email")
+	assert.Equal(t, "role-index", parser.Index)
+	assert.Equal(t, []ValidationRule{{Name: RuleOneOf, Param: "admin member"}, {Name: RuleEmail}}, parser.Rules)
+}
+
+func TestValidateStruct_Rules(t *testing.T) {
+	score := 1.0
+	valid := validatedUser{
+		ID:    "u1",
+		Name:  "Alice",
+		Email: "alice@example.com",
+		Code:  "日本語",
+		Role:  "admin",
+		Level: 5,
+		Score: &score,
+		Tags:  []string{"a", "b"},
+		Slug:  "ab,cde",
+	}
+	require.NoError(t, validateStruct(&valid))
+
+	// Rules other than required are skipped for empty strings, slices and
+	// nil pointers, but numbers are always checked.
+	require.NoError(t, validateStruct(validatedUser{Name: "Bob", Level: 1}))
+	err := validateStruct(validatedUser{Name: "Bob"})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{"must be at least 1", "must be one of 1, 5, 10"}, validationErr.FieldErrors()["level"])
+	assert.Len(t, validationErr.Errors, 2)
+
+	tooHigh := 2.5
+	invalid := validatedUser{
+		Name:     "A",
+		Email:    "Alice <alice@example.com>",
+		Code:     "ab",
+		Role:     "owner",
+		Level:    11,
+		Score:    &tooHigh,
+		Tags:     []string{"a", "b", "c"},
+		Slug:     "ABC",
+		Optional: "ab",
+	}
+	err = validateStruct(&invalid)
+	require.True(t, errors.As(err, &validationErr))
+	var failed []string
+	for _, fieldErr := range validationErr.Errors {
+		failed = append(failed, fieldErr.Field+":"+fieldErr.Rule)
+	}
+	assert.Equal(t, []string{
+		"Name:min",
+		"Email:email",
+		"Code:len",
+		"Role:oneof",
+		"Level:max",
+		"Level:oneof",
+		"Score:max",
+		"Tags:max",
+		"Slug:pattern",
+		"Optional:min",
+	}, failed)
+	assert.Equal(t, FieldError{
+		Field:     "Name",
+		Attribute: "name",
+		Rule:      RuleMin,
+		Param:     "2",
+		Message:   "must be at least 2 characters",
+	}, validationErr.Errors[0])
+	assert.Equal(t, []string{"must be at most 10", "must be one of 1, 5, 10"}, validationErr.FieldErrors()["level"])
+	assert.Contains(t, err.Error(), "field Role must be one of admin, member; field Level must be at most 10")
+
+	err = validateStruct(validatedUser{Level: 1})
+	assert.EqualError(t, err, "field Name is required")
+}
+
+func TestValidateStruct_InvalidRule(t *testing.T) {
+	type badLen struct {
+		Active bool `dynamo:"active,min=1"`
+	}
+	assert.ErrorContains(t, validateStruct(badLen{Active: true}), "field Active: rule min: not supported for bool")
+
+	type badPattern struct {
+		Code string `dynamo:"code,pattern=[a-"`
+	}
+	err := validateStruct(badPattern{Code: "x"})
+	assert.ErrorContains(t, err, "invalid pattern")
+	var validationErr *ValidationError
+	assert.False(t, errors.As(err, &validationErr))
+}
+
+type validatedLine struct {
+	SKU      string `dynamo:"sku,required"`
+	Quantity int    `dynamo:"quantity,min=1"`
+}
+
+type validatedOrder struct {
+	ID       string           `dynamo:"id,key=hash"`
+	Primary  validatedLine    `dynamodbav:"primary"`
+	Optional *validatedLine   `dynamo:"optional"`
+	Lines    []validatedLine  `dynamodbav:"lines"`
+	Extra    []*validatedLine `dynamo:"extra"`
+	Ignored  validatedLine    `dynamodbav:"-"`
+}
+
+func TestValidateStruct_Nested(t *testing.T) {
+	valid := validatedOrder{ID: "o1", Primary: validatedLine{SKU: "a", Quantity: 1}, Extra: []*validatedLine{nil}}
+	require.NoError(t, validateStruct(valid))
+
+	err := validateStruct(&validatedOrder{
+		Primary:  validatedLine{SKU: "a", Quantity: 1},
+		Optional: &validatedLine{Quantity: -1},
+		Lines:    []validatedLine{{SKU: "b", Quantity: 1}, {SKU: "c", Quantity: -2}},
+		Extra:    []*validatedLine{{Quantity: 1}},
+	})
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string][]string{
+		"optional.sku":      {"is required"},
+		"optional.quantity": {"must be at least 1"},
+		"lines[1].quantity": {"must be at least 1"},
+		"extra[0].sku":      {"is required"},
+	}, validationErr.FieldErrors())
+	assert.Equal(t, "Optional.SKU", validationErr.Errors[0].Field)
+	assert.Equal(t, "Lines[1].Quantity", validationErr.Errors[2].Field)
+}
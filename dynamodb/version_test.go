@@ -0,0 +1,59 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionedItem struct {
+	ID      string `dynamodbav:"id" dynamo:"id,key=hash"`
+	Name    string `dynamodbav:"name" dynamo:"name"`
+	Version int64  `dynamodbav:"version" dynamo:"version,version"`
+}
+
+type stringVersionItem struct {
+	ID      string `dynamo:"id,key=hash"`
+	Version string `dynamo:"version,version"`
+}
+
+func TestParseDynamoTag_Version(t *testing.T) {
+	parser := ParseDynamoTag("version,version")
+	assert.Equal(t, "version", parser.AttributeName)
+	assert.True(t, parser.Version)
+	assert.False(t, ParseDynamoTag("version").Version)
+}
+
+func TestCreateInput_InitializesVersion(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+	item := &versionedItem{ID: "a", Version: 7}
+
+	input, err := repo.createInput(item)
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, input.Item["version"])
+	assert.Equal(t, int64(1), item.Version)
+}
+
+func TestUpdateInput_ChecksVersion(t *testing.T) {
+	repo := NewRepository(nil, "Items")
+
+	input, err := repo.updateInput(&versionedItem{ID: "a", Name: "x", Version: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "SET #n0 = :v0, #n2 = :v2", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(#n1) AND #n2 = :v1", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, "version", input.ExpressionAttributeNames["#n2"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, input.ExpressionAttributeValues[":v1"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "4"}, input.ExpressionAttributeValues[":v2"])
+	assert.Equal(t, types.ReturnValuesOnConditionCheckFailureAllOld, input.ReturnValuesOnConditionCheckFailure)
+
+	// 既存データにバージョン属性がない場合も更新できる
+	input, err = repo.updateInput(&versionedItem{ID: "a", Name: "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "attribute_exists(#n1) AND (attribute_not_exists(#n2) OR #n2 = :v1)", aws.ToString(input.ConditionExpression))
+
+	_, err = repo.updateInput(&stringVersionItem{ID: "a"})
+	assert.ErrorContains(t, err, "must be an integer")
+}
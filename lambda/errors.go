@@ -1,6 +1,8 @@
 package lambda
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -39,6 +41,14 @@ type LambdaError struct {
 	Message    string
 	StatusCode int
 	Err        error
+	// Fields holds per-field failure messages of a validation failed error
+	Fields map[string][]string
+}
+
+// FieldErrorer is implemented by errors that report invalid fields, such as
+// *dynamodb.ValidationError
+type FieldErrorer interface {
+	FieldErrors() map[string][]string
 }
 
 // Error implements the error interface
@@ -54,11 +64,22 @@ func (e *LambdaError) Unwrap() error {
 	return e.Err
 }
 
-// ToAPIGatewayResponse converts a LambdaError to an APIGatewayProxyResponse
+// ToAPIGatewayResponse converts a LambdaError to an APIGatewayProxyResponse.
+// When Fields is set, the body is a JSON object with the message and fields.
 func (e *LambdaError) ToAPIGatewayResponse() events.APIGatewayProxyResponse {
+	body := e.Message
+	if len(e.Fields) > 0 {
+		encoded, err := json.Marshal(struct {
+			Message string              `json:"message"`
+			Fields  map[string][]string `json:"fields"`
+		}{e.Message, e.Fields})
+		if err == nil {
+			body = string(encoded)
+		}
+	}
 	return events.APIGatewayProxyResponse{
 		StatusCode: e.StatusCode,
-		Body:       e.Message,
+		Body:       body,
 		Headers: map[string]string{
 			"Content-Type": "application/json",
 		},
@@ -144,14 +165,20 @@ func NewServiceUnavailableError(msg string, err error) *LambdaError {
 	}
 }
 
-// NewValidationFailedError creates a new validation failed error
+// NewValidationFailedError creates a new validation failed error.
+// If err wraps a FieldErrorer, its field errors are copied into Fields.
 func NewValidationFailedError(msg string, err error) *LambdaError {
-	return &LambdaError{
+	lambdaErr := &LambdaError{
 		Type:       ErrorTypeValidationFailed,
 		Message:    msg,
 		StatusCode: http.StatusBadRequest,
 		Err:        err,
 	}
+	var fieldErr FieldErrorer
+	if errors.As(err, &fieldErr) {
+		lambdaErr.Fields = fieldErr.FieldErrors()
+	}
+	return lambdaErr
 }
 
 // NewRequestFailedError creates a new request failed error
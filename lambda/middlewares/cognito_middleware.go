@@ -16,6 +16,7 @@ type CognitoUser struct {
 	UserID    string
 	Username  string
 	Email     string
+	TenantID  string
 	Groups    []string
 	TokenData map[string]interface{}
 }
@@ -91,6 +92,10 @@ func CognitoAuthMiddleware() Middleware {
 			if username, ok := claims["cognito:username"].(string); ok {
 				user.Username = username
 			}
+			// Extract the tenant of multi-tenant user pools
+			if tenantID, ok := claims["custom:tenant_id"].(string); ok {
+				user.TenantID = tenantID
+			}
 			// Extract groups if available
 			if cognitoGroups, ok := claims["cognito:groups"].([]interface{}); ok {
 				for _, group := range cognitoGroups {
@@ -108,6 +113,7 @@ func CognitoAuthMiddleware() Middleware {
 			req.Headers["X-Cognito-User-ID"] = user.UserID
 			req.Headers["X-Cognito-Username"] = user.Username
 			req.Headers["X-Cognito-Email"] = user.Email
+			req.Headers["X-Cognito-Tenant-ID"] = user.TenantID
 
 			// Process the request with the next handler
 			resp, err := next(req)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -10,18 +11,26 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
+	db "github.com/yuki5155/go-aws/dynamodb"
 )
 
 // User represents a user in our system
 type User struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string `json:"id" dynamodbav:"id" dynamo:"id,key=hash"`
+	Email     string `json:"email" dynamodbav:"email" dynamo:"email,required,index=email-index"`
+	Name      string `json:"name" dynamodbav:"name" dynamo:"name,required,index=name-index"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at" dynamo:"created_at,autoCreateTime"`
+}
+
+// TableName implements db.TableNamer
+func (u *User) TableName() string {
+	return "Users"
 }
 
 var dynamoClient *dynamodb.Client
+var userRepo *db.Repository
 var s3Client *s3.Client
 
 func init() {
@@ -47,11 +56,23 @@ func init() {
 
 	// DynamoDBクライアントの初期化
 	dynamoClient = dynamodb.NewFromConfig(cfg)
+	userRepo = db.NewRepository(dynamoClient, "Users")
 	// S3クライアントの初期化
 	s3Client = s3.NewFromConfig(cfg)
 }
 
+// ensureTables creates the tables of the models from their struct tags if
+// they do not exist yet.
+func ensureTables(ctx context.Context) error {
+	// Usersテーブルを構造体の定義から作成
+	return userRepo.EnsureTable(ctx, &User{})
+}
+
 func main() {
+	if err := ensureTables(context.TODO()); err != nil {
+		log.Fatal(err)
+	}
+
 	r := gin.Default()
 
 	// ヘルスチェックエンドポイント
@@ -80,15 +101,17 @@ func createUser(c *gin.Context) {
 		return
 	}
 
-	_, err := dynamoClient.PutItem(context.TODO(), &dynamodb.PutItemInput{
-		TableName: aws.String("Users"),
-		Item: map[string]types.AttributeValue{
-			"id":   &types.AttributeValueMemberS{Value: user.ID},
-			"name": &types.AttributeValueMemberS{Value: user.Name},
-		},
-	})
-
-	if err != nil {
+	// バリデーションとcreated_atの設定はRepositoryが行う
+	err := userRepo.Create(c.Request.Context(), &user)
+	var validationErr *db.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, db.ErrDuplicateKey):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -97,28 +120,15 @@ func createUser(c *gin.Context) {
 }
 
 func getUser(c *gin.Context) {
-	id := c.Param("id")
-
-	result, err := dynamoClient.GetItem(context.TODO(), &dynamodb.GetItemInput{
-		TableName: aws.String("Users"),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if result.Item == nil {
+	var user User
+	err := userRepo.FindByID(c.Request.Context(), c.Param("id"), &user)
+	if errors.Is(err, db.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
-
-	user := User{
-		ID:   result.Item["id"].(*types.AttributeValueMemberS).Value,
-		Name: result.Item["name"].(*types.AttributeValueMemberS).Value,
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, user)